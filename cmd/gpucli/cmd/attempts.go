@@ -0,0 +1,134 @@
+package cmd
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"strings"
+	"text/tabwriter"
+	"time"
+
+	"github.com/spf13/cobra"
+)
+
+type jobAttempt struct {
+	Attempt       int        `json:"attempt"`
+	Status        string     `json:"status"`
+	WorkerID      string     `json:"worker_id"`
+	StartedAt     *time.Time `json:"started_at"`
+	FinishedAt    *time.Time `json:"finished_at"`
+	ExitCode      *int       `json:"exit_code"`
+	Signal        string     `json:"signal"`
+	TermSignal    string     `json:"termination_signal"`
+	Error         string     `json:"error"`
+	FailureReason string     `json:"failure_reason"`
+	PeakMemory    int64      `json:"peak_memory_bytes"`
+	CPUSeconds    float64    `json:"cpu_seconds"`
+	LogStartID    string     `json:"log_start_id"`
+	LogEndID      string     `json:"log_end_id"`
+}
+
+var attemptsCmd = &cobra.Command{
+	Use:   "attempts [jobID]",
+	Short: "Show every attempt of a job",
+	Args:  cobra.ExactArgs(1),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		jobID := args[0]
+		asJSON, _ := cmd.Flags().GetBool("json")
+
+		base := strings.TrimRight(server, "/")
+		resp, err := http.Get(base + "/jobs/" + jobID + "/attempts")
+		if err != nil {
+			return fmt.Errorf("attempts request failed: %w", err)
+		}
+		defer resp.Body.Close()
+
+		payload, _ := io.ReadAll(resp.Body)
+		if resp.StatusCode >= 300 {
+			return fmt.Errorf("attempts failed (%s): %s", resp.Status, strings.TrimSpace(string(payload)))
+		}
+
+		if asJSON {
+			fmt.Println(strings.TrimSpace(string(payload)))
+			return nil
+		}
+
+		var body struct {
+			Attempts []jobAttempt `json:"attempts"`
+		}
+		if err := json.Unmarshal(payload, &body); err != nil {
+			return fmt.Errorf("parse response: %w", err)
+		}
+
+		tw := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+		fmt.Fprintln(tw, "ATTEMPT\tSTATUS\tWORKER\tSTARTED\tDURATION\tEXIT\tREASON\tPEAK MEM\tCPU\tERROR")
+		for _, a := range body.Attempts {
+			started, duration := "-", "-"
+			if a.StartedAt != nil {
+				started = a.StartedAt.Local().Format("2006-01-02 15:04:05")
+				if a.FinishedAt != nil {
+					duration = a.FinishedAt.Sub(*a.StartedAt).Round(time.Millisecond).String()
+				}
+			}
+			exit := "-"
+			if a.ExitCode != nil {
+				exit = fmt.Sprintf("%d", *a.ExitCode)
+			} else if a.Signal != "" {
+				exit = a.Signal
+			}
+			if a.TermSignal != "" {
+				exit += " (sent " + a.TermSignal + ")"
+			}
+			peak, cpu := "-", "-"
+			if a.PeakMemory > 0 || a.CPUSeconds > 0 {
+				peak, cpu = formatBytes(a.PeakMemory), fmt.Sprintf("%.1fs", a.CPUSeconds)
+			}
+			fmt.Fprintf(tw, "%d\t%s\t%s\t%s\t%s\t%s\t%s\t%s\t%s\t%s\n",
+				a.Attempt,
+				a.Status,
+				orDash(a.WorkerID),
+				started,
+				duration,
+				exit,
+				orDash(a.FailureReason),
+				peak,
+				cpu,
+				orDash(truncate(firstLine(a.Error), 60)),
+			)
+		}
+		return tw.Flush()
+	},
+}
+
+func orDash(s string) string {
+	if s == "" {
+		return "-"
+	}
+	return s
+}
+
+// formatBytes prints a byte count in the largest binary unit below it.
+func formatBytes(n int64) string {
+	const unit = 1024
+	if n < unit {
+		return fmt.Sprintf("%dB", n)
+	}
+	div, exp := int64(unit), 0
+	for m := n / unit; m >= unit && exp < 3; m /= unit {
+		div *= unit
+		exp++
+	}
+	return fmt.Sprintf("%.1f%s", float64(n)/float64(div), []string{"KB", "MB", "GB", "TB"}[exp])
+}
+
+func firstLine(s string) string {
+	line, _, _ := strings.Cut(s, "\n")
+	return line
+}
+
+func init() {
+	attemptsCmd.Flags().Bool("json", false, "Print the raw JSON response")
+	rootCmd.AddCommand(attemptsCmd)
+}
@@ -0,0 +1,222 @@
+package cmd
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"strconv"
+	"strings"
+	"text/tabwriter"
+	"time"
+
+	"github.com/spf13/cobra"
+)
+
+type deadLetter struct {
+	Job struct {
+		ID       string `json:"id"`
+		Command  string `json:"command"`
+		JobTrial int    `json:"job_trial"`
+	} `json:"job"`
+	Reason string    `json:"reason"`
+	Error  string    `json:"error"`
+	DeadAt time.Time `json:"dead_at"`
+}
+
+var dlqCmd = &cobra.Command{
+	Use:   "dlq",
+	Short: "Inspect and manage jobs that exhausted their retries",
+}
+
+var dlqListCmd = &cobra.Command{
+	Use:   "list",
+	Short: "List dead-lettered jobs",
+	RunE: func(cmd *cobra.Command, args []string) error {
+		asJSON, _ := cmd.Flags().GetBool("json")
+		limit, _ := cmd.Flags().GetInt("limit")
+
+		path := "/dlq"
+		if limit > 0 {
+			path += "?limit=" + strconv.Itoa(limit)
+		}
+		payload, err := dlqRequest(http.MethodGet, path, nil)
+		if err != nil {
+			return err
+		}
+		if asJSON {
+			fmt.Println(strings.TrimSpace(string(payload)))
+			return nil
+		}
+
+		var body struct {
+			Entries []deadLetter `json:"entries"`
+		}
+		if err := json.Unmarshal(payload, &body); err != nil {
+			return fmt.Errorf("parse response: %w", err)
+		}
+
+		tw := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+		fmt.Fprintln(tw, "ID\tDEAD SINCE\tATTEMPTS\tREASON\tCOMMAND\tERROR")
+		for _, e := range body.Entries {
+			fmt.Fprintf(tw, "%s\t%s\t%d\t%s\t%s\t%s\n",
+				e.Job.ID,
+				e.DeadAt.Local().Format("2006-01-02 15:04:05"),
+				e.Job.JobTrial,
+				orDash(e.Reason),
+				truncate(e.Job.Command, 40),
+				orDash(truncate(firstLine(e.Error), 60)),
+			)
+		}
+		return tw.Flush()
+	},
+}
+
+var dlqRetryCmd = &cobra.Command{
+	Use:   "retry [jobID]",
+	Short: "Requeue a dead-lettered job, optionally overriding its settings",
+	Args:  cobra.ExactArgs(1),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		jobID := args[0]
+		flags := cmd.Flags()
+
+		policy, err := retryPolicyFromFlags(cmd)
+		if err != nil {
+			return err
+		}
+		body := map[string]any{}
+		if retries, ok := policy["max_retries"]; ok {
+			body["max_retries"] = retries
+			delete(policy, "max_retries")
+		}
+		if len(policy) > 0 {
+			body["retry_policy"] = policy
+		}
+		if command, _ := flags.GetString("cmd"); command != "" {
+			body["command"] = command
+		}
+		if flags.Changed("timeout") {
+			timeout, _ := flags.GetDuration("timeout")
+			if timeout < time.Second {
+				return fmt.Errorf("invalid timeout '%s': must be at least 1s", timeout)
+			}
+			body["timeout_seconds"] = int64(timeout / time.Second)
+		}
+		if deadline, _ := flags.GetString("deadline"); deadline != "" {
+			t, err := time.Parse(time.RFC3339, deadline)
+			if err != nil {
+				return fmt.Errorf("invalid deadline '%s': must be RFC3339", deadline)
+			}
+			body["deadline"] = t
+		}
+		labels, _ := flags.GetStringSlice("label")
+		labelMap := make(map[string]string, len(labels))
+		for _, l := range labels {
+			key, value, ok := strings.Cut(l, "=")
+			if !ok || key == "" {
+				return fmt.Errorf("invalid label '%s': must be key=value", l)
+			}
+			labelMap[key] = value
+		}
+		if len(labelMap) > 0 {
+			body["labels"] = labelMap
+		}
+
+		data, err := json.Marshal(body)
+		if err != nil {
+			return fmt.Errorf("encode request: %w", err)
+		}
+		payload, err := dlqRequest(http.MethodPost, "/dlq/"+jobID+"/retry", data)
+		if err != nil {
+			return err
+		}
+
+		var job struct {
+			ID       string `json:"id"`
+			Status   string `json:"status"`
+			JobTrial int    `json:"job_trial"`
+		}
+		if err := json.Unmarshal(payload, &job); err != nil {
+			return fmt.Errorf("parse response: %w", err)
+		}
+		fmt.Printf("Job requeued: %s (status: %s, attempt %d)\n", job.ID, job.Status, job.JobTrial)
+		return nil
+	},
+}
+
+var dlqPurgeCmd = &cobra.Command{
+	Use:   "purge [jobID]",
+	Short: "Remove one job, or with --all every job, from the dead-letter queue",
+	Args:  cobra.MaximumNArgs(1),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		all, _ := cmd.Flags().GetBool("all")
+		path := "/dlq"
+		switch {
+		case len(args) == 1 && !all:
+			path += "/" + args[0]
+		case len(args) == 0 && all:
+		default:
+			return fmt.Errorf("give either a job ID or --all")
+		}
+
+		payload, err := dlqRequest(http.MethodDelete, path, nil)
+		if err != nil {
+			return err
+		}
+		var body struct {
+			Purged int64 `json:"purged"`
+		}
+		if err := json.Unmarshal(payload, &body); err != nil {
+			return fmt.Errorf("parse response: %w", err)
+		}
+		fmt.Printf("Purged %d dead-letter entries\n", body.Purged)
+		return nil
+	},
+}
+
+func dlqRequest(method, path string, data []byte) ([]byte, error) {
+	base := strings.TrimRight(server, "/")
+	req, err := http.NewRequest(method, base+path, bytes.NewReader(data))
+	if err != nil {
+		return nil, err
+	}
+	if data != nil {
+		req.Header.Set("Content-Type", "application/json")
+	}
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("dlq request failed: %w", err)
+	}
+	defer resp.Body.Close()
+
+	payload, _ := io.ReadAll(resp.Body)
+	if resp.StatusCode >= 300 {
+		return nil, fmt.Errorf("dlq failed (%s): %s", resp.Status, strings.TrimSpace(string(payload)))
+	}
+	return payload, nil
+}
+
+func init() {
+	dlqListCmd.Flags().Int("limit", 0, "Maximum entries to show (server default 100)")
+	dlqListCmd.Flags().Bool("json", false, "Print the raw JSON response")
+
+	dlqRetryCmd.Flags().String("cmd", "", "Run this command instead of the original")
+	dlqRetryCmd.Flags().StringSlice("label", nil, "Label to add or change (key=value, repeatable)")
+	dlqRetryCmd.Flags().Duration("timeout", 0, "New maximum run time")
+	dlqRetryCmd.Flags().String("deadline", "", "New absolute deadline (RFC3339)")
+	dlqRetryCmd.Flags().Int("retries", 0, "Retries allowed after this attempt (0 disables retries)")
+	dlqRetryCmd.Flags().Duration("backoff", 0, "Delay before the first retry, doubled on each retry")
+	dlqRetryCmd.Flags().Duration("max-backoff", 0, "Upper bound on the retry delay")
+	dlqRetryCmd.Flags().Float64("jitter", 0, "Randomise each retry delay by up to this fraction (0-1)")
+	dlqRetryCmd.Flags().StringSlice("retry-on", nil, "Only retry these failure reasons")
+	dlqRetryCmd.Flags().StringSlice("no-retry-on", nil, "Never retry these failure reasons")
+	dlqRetryCmd.Flags().IntSlice("retry-on-exit", nil, "Only retry these exit codes")
+	dlqRetryCmd.Flags().IntSlice("no-retry-on-exit", nil, "Never retry these exit codes")
+
+	dlqPurgeCmd.Flags().Bool("all", false, "Purge every entry")
+
+	dlqCmd.AddCommand(dlqListCmd, dlqRetryCmd, dlqPurgeCmd)
+	rootCmd.AddCommand(dlqCmd)
+}
@@ -0,0 +1,70 @@
+package cmd
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"strings"
+	"text/tabwriter"
+
+	"github.com/spf13/cobra"
+)
+
+var gpusCmd = &cobra.Command{
+	Use:   "gpus",
+	Short: "List the server's GPUs and the jobs using them",
+	Args:  cobra.NoArgs,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		asJSON, _ := cmd.Flags().GetBool("json")
+
+		base := strings.TrimRight(server, "/")
+		resp, err := http.Get(base + "/gpus")
+		if err != nil {
+			return fmt.Errorf("gpus request failed: %w", err)
+		}
+		defer resp.Body.Close()
+
+		payload, _ := io.ReadAll(resp.Body)
+		if resp.StatusCode >= 300 {
+			return fmt.Errorf("gpus failed (%s): %s", resp.Status, strings.TrimSpace(string(payload)))
+		}
+
+		if asJSON {
+			fmt.Println(strings.TrimSpace(string(payload)))
+			return nil
+		}
+
+		var devices []struct {
+			Index       int    `json:"index"`
+			UUID        string `json:"uuid"`
+			Model       string `json:"model"`
+			MemoryBytes int64  `json:"memory_bytes"`
+			JobID       string `json:"job_id"`
+		}
+		if err := json.Unmarshal(payload, &devices); err != nil {
+			return fmt.Errorf("parse response: %w", err)
+		}
+		if len(devices) == 0 {
+			fmt.Println("The server has no GPUs")
+			return nil
+		}
+
+		tw := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+		fmt.Fprintln(tw, "INDEX\tMODEL\tMEMORY\tUUID\tJOB")
+		for _, d := range devices {
+			job := "-"
+			if d.JobID != "" {
+				job = d.JobID
+			}
+			fmt.Fprintf(tw, "%d\t%s\t%s\t%s\t%s\n", d.Index, d.Model, formatBytes(d.MemoryBytes), d.UUID, job)
+		}
+		return tw.Flush()
+	},
+}
+
+func init() {
+	gpusCmd.Flags().Bool("json", false, "Print the raw JSON response")
+	rootCmd.AddCommand(gpusCmd)
+}
@@ -0,0 +1,207 @@
+package cmd
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+	"text/tabwriter"
+	"time"
+
+	"github.com/spf13/cobra"
+)
+
+type listedJob struct {
+	ID         string            `json:"id"`
+	Command    string            `json:"command"`
+	Status     string            `json:"status"`
+	CreatedAt  time.Time         `json:"created_at"`
+	StartedAt  *time.Time        `json:"started_at"`
+	FinishedAt *time.Time        `json:"finished_at"`
+	Labels     map[string]string `json:"labels"`
+	Priority   int               `json:"priority"`
+}
+
+type jobPage struct {
+	Jobs       []json.RawMessage `json:"jobs"`
+	NextCursor string            `json:"next_cursor"`
+}
+
+var listCmd = &cobra.Command{
+	Use:   "list",
+	Short: "List jobs",
+	RunE: func(cmd *cobra.Command, args []string) error {
+		output, _ := cmd.Flags().GetString("output")
+		if output != "table" && output != "json" {
+			return fmt.Errorf("invalid output '%s': must be table or json", output)
+		}
+		all, _ := cmd.Flags().GetBool("all")
+
+		query, err := listQuery(cmd)
+		if err != nil {
+			return err
+		}
+
+		base := strings.TrimRight(server, "/")
+		var raw []json.RawMessage
+		var next string
+		for {
+			page, err := fetchJobPage(base, query)
+			if err != nil {
+				return err
+			}
+			raw = append(raw, page.Jobs...)
+			next = page.NextCursor
+			if !all || next == "" {
+				break
+			}
+			query.Set("cursor", next)
+		}
+
+		if output == "json" {
+			enc := json.NewEncoder(os.Stdout)
+			enc.SetIndent("", "  ")
+			return enc.Encode(map[string]any{"jobs": raw, "next_cursor": next})
+		}
+
+		tw := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+		fmt.Fprintln(tw, "ID\tSTATUS\tPRIORITY\tCREATED\tCOMMAND\tLABELS")
+		for _, r := range raw {
+			var job listedJob
+			if err := json.Unmarshal(r, &job); err != nil {
+				return fmt.Errorf("parse response: %w", err)
+			}
+			fmt.Fprintf(tw, "%s\t%s\t%d\t%s\t%s\t%s\n",
+				job.ID,
+				job.Status,
+				job.Priority,
+				job.CreatedAt.Local().Format("2006-01-02 15:04:05"),
+				truncate(job.Command, 48),
+				formatLabels(job.Labels),
+			)
+		}
+		if err := tw.Flush(); err != nil {
+			return err
+		}
+		if next != "" {
+			fmt.Printf("\nMore jobs available: --cursor %s\n", next)
+		}
+		return nil
+	},
+}
+
+func listQuery(cmd *cobra.Command) (url.Values, error) {
+	q := url.Values{}
+	statuses, _ := cmd.Flags().GetStringSlice("status")
+	for _, s := range statuses {
+		q.Add("status", s)
+	}
+	labels, _ := cmd.Flags().GetStringSlice("label")
+	for _, l := range labels {
+		if !strings.Contains(l, "=") {
+			return nil, fmt.Errorf("invalid label '%s': must be key=value", l)
+		}
+		q.Add("label", l)
+	}
+	for flag, param := range map[string]string{
+		"command": "command",
+		"sort":    "sort",
+		"order":   "order",
+		"cursor":  "cursor",
+		"array":   "array_id",
+	} {
+		if v, _ := cmd.Flags().GetString(flag); v != "" {
+			q.Set(param, v)
+		}
+	}
+	for flag, param := range map[string]string{
+		"since": "created_after",
+		"until": "created_before",
+	} {
+		v, _ := cmd.Flags().GetString(flag)
+		if v == "" {
+			continue
+		}
+		t, err := parseTimeFlag(v)
+		if err != nil {
+			return nil, fmt.Errorf("invalid %s value '%s': %w", flag, v, err)
+		}
+		q.Set(param, t.Format(time.RFC3339))
+	}
+	if limit, _ := cmd.Flags().GetInt("limit"); limit > 0 {
+		q.Set("limit", strconv.Itoa(limit))
+	}
+	return q, nil
+}
+
+func fetchJobPage(base string, query url.Values) (*jobPage, error) {
+	resp, err := http.Get(base + "/jobs?" + query.Encode())
+	if err != nil {
+		return nil, fmt.Errorf("list request failed: %w", err)
+	}
+	defer resp.Body.Close()
+
+	payload, _ := io.ReadAll(resp.Body)
+	if resp.StatusCode >= 300 {
+		return nil, fmt.Errorf("list failed (%s): %s", resp.Status, strings.TrimSpace(string(payload)))
+	}
+
+	var page jobPage
+	if err := json.Unmarshal(payload, &page); err != nil {
+		return nil, fmt.Errorf("parse response: %w", err)
+	}
+	return &page, nil
+}
+
+// parseTimeFlag accepts either an RFC3339 timestamp or a duration that is
+// interpreted relative to now, so "--since 24h" means the last day.
+func parseTimeFlag(v string) (time.Time, error) {
+	if d, err := time.ParseDuration(v); err == nil {
+		return time.Now().Add(-d), nil
+	}
+	return time.Parse(time.RFC3339, v)
+}
+
+func formatLabels(labels map[string]string) string {
+	if len(labels) == 0 {
+		return "-"
+	}
+	keys := make([]string, 0, len(labels))
+	for k := range labels {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	parts := make([]string, len(keys))
+	for i, k := range keys {
+		parts[i] = k + "=" + labels[k]
+	}
+	return strings.Join(parts, ",")
+}
+
+func truncate(s string, n int) string {
+	if len(s) <= n {
+		return s
+	}
+	return s[:n-3] + "..."
+}
+
+func init() {
+	listCmd.Flags().StringSlice("status", nil, "Only show jobs with these statuses")
+	listCmd.Flags().String("command", "", "Only show jobs whose command contains this text")
+	listCmd.Flags().String("array", "", "Only show the jobs of this array")
+	listCmd.Flags().StringSlice("label", nil, "Only show jobs with this label (key=value, repeatable)")
+	listCmd.Flags().String("since", "", "Only show jobs created after this time (RFC3339 or duration such as 24h)")
+	listCmd.Flags().String("until", "", "Only show jobs created before this time (RFC3339 or duration)")
+	listCmd.Flags().String("sort", "created_at", "Sort by created_at, started_at, finished_at, deadline or priority")
+	listCmd.Flags().String("order", "desc", "Sort order: asc or desc")
+	listCmd.Flags().Int("limit", 0, "Maximum jobs per page")
+	listCmd.Flags().String("cursor", "", "Cursor returned by a previous list")
+	listCmd.Flags().Bool("all", false, "Fetch every page")
+	listCmd.Flags().StringP("output", "o", "table", "Output format: table or json")
+	rootCmd.AddCommand(listCmd)
+}
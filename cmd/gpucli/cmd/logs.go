@@ -0,0 +1,228 @@
+package cmd
+
+import (
+	"bufio"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/spf13/cobra"
+)
+
+type logRecord struct {
+	ID        string         `json:"id"`
+	Level     string         `json:"level"`
+	Message   string         `json:"message"`
+	Timestamp time.Time      `json:"timestamp"`
+	Stream    string         `json:"stream"`
+	Fields    map[string]any `json:"fields"`
+}
+
+// errStreamEnded is returned by followLogs when the server closed the
+// stream without reporting a terminal status, so the caller reconnects.
+var errStreamEnded = errors.New("log stream ended")
+
+var logsCmd = &cobra.Command{
+	Use:   "logs [jobID]",
+	Short: "Show job logs",
+	Args:  cobra.ExactArgs(1),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		jobID := args[0]
+		follow, _ := cmd.Flags().GetBool("follow")
+		asJSON, _ := cmd.Flags().GetBool("json")
+
+		query := url.Values{}
+		levels, _ := cmd.Flags().GetStringSlice("level")
+		if len(levels) > 0 {
+			query.Set("level", strings.Join(levels, ","))
+		}
+		streams, _ := cmd.Flags().GetStringSlice("stream")
+		if len(streams) > 0 {
+			query.Set("stream", strings.Join(streams, ","))
+		}
+		if since, _ := cmd.Flags().GetString("since"); since != "" {
+			t, err := parseTimeFlag(since)
+			if err != nil {
+				return fmt.Errorf("invalid since value '%s': %w", since, err)
+			}
+			query.Set("since", t.Format(time.RFC3339))
+		}
+		if attempt, _ := cmd.Flags().GetInt("attempt"); attempt > 0 {
+			query.Set("attempt", strconv.Itoa(attempt))
+		}
+		if tail, _ := cmd.Flags().GetInt("tail"); tail > 0 && !follow {
+			query.Set("tail", strconv.Itoa(tail))
+		}
+
+		base := strings.TrimRight(server, "/")
+		endpoint := fmt.Sprintf("%s/jobs/%s/logs", base, jobID)
+
+		if !follow {
+			records, err := fetchLogs(endpoint, query)
+			if err != nil {
+				return err
+			}
+			for _, rec := range records {
+				printRecord(rec, asJSON)
+			}
+			return nil
+		}
+
+		query.Set("follow", "true")
+		lastID := ""
+		for {
+			status, err := followLogs(endpoint, query, &lastID, asJSON)
+			if err == nil {
+				if !asJSON {
+					fmt.Fprintf(os.Stderr, "Job %s finished with status %s\n", jobID, status)
+				}
+				return nil
+			}
+			if !errors.Is(err, errStreamEnded) {
+				return err
+			}
+			time.Sleep(time.Second)
+		}
+	},
+}
+
+func fetchLogs(endpoint string, query url.Values) ([]logRecord, error) {
+	resp, err := http.Get(endpoint + "?" + query.Encode())
+	if err != nil {
+		return nil, fmt.Errorf("logs request failed: %w", err)
+	}
+	defer resp.Body.Close()
+
+	payload, _ := io.ReadAll(resp.Body)
+	if resp.StatusCode >= 300 {
+		return nil, fmt.Errorf("logs failed (%s): %s", resp.Status, strings.TrimSpace(string(payload)))
+	}
+
+	var body struct {
+		Entries []logRecord `json:"entries"`
+	}
+	if err := json.Unmarshal(payload, &body); err != nil {
+		return nil, fmt.Errorf("parse response: %w", err)
+	}
+	return body.Entries, nil
+}
+
+// followLogs reads one SSE connection, printing log events as they arrive.
+// It returns the job's final status on an "end" event and keeps lastID up
+// to date so a reconnect resumes where the stream stopped.
+func followLogs(endpoint string, query url.Values, lastID *string, asJSON bool) (string, error) {
+	req, err := http.NewRequest(http.MethodGet, endpoint+"?"+query.Encode(), nil)
+	if err != nil {
+		return "", err
+	}
+	req.Header.Set("Accept", "text/event-stream")
+	if *lastID != "" {
+		req.Header.Set("Last-Event-ID", *lastID)
+	}
+
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		return "", fmt.Errorf("logs request failed: %w", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode >= 300 {
+		payload, _ := io.ReadAll(resp.Body)
+		return "", fmt.Errorf("logs failed (%s): %s", resp.Status, strings.TrimSpace(string(payload)))
+	}
+
+	scanner := bufio.NewScanner(resp.Body)
+	scanner.Buffer(make([]byte, 64*1024), 1024*1024)
+
+	var event, id string
+	var data []string
+	for scanner.Scan() {
+		line := scanner.Text()
+		switch {
+		case line == "":
+			payload := strings.Join(data, "\n")
+			switch event {
+			case "end":
+				var end struct {
+					Status string `json:"status"`
+				}
+				_ = json.Unmarshal([]byte(payload), &end)
+				return end.Status, nil
+			case "log":
+				var rec logRecord
+				if err := json.Unmarshal([]byte(payload), &rec); err == nil {
+					rec.ID = id
+					printRecord(rec, asJSON)
+				}
+			}
+			if id != "" {
+				*lastID = id
+			}
+			event, id, data = "", "", nil
+		case strings.HasPrefix(line, ":"):
+			// comment / keep-alive
+		case strings.HasPrefix(line, "event:"):
+			event = strings.TrimSpace(strings.TrimPrefix(line, "event:"))
+		case strings.HasPrefix(line, "id:"):
+			id = strings.TrimSpace(strings.TrimPrefix(line, "id:"))
+		case strings.HasPrefix(line, "data:"):
+			data = append(data, strings.TrimPrefix(strings.TrimPrefix(line, "data:"), " "))
+		}
+	}
+	return "", errStreamEnded
+}
+
+func printRecord(rec logRecord, asJSON bool) {
+	if asJSON {
+		data, _ := json.Marshal(rec)
+		fmt.Println(string(data))
+		return
+	}
+
+	ts := rec.Timestamp.Local().Format("2006-01-02 15:04:05.000")
+	if rec.Stream != "" {
+		if rec.Fields["encoding"] == "base64" {
+			raw, _ := base64.StdEncoding.DecodeString(rec.Message)
+			fmt.Printf("%s %-6s| <%d bytes of binary output>\n", ts, rec.Stream, len(raw))
+			return
+		}
+		fmt.Printf("%s %-6s| %s\n", ts, rec.Stream, rec.Message)
+		return
+	}
+
+	keys := make([]string, 0, len(rec.Fields))
+	for k := range rec.Fields {
+		if k == "job_id" {
+			continue
+		}
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+
+	var b strings.Builder
+	fmt.Fprintf(&b, "%s %-6s %s", ts, strings.ToUpper(rec.Level), rec.Message)
+	for _, k := range keys {
+		fmt.Fprintf(&b, " %s=%v", k, rec.Fields[k])
+	}
+	fmt.Println(b.String())
+}
+
+func init() {
+	logsCmd.Flags().BoolP("follow", "f", false, "Stream new log entries until the job finishes")
+	logsCmd.Flags().StringSlice("level", nil, "Only show entries with these levels")
+	logsCmd.Flags().StringSlice("stream", nil, "Only show program output from these streams (stdout, stderr)")
+	logsCmd.Flags().String("since", "", "Only show entries after this time (RFC3339 or duration such as 10m)")
+	logsCmd.Flags().Int("tail", 0, "Only show the last N entries")
+	logsCmd.Flags().Int("attempt", 0, "Only show entries written during this attempt")
+	logsCmd.Flags().Bool("json", false, "Print raw JSON entries")
+	rootCmd.AddCommand(logsCmd)
+}
@@ -0,0 +1,176 @@
+package cmd
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"strings"
+	"text/tabwriter"
+	"time"
+
+	"github.com/spf13/cobra"
+)
+
+type schedule struct {
+	ID        string     `json:"id"`
+	Name      string     `json:"name"`
+	Cron      string     `json:"cron"`
+	Timezone  string     `json:"timezone"`
+	Overlap   string     `json:"overlap"`
+	NextRunAt time.Time  `json:"next_run_at"`
+	LastRunAt *time.Time `json:"last_run_at"`
+	LastJobID string     `json:"last_job_id"`
+	Job       struct {
+		Command string `json:"command"`
+	} `json:"job"`
+}
+
+var scheduleCmd = &cobra.Command{
+	Use:   "schedule",
+	Short: "Manage recurring jobs",
+}
+
+var scheduleCreateCmd = &cobra.Command{
+	Use:   "create",
+	Short: "Create a schedule that submits a job on a cron expression",
+	RunE: func(cmd *cobra.Command, args []string) error {
+		job, err := jobBodyFromFlags(cmd)
+		if err != nil {
+			return err
+		}
+		name, _ := cmd.Flags().GetString("name")
+		cron, _ := cmd.Flags().GetString("cron")
+		timezone, _ := cmd.Flags().GetString("timezone")
+		overlap, _ := cmd.Flags().GetString("overlap")
+		body := map[string]any{
+			"name":     name,
+			"cron":     cron,
+			"timezone": timezone,
+			"overlap":  overlap,
+			"job":      job,
+		}
+
+		data, err := json.Marshal(body)
+		if err != nil {
+			return fmt.Errorf("encode request: %w", err)
+		}
+		payload, err := scheduleRequest(http.MethodPost, "/schedules", data)
+		if err != nil {
+			return err
+		}
+
+		var sc schedule
+		if err := json.Unmarshal(payload, &sc); err != nil {
+			return fmt.Errorf("parse response: %w", err)
+		}
+		fmt.Printf("Schedule created: %s (next run: %s)\n", sc.ID, sc.NextRunAt.Local().Format("2006-01-02 15:04:05"))
+		return nil
+	},
+}
+
+var scheduleListCmd = &cobra.Command{
+	Use:   "list",
+	Short: "List schedules",
+	RunE: func(cmd *cobra.Command, args []string) error {
+		asJSON, _ := cmd.Flags().GetBool("json")
+
+		payload, err := scheduleRequest(http.MethodGet, "/schedules", nil)
+		if err != nil {
+			return err
+		}
+		if asJSON {
+			fmt.Println(strings.TrimSpace(string(payload)))
+			return nil
+		}
+
+		var body struct {
+			Schedules []schedule `json:"schedules"`
+		}
+		if err := json.Unmarshal(payload, &body); err != nil {
+			return fmt.Errorf("parse response: %w", err)
+		}
+
+		tw := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+		fmt.Fprintln(tw, "ID\tNAME\tCRON\tTIMEZONE\tOVERLAP\tNEXT RUN\tLAST JOB\tCOMMAND")
+		for _, sc := range body.Schedules {
+			fmt.Fprintf(tw, "%s\t%s\t%s\t%s\t%s\t%s\t%s\t%s\n",
+				sc.ID,
+				orDash(sc.Name),
+				sc.Cron,
+				sc.Timezone,
+				sc.Overlap,
+				sc.NextRunAt.Local().Format("2006-01-02 15:04:05"),
+				orDash(sc.LastJobID),
+				truncate(sc.Job.Command, 40),
+			)
+		}
+		return tw.Flush()
+	},
+}
+
+var scheduleGetCmd = &cobra.Command{
+	Use:   "get [scheduleID]",
+	Short: "Show a schedule as JSON",
+	Args:  cobra.ExactArgs(1),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		payload, err := scheduleRequest(http.MethodGet, "/schedules/"+args[0], nil)
+		if err != nil {
+			return err
+		}
+		fmt.Println(strings.TrimSpace(string(payload)))
+		return nil
+	},
+}
+
+var scheduleDeleteCmd = &cobra.Command{
+	Use:   "delete [scheduleID]",
+	Short: "Delete a schedule; jobs it already created are kept",
+	Args:  cobra.ExactArgs(1),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		if _, err := scheduleRequest(http.MethodDelete, "/schedules/"+args[0], nil); err != nil {
+			return err
+		}
+		fmt.Printf("Schedule deleted: %s\n", args[0])
+		return nil
+	},
+}
+
+func scheduleRequest(method, path string, data []byte) ([]byte, error) {
+	base := strings.TrimRight(server, "/")
+	req, err := http.NewRequest(method, base+path, bytes.NewReader(data))
+	if err != nil {
+		return nil, err
+	}
+	if data != nil {
+		req.Header.Set("Content-Type", "application/json")
+	}
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("schedule request failed: %w", err)
+	}
+	defer resp.Body.Close()
+
+	payload, _ := io.ReadAll(resp.Body)
+	if resp.StatusCode >= 300 {
+		return nil, fmt.Errorf("schedule failed (%s): %s", resp.Status, strings.TrimSpace(string(payload)))
+	}
+	return payload, nil
+}
+
+func init() {
+	addJobFlags(scheduleCreateCmd)
+	scheduleCreateCmd.MarkFlagRequired("cmd")
+	scheduleCreateCmd.Flags().String("name", "", "Name to show in listings")
+	scheduleCreateCmd.Flags().String("cron", "", "Cron expression (5 fields, or @hourly, @daily, ...)")
+	scheduleCreateCmd.MarkFlagRequired("cron")
+	scheduleCreateCmd.Flags().String("timezone", "UTC", "IANA timezone the cron expression is evaluated in")
+	scheduleCreateCmd.Flags().String("overlap", "skip", "When the previous job is still active: skip, queue or cancel-previous")
+
+	scheduleListCmd.Flags().Bool("json", false, "Print the raw JSON response")
+
+	scheduleCmd.AddCommand(scheduleCreateCmd, scheduleListCmd, scheduleGetCmd, scheduleDeleteCmd)
+	rootCmd.AddCommand(scheduleCmd)
+}
@@ -0,0 +1,128 @@
+package cmd
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"strings"
+	"text/tabwriter"
+	"time"
+
+	"github.com/spf13/cobra"
+)
+
+var secretCmd = &cobra.Command{
+	Use:   "secret",
+	Short: "Manage secrets that jobs can read as environment variables",
+	Long: `Manage secrets stored encrypted on the server. A job refers to a secret
+by name, e.g. "gpucli submit --secret HF_TOKEN=hf-token"; its value is
+only read when the job starts and is masked in the job's logs.`,
+}
+
+var secretSetCmd = &cobra.Command{
+	Use:   "set [name]",
+	Short: "Create or replace a secret, reading the value from stdin by default",
+	Args:  cobra.ExactArgs(1),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		var value []byte
+		var err error
+		if file, _ := cmd.Flags().GetString("from-file"); file != "" {
+			value, err = os.ReadFile(file)
+		} else {
+			value, err = io.ReadAll(os.Stdin)
+			value = bytes.TrimRight(value, "\r\n")
+		}
+		if err != nil {
+			return fmt.Errorf("read secret value: %w", err)
+		}
+		if len(value) == 0 {
+			return fmt.Errorf("secret value is empty")
+		}
+
+		data, err := json.Marshal(map[string]string{"value": string(value)})
+		if err != nil {
+			return fmt.Errorf("encode request: %w", err)
+		}
+		if _, err := secretRequest(http.MethodPut, "/secrets/"+args[0], data); err != nil {
+			return err
+		}
+		fmt.Printf("Secret stored: %s\n", args[0])
+		return nil
+	},
+}
+
+var secretListCmd = &cobra.Command{
+	Use:   "list",
+	Short: "List secret names",
+	RunE: func(cmd *cobra.Command, args []string) error {
+		payload, err := secretRequest(http.MethodGet, "/secrets", nil)
+		if err != nil {
+			return err
+		}
+		var body struct {
+			Secrets []struct {
+				Name      string    `json:"name"`
+				CreatedAt time.Time `json:"created_at"`
+				UpdatedAt time.Time `json:"updated_at"`
+			} `json:"secrets"`
+		}
+		if err := json.Unmarshal(payload, &body); err != nil {
+			return fmt.Errorf("parse response: %w", err)
+		}
+
+		tw := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+		fmt.Fprintln(tw, "NAME\tCREATED\tUPDATED")
+		for _, s := range body.Secrets {
+			fmt.Fprintf(tw, "%s\t%s\t%s\n",
+				s.Name,
+				s.CreatedAt.Local().Format("2006-01-02 15:04:05"),
+				s.UpdatedAt.Local().Format("2006-01-02 15:04:05"),
+			)
+		}
+		return tw.Flush()
+	},
+}
+
+var secretDeleteCmd = &cobra.Command{
+	Use:   "delete [name]",
+	Short: "Delete a secret; jobs still using it will fail to start",
+	Args:  cobra.ExactArgs(1),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		if _, err := secretRequest(http.MethodDelete, "/secrets/"+args[0], nil); err != nil {
+			return err
+		}
+		fmt.Printf("Secret deleted: %s\n", args[0])
+		return nil
+	},
+}
+
+func secretRequest(method, path string, data []byte) ([]byte, error) {
+	base := strings.TrimRight(server, "/")
+	req, err := http.NewRequest(method, base+path, bytes.NewReader(data))
+	if err != nil {
+		return nil, err
+	}
+	if data != nil {
+		req.Header.Set("Content-Type", "application/json")
+	}
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("secret request failed: %w", err)
+	}
+	defer resp.Body.Close()
+
+	payload, _ := io.ReadAll(resp.Body)
+	if resp.StatusCode >= 300 {
+		return nil, fmt.Errorf("secret failed (%s): %s", resp.Status, strings.TrimSpace(string(payload)))
+	}
+	return payload, nil
+}
+
+func init() {
+	secretSetCmd.Flags().String("from-file", "", "Read the value from this file instead of stdin")
+	secretCmd.AddCommand(secretSetCmd, secretListCmd, secretDeleteCmd)
+	rootCmd.AddCommand(secretCmd)
+}
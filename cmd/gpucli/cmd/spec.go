@@ -0,0 +1,140 @@
+package cmd
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"time"
+
+	"gpu-runner/internal/jobs"
+
+	"github.com/spf13/cobra"
+	"gopkg.in/yaml.v3"
+)
+
+var validateCmd = &cobra.Command{
+	Use:   "validate",
+	Short: "Check a job spec file without submitting it",
+	Long: `Check a job spec file with the same rules the server applies when the
+job is submitted. A spec looks like
+
+  version: v1
+  command: python train.py --epochs 10
+  env:
+    WANDB_MODE: offline
+  resources:
+    storage: 25MB
+    cpu: 4
+    memory: 16GB
+    gpus:
+      count: 2
+      model: A100
+      min_memory: 40GB
+  timeout: 2h
+  retries:
+    max: 2
+    backoff: 30s
+  labels:
+    team: vision
+  priority: 10
+  inputs:
+    data: data/train.csv
+  outputs:
+    model: out/model.pt
+
+argv may replace command to run a program without a shell. JSON files
+use the same field names.`,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		file, _ := cmd.Flags().GetString("file")
+		spec, err := readSpec(file)
+		if err != nil {
+			return err
+		}
+		job, err := spec.Validate(time.Now())
+		if err != nil {
+			return fmt.Errorf("%s: %w", file, err)
+		}
+		kind := "job"
+		if job.Array != nil {
+			kind = fmt.Sprintf("array of %d jobs", job.Array.Size())
+		}
+		fmt.Printf("%s: valid %s spec (%s)\n", file, spec.Version, kind)
+		return nil
+	},
+}
+
+var renderCmd = &cobra.Command{
+	Use:   "render",
+	Short: "Show a job spec with the server defaults filled in",
+	RunE: func(cmd *cobra.Command, args []string) error {
+		file, _ := cmd.Flags().GetString("file")
+		spec, err := readSpec(file)
+		if err != nil {
+			return err
+		}
+		resolved, err := spec.Resolve(time.Now())
+		if err != nil {
+			return fmt.Errorf("%s: %w", file, err)
+		}
+
+		output, _ := cmd.Flags().GetString("output")
+		switch output {
+		case "yaml":
+			enc := yaml.NewEncoder(os.Stdout)
+			enc.SetIndent(2)
+			defer enc.Close()
+			return enc.Encode(resolved)
+		case "json":
+			enc := json.NewEncoder(os.Stdout)
+			enc.SetIndent("", "  ")
+			return enc.Encode(resolved)
+		}
+		return fmt.Errorf("invalid output '%s': must be yaml or json", output)
+	},
+}
+
+// readSpec reads and parses a job spec file, or stdin when file is "-".
+func readSpec(file string) (*jobs.JobSpec, error) {
+	var data []byte
+	var err error
+	if file == "-" {
+		data, err = io.ReadAll(os.Stdin)
+	} else {
+		data, err = os.ReadFile(file)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("read job spec: %w", err)
+	}
+	spec, err := jobs.ParseSpec(data)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", file, err)
+	}
+	return spec, nil
+}
+
+// requestFromSpecFile reads a job spec and checks it as the server would,
+// returning the request to submit.
+func requestFromSpecFile(file string) (jobs.JobRequest, error) {
+	spec, err := readSpec(file)
+	if err != nil {
+		return jobs.JobRequest{}, err
+	}
+	req, err := spec.Request()
+	if err == nil {
+		_, err = req.NewJob(time.Now())
+	}
+	if err != nil {
+		return jobs.JobRequest{}, fmt.Errorf("%s: %w", file, err)
+	}
+	return req, nil
+}
+
+func init() {
+	for _, c := range []*cobra.Command{validateCmd, renderCmd} {
+		c.Flags().StringP("file", "f", "", "Job spec file (YAML or JSON, - for stdin)")
+		c.MarkFlagRequired("file")
+		rootCmd.AddCommand(c)
+	}
+	renderCmd.Flags().StringP("output", "o", "yaml", "Output format: yaml or json")
+}
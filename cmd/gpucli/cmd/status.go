@@ -5,7 +5,10 @@ import (
 	"fmt"
 	"io"
 	"net/http"
+	"net/url"
+	"strconv"
 	"strings"
+	"time"
 
 	"github.com/spf13/cobra"
 )
@@ -32,21 +35,183 @@ var statusCmd = &cobra.Command{
 		}
 
 		var job struct {
-			ID      string `json:"id"`
-			Status  string `json:"status"`
-			Log     string `json:"log"`
-			Command string `json:"command"`
+			ID            string     `json:"id"`
+			Status        string     `json:"status"`
+			Command       string     `json:"command"`
+			Shell         string     `json:"shell"`
+			Workdir       string     `json:"workdir"`
+			Runtime       string     `json:"runtime"`
+			Image         string     `json:"image"`
+			Sandbox       bool       `json:"sandbox"`
+			NoNetwork     bool       `json:"no_network"`
+			Storage       int64      `json:"storage"`
+			VolumePath    string     `json:"volume_path"`
+			CPU           float64    `json:"cpu"`
+			Memory        int64      `json:"memory"`
+			MaxPids       int        `json:"max_pids"`
+			GPUs          *struct {
+				Count     int    `json:"count"`
+				Model     string `json:"model"`
+				MinMemory int64  `json:"min_memory"`
+			} `json:"gpus"`
+			GPUDevices    []int      `json:"gpu_devices"`
+			PeakMemory    int64      `json:"peak_memory_bytes"`
+			CPUSeconds    float64    `json:"cpu_seconds"`
+			CreatedAt     time.Time  `json:"created_at"`
+			StartedAt     *time.Time `json:"started_at"`
+			FinishedAt    *time.Time `json:"finished_at"`
+			ExitCode      *int       `json:"exit_code"`
+			Signal        string     `json:"signal"`
+			TermSignal    string     `json:"termination_signal"`
+			WorkerID      string     `json:"worker_id"`
+			JobTrial      int        `json:"job_trial"`
+			MaxRetries    int        `json:"max_retries"`
+			FailureReason string     `json:"failure_reason"`
+			RunAt         *time.Time `json:"run_at"`
+			WorkflowID    string     `json:"workflow_id"`
+			WorkflowStep  string     `json:"workflow_step"`
+			DependsOn     []struct {
+				JobID     string `json:"job_id"`
+				Condition string `json:"condition"`
+			} `json:"depends_on"`
+			ArrayID     string `json:"array_id"`
+			ArrayIndex  *int   `json:"array_index"`
+			ArrayStatus *struct {
+				Status string         `json:"status"`
+				Size   int            `json:"size"`
+				Counts map[string]int `json:"counts"`
+			} `json:"array_status"`
+			Array *struct {
+				MaxConcurrency int `json:"max_concurrency"`
+			} `json:"array"`
 		}
 
 		if err := json.Unmarshal(payload, &job); err != nil {
 			return fmt.Errorf("parse response: %w", err)
 		}
 
-		fmt.Printf("Job: %s\nCommand: %s\nStatus: %s\nLogs:\n%s\n", job.ID, job.Command, job.Status, job.Log)
+		fmt.Printf("Job: %s\nCommand: %s\nStatus: %s\n", job.ID, job.Command, job.Status)
+		if job.Shell != "" {
+			fmt.Printf("Shell: %s\n", job.Shell)
+		}
+		if job.Workdir != "" {
+			fmt.Printf("Workdir: %s\n", job.Workdir)
+		}
+		if job.Runtime != "" {
+			fmt.Printf("Runtime: %s\n", job.Runtime)
+		}
+		if job.Image != "" {
+			fmt.Printf("Image: %s\n", job.Image)
+		}
+		if job.NoNetwork {
+			fmt.Println("Sandbox: yes, without network")
+		} else if job.Sandbox {
+			fmt.Println("Sandbox: yes")
+		}
+		if job.VolumePath != "" {
+			fmt.Printf("Workspace: %s\n", job.VolumePath)
+		}
+		var limits []string
+		if job.Storage > 0 {
+			limits = append(limits, "storage "+formatBytes(job.Storage))
+		}
+		if job.CPU > 0 {
+			limits = append(limits, fmt.Sprintf("cpu %g", job.CPU))
+		}
+		if job.Memory > 0 {
+			limits = append(limits, "memory "+formatBytes(job.Memory))
+		}
+		if job.MaxPids > 0 {
+			limits = append(limits, fmt.Sprintf("pids %d", job.MaxPids))
+		}
+		if len(limits) > 0 {
+			fmt.Printf("Limits: %s\n", strings.Join(limits, ", "))
+		}
+		if g := job.GPUs; g != nil {
+			fmt.Printf("GPUs: %d", g.Count)
+			if g.Model != "" {
+				fmt.Printf(" %s", g.Model)
+			}
+			if g.MinMemory > 0 {
+				fmt.Printf(", at least %s each", formatBytes(g.MinMemory))
+			}
+			fmt.Println()
+		}
+		if len(job.GPUDevices) > 0 {
+			devices := make([]string, len(job.GPUDevices))
+			for i, d := range job.GPUDevices {
+				devices[i] = strconv.Itoa(d)
+			}
+			fmt.Printf("GPU devices: %s\n", strings.Join(devices, ", "))
+		}
+		fmt.Printf("Attempt: %d (max retries %d)\n", job.JobTrial, job.MaxRetries)
+		fmt.Printf("Created: %s\n", job.CreatedAt.Local().Format(time.RFC3339))
+		if job.WorkflowID != "" {
+			fmt.Printf("Workflow: %s (step %s)\n", job.WorkflowID, job.WorkflowStep)
+		}
+		if job.ArrayID != "" && job.ArrayIndex != nil {
+			fmt.Printf("Array: %s (index %d)\n", job.ArrayID, *job.ArrayIndex)
+		}
+		if a := job.ArrayStatus; a != nil {
+			fmt.Printf("Array jobs: %d (%s)", a.Size, a.Status)
+			if job.Array != nil && job.Array.MaxConcurrency > 0 {
+				fmt.Printf(", at most %d at once", job.Array.MaxConcurrency)
+			}
+			fmt.Println()
+			for _, s := range []string{"blocked", "scheduled", "pending", "running", "success", "failed", "timed_out", "dead", "cancelled"} {
+				if n := a.Counts[s]; n > 0 {
+					fmt.Printf("  %-10s %d\n", s, n)
+				}
+			}
+		}
+		for _, dep := range job.DependsOn {
+			fmt.Printf("Depends on: %s (%s)\n", dep.JobID, dep.Condition)
+		}
+		if job.RunAt != nil {
+			fmt.Printf("Run at: %s\n", job.RunAt.Local().Format(time.RFC3339))
+		}
+		if job.StartedAt != nil {
+			fmt.Printf("Started: %s (queued %s)\n", job.StartedAt.Local().Format(time.RFC3339), job.StartedAt.Sub(job.CreatedAt).Round(time.Millisecond))
+		}
+		if job.FinishedAt != nil && job.StartedAt != nil {
+			fmt.Printf("Finished: %s (ran %s)\n", job.FinishedAt.Local().Format(time.RFC3339), job.FinishedAt.Sub(*job.StartedAt).Round(time.Millisecond))
+		}
+		if job.WorkerID != "" {
+			fmt.Printf("Worker: %s\n", job.WorkerID)
+		}
+		if job.ExitCode != nil {
+			fmt.Printf("Exit code: %d\n", *job.ExitCode)
+		}
+		if job.Signal != "" {
+			fmt.Printf("Signal: %s\n", job.Signal)
+		}
+		if job.TermSignal != "" {
+			fmt.Printf("Stopped with: %s\n", job.TermSignal)
+		}
+		if job.FailureReason != "" {
+			fmt.Printf("Failure reason: %s\n", job.FailureReason)
+		}
+		if job.PeakMemory > 0 || job.CPUSeconds > 0 {
+			fmt.Printf("Usage: peak memory %s, cpu %.1fs\n", formatBytes(job.PeakMemory), job.CPUSeconds)
+		}
+		tail, _ := cmd.Flags().GetInt("tail")
+		if tail <= 0 {
+			return nil
+		}
+		fmt.Println("Logs:")
+
+		records, err := fetchLogs(base+"/jobs/"+jobID+"/logs", url.Values{"tail": {strconv.Itoa(tail)}})
+		if err != nil {
+			return err
+		}
+		for _, rec := range records {
+			printRecord(rec, false)
+		}
 		return nil
 	},
 }
 
 func init() {
+	statusCmd.Flags().Int("tail", 10, "Number of recent log entries to show")
 	rootCmd.AddCommand(statusCmd)
 }
@@ -8,36 +8,71 @@ import (
 	"net/http"
 	"strconv"
 	"strings"
+	"time"
+
+	"gpu-runner/internal/jobs"
 
 	"github.com/spf13/cobra"
+	"github.com/spf13/pflag"
 )
 
 var submitCmd = &cobra.Command{
-	Use:   "submit",
+	Use:   "submit [flags] [-- program args...]",
 	Short: "Submit a GPU job",
+	Long: `Submit a GPU job described by flags, or by a job spec file with -f.
+A spec is checked locally before it is sent; see "gpucli validate".
+
+The job runs either a shell string given with --cmd, or a program and its
+arguments given after --, which run without a shell:
+
+  gpucli submit --cmd 'python train.py --lr 0.1 > log.txt'
+  gpucli submit --workdir exp1 -- python train.py --lr 0.1`,
 	RunE: func(cmd *cobra.Command, args []string) error {
+		if len(args) > 0 && cmd.ArgsLenAtDash() != 0 {
+			return fmt.Errorf("unexpected argument '%s': put the program to run after --", args[0])
+		}
+		file, _ := cmd.Flags().GetString("file")
 		command, _ := cmd.Flags().GetString("cmd")
-		storage, _ := cmd.Flags().GetString("storage")
-		storageInt := 0
-		var err error = nil
-		if len(storage) != 0 {
-			storageInt, err = strconv.Atoi(storage)
-			if err != nil {
-					return fmt.Errorf("invalid storage value '%s': must be an integer", storage)
+		sources := 0
+		for _, set := range []bool{file != "", command != "", len(args) > 0} {
+			if set {
+				sources++
 			}
-		} 
+		}
+		if sources != 1 {
+			return fmt.Errorf("give exactly one of --cmd, -f or a program after --")
+		}
 
-		maxRetriesStr, _ := cmd.Flags().GetString("maxRetries")
-		maxRetries := 0
-		if maxRetriesStr != "" {
-			maxRetries, err = strconv.Atoi(maxRetriesStr)
+		var body any
+		isArray := false
+		if file != "" {
+			var changed []string
+			cmd.Flags().Visit(func(f *pflag.Flag) {
+				if f.Name != "file" {
+					changed = append(changed, "--"+f.Name)
+				}
+			})
+			if len(changed) > 0 {
+				return fmt.Errorf("%s cannot be combined with -f; set them in the spec instead", strings.Join(changed, ", "))
+			}
+			req, err := requestFromSpecFile(file)
 			if err != nil {
-				return fmt.Errorf("invalid maxRetries value '%s': must be an integer", maxRetriesStr)
+				return err
 			}
+			body, isArray = req, req.Array != nil
+		} else {
+			flagBody, err := submitBodyFromFlags(cmd)
+			if err != nil {
+				return err
+			}
+			if len(args) > 0 {
+				delete(flagBody, "command")
+				flagBody["argv"] = args
+			}
+			_, isArray = flagBody["array"]
+			body = flagBody
 		}
 
-		body := map[string]any{"command": command, "storage": storageInt, "max_retries": maxRetries}
-		
 		data, err := json.Marshal(body)
 		if err != nil {
 			return fmt.Errorf("encode request: %w", err)
@@ -63,16 +98,314 @@ var submitCmd = &cobra.Command{
 			return fmt.Errorf("parse response: %w", err)
 		}
 
+		if isArray {
+			fmt.Printf("Array submitted: %s (status: %s)\n", job.ID, job.Status)
+			return nil
+		}
 		fmt.Printf("Job submitted: %s (status: %s)\n", job.ID, job.Status)
 		return nil
 	},
 }
 
+// submitBodyFromFlags builds the POST /jobs body from submit's flags.
+func submitBodyFromFlags(cmd *cobra.Command) (map[string]any, error) {
+	body, err := jobBodyFromFlags(cmd)
+	if err != nil {
+		return nil, err
+	}
+	if runAt, _ := cmd.Flags().GetString("run-at"); runAt != "" {
+		t, err := parseRunAt(runAt)
+		if err != nil {
+			return nil, fmt.Errorf("invalid run-at '%s': %w", runAt, err)
+		}
+		body["run_at"] = t
+	}
+	if deadline, _ := cmd.Flags().GetString("deadline"); deadline != "" {
+		t, err := time.Parse(time.RFC3339, deadline)
+		if err != nil {
+			return nil, fmt.Errorf("invalid deadline '%s': must be RFC3339", deadline)
+		}
+		body["deadline"] = t
+	}
+	if deps, _ := cmd.Flags().GetStringSlice("depends-on"); len(deps) > 0 {
+		dependsOn := make([]map[string]string, 0, len(deps))
+		for _, d := range deps {
+			id, condition, _ := strings.Cut(d, ":")
+			if id == "" {
+				return nil, fmt.Errorf("invalid depends-on '%s': must be jobID or jobID:condition", d)
+			}
+			dependsOn = append(dependsOn, map[string]string{"job_id": id, "condition": condition})
+		}
+		body["depends_on"] = dependsOn
+	}
+	arraySpec, _ := cmd.Flags().GetString("array")
+	params, _ := cmd.Flags().GetStringArray("param")
+	if arraySpec != "" || len(params) > 0 {
+		array, err := parseArray(arraySpec, params)
+		if err != nil {
+			return nil, err
+		}
+		body["array"] = array
+	}
+	return body, nil
+}
+
+// parseArray builds an array spec from --array START-END[%LIMIT] and
+// --param name=v1,v2 flags. With params the range is omitted, so "%LIMIT"
+// alone sets the throttle.
+func parseArray(spec string, params []string) (map[string]any, error) {
+	array := map[string]any{}
+	rng, limit, hasLimit := strings.Cut(spec, "%")
+	if hasLimit {
+		n, err := strconv.Atoi(limit)
+		if err != nil || n < 1 {
+			return nil, fmt.Errorf("invalid array limit '%s': must be a positive number", limit)
+		}
+		array["max_concurrency"] = n
+	}
+	if rng != "" {
+		if len(params) > 0 {
+			return nil, fmt.Errorf("--array takes either an index range or --param values, not both")
+		}
+		from, to, ok := strings.Cut(rng, "-")
+		start, err1 := strconv.Atoi(from)
+		end, err2 := strconv.Atoi(to)
+		if !ok || err1 != nil || err2 != nil {
+			return nil, fmt.Errorf("invalid array range '%s': must be START-END", rng)
+		}
+		array["start"], array["end"] = start, end
+		return array, nil
+	}
+	if len(params) == 0 {
+		return nil, fmt.Errorf("--array needs an index range such as 0-99 or --param values")
+	}
+	grid := map[string][]string{}
+	for _, p := range params {
+		name, values, ok := strings.Cut(p, "=")
+		if !ok || name == "" || values == "" {
+			return nil, fmt.Errorf("invalid param '%s': must be name=v1,v2", p)
+		}
+		grid[name] = append(grid[name], strings.Split(values, ",")...)
+	}
+	array["params"] = grid
+	return array, nil
+}
+
+// parseRunAt accepts an RFC3339 timestamp or a duration from now, so
+// "--run-at 2h" starts the job two hours from now.
+func parseRunAt(v string) (time.Time, error) {
+	if d, err := time.ParseDuration(v); err == nil {
+		return time.Now().Add(d), nil
+	}
+	return time.Parse(time.RFC3339, v)
+}
+
+// jobBodyFromFlags builds a job request from the flags added by
+// addJobFlags.
+func jobBodyFromFlags(cmd *cobra.Command) (map[string]any, error) {
+	command, _ := cmd.Flags().GetString("cmd")
+	storage, _ := cmd.Flags().GetString("storage")
+	var storageBytes jobs.JobStorage
+	if len(storage) != 0 {
+		var err error
+		storageBytes, err = jobs.ParseSize(storage)
+		if err != nil {
+			return nil, fmt.Errorf("invalid storage value '%s': %w", storage, err)
+		}
+	}
+
+	policy, err := retryPolicyFromFlags(cmd)
+	if err != nil {
+		return nil, err
+	}
+
+	labels, _ := cmd.Flags().GetStringSlice("label")
+	labelMap := make(map[string]string, len(labels))
+	for _, l := range labels {
+		key, value, ok := strings.Cut(l, "=")
+		if !ok || key == "" {
+			return nil, fmt.Errorf("invalid label '%s': must be key=value", l)
+		}
+		labelMap[key] = value
+	}
+
+	priority, _ := cmd.Flags().GetInt("priority")
+	body := map[string]any{"command": command, "storage": storageBytes, "labels": labelMap, "priority": priority}
+	for _, flag := range []string{"shell", "workdir", "runtime", "image"} {
+		if v, _ := cmd.Flags().GetString(flag); v != "" {
+			body[flag] = v
+		}
+	}
+	for flag, field := range map[string]string{"sandbox": "sandbox", "no-network": "no_network"} {
+		if v, _ := cmd.Flags().GetBool(flag); v {
+			body[field] = true
+		}
+	}
+	for flag, field := range map[string]string{"env": "env", "secret": "secrets"} {
+		pairs, _ := cmd.Flags().GetStringArray(flag)
+		if len(pairs) == 0 {
+			continue
+		}
+		m := make(map[string]string, len(pairs))
+		for _, p := range pairs {
+			key, value, ok := strings.Cut(p, "=")
+			if !ok || key == "" {
+				return nil, fmt.Errorf("invalid %s '%s': must be NAME=value", flag, p)
+			}
+			m[key] = value
+		}
+		body[field] = m
+	}
+	if len(policy) > 0 {
+		body["retry_policy"] = policy
+	}
+	if cpu, _ := cmd.Flags().GetFloat64("cpu"); cpu != 0 {
+		body["cpu"] = cpu
+	}
+	if memory, _ := cmd.Flags().GetString("memory"); memory != "" {
+		memoryBytes, err := jobs.ParseSize(memory)
+		if err != nil {
+			return nil, fmt.Errorf("invalid memory value '%s': %w", memory, err)
+		}
+		body["memory"] = memoryBytes
+	}
+	if pids, _ := cmd.Flags().GetInt("max-pids"); pids != 0 {
+		body["max_pids"] = pids
+	}
+	gpus, err := gpusFromFlags(cmd)
+	if err != nil {
+		return nil, err
+	}
+	if gpus != nil {
+		body["gpus"] = gpus
+	}
+
+	if timeout, _ := cmd.Flags().GetDuration("timeout"); timeout != 0 {
+		if timeout < time.Second {
+			return nil, fmt.Errorf("invalid timeout '%s': must be at least 1s", timeout)
+		}
+		body["timeout_seconds"] = int64(timeout / time.Second)
+	}
+	return body, nil
+}
+
+// gpusFromFlags builds the gpus request field. The model and memory flags
+// only narrow a --gpus request.
+func gpusFromFlags(cmd *cobra.Command) (map[string]any, error) {
+	flags := cmd.Flags()
+	count, _ := flags.GetInt("gpus")
+	model, _ := flags.GetString("gpu-model")
+	memory, _ := flags.GetString("gpu-memory")
+	if count == 0 {
+		if model != "" || memory != "" {
+			return nil, fmt.Errorf("--gpu-model and --gpu-memory need --gpus")
+		}
+		return nil, nil
+	}
+	gpus := map[string]any{"count": count}
+	if model != "" {
+		gpus["model"] = model
+	}
+	if memory != "" {
+		memoryBytes, err := jobs.ParseSize(memory)
+		if err != nil {
+			return nil, fmt.Errorf("invalid gpu-memory value '%s': %w", memory, err)
+		}
+		gpus["min_memory"] = memoryBytes
+	}
+	return gpus, nil
+}
+
+// retryPolicyFromFlags builds the retry_policy request field from whichever
+// retry flags were set, leaving the rest to the server defaults.
+func retryPolicyFromFlags(cmd *cobra.Command) (map[string]any, error) {
+	policy := map[string]any{}
+	flags := cmd.Flags()
+
+	if flags.Changed("retries") {
+		retries, _ := flags.GetInt("retries")
+		policy["max_retries"] = retries
+	} else if raw, _ := flags.GetString("maxRetries"); raw != "" {
+		retries, err := strconv.Atoi(raw)
+		if err != nil {
+			return nil, fmt.Errorf("invalid maxRetries value '%s': must be an integer", raw)
+		}
+		policy["max_retries"] = retries
+	}
+	for flag, field := range map[string]string{
+		"backoff":     "initial_backoff_seconds",
+		"max-backoff": "max_backoff_seconds",
+	} {
+		if flags.Changed(flag) {
+			d, _ := flags.GetDuration(flag)
+			policy[field] = d.Seconds()
+		}
+	}
+	if flags.Changed("jitter") {
+		jitter, _ := flags.GetFloat64("jitter")
+		policy["jitter"] = jitter
+	}
+	for flag, field := range map[string]string{
+		"retry-on":    "retry_on",
+		"no-retry-on": "no_retry_on",
+	} {
+		if reasons, _ := flags.GetStringSlice(flag); len(reasons) > 0 {
+			policy[field] = reasons
+		}
+	}
+	for flag, field := range map[string]string{
+		"retry-on-exit":    "retry_on_exit_codes",
+		"no-retry-on-exit": "no_retry_on_exit_codes",
+	} {
+		if codes, _ := flags.GetIntSlice(flag); len(codes) > 0 {
+			policy[field] = codes
+		}
+	}
+	return policy, nil
+}
+
+// addJobFlags registers the flags describing a job, shared by submit and
+// schedule create.
+func addJobFlags(cmd *cobra.Command) {
+	cmd.Flags().String("cmd", "", "Command to run")
+	cmd.Flags().String("shell", "", "Shell that runs --cmd (server default bash)")
+	cmd.Flags().String("workdir", "", "Working directory, relative to the job's volume or absolute")
+	cmd.Flags().String("runtime", "", "Runtime that runs the job (server default local)")
+	cmd.Flags().String("image", "", "Container image to run the job in; implies --runtime container")
+	cmd.Flags().Bool("sandbox", false, "Isolate the job from the host: read-only root, own process table, unprivileged user")
+	cmd.Flags().Bool("no-network", false, "Run the job without network access; implies --sandbox")
+	cmd.Flags().String("storage", "", "Disk quota for the job's workspace, in bytes or with a unit such as 25MB (server default 1GB)")
+	cmd.Flags().Float64("cpu", 0, "CPU limit in cores, e.g. 2 or 0.5")
+	cmd.Flags().String("memory", "", "Memory limit, e.g. 4GB; the job is OOM-killed above it")
+	cmd.Flags().Int("max-pids", 0, "Limit on the number of processes and threads the job may run")
+	cmd.Flags().Int("gpus", 0, "Number of GPUs the job needs; it waits in the queue until they are free")
+	cmd.Flags().String("gpu-model", "", "Only use GPUs whose model contains this, e.g. A100")
+	cmd.Flags().String("gpu-memory", "", "Only use GPUs with at least this much memory, e.g. 40GB")
+	cmd.Flags().Int("retries", 0, "Retries after the first attempt (0 disables retries; server default 3)")
+	cmd.Flags().Duration("backoff", 0, "Delay before the first retry, doubled on each retry")
+	cmd.Flags().Duration("max-backoff", 0, "Upper bound on the retry delay")
+	cmd.Flags().Float64("jitter", 0, "Randomise each retry delay by up to this fraction (0-1)")
+	cmd.Flags().StringSlice("retry-on", nil, "Only retry these failure reasons (exit_code, signal, timeout, oom_killed, disk_quota, preempted, error)")
+	cmd.Flags().StringSlice("no-retry-on", nil, "Never retry these failure reasons")
+	cmd.Flags().IntSlice("retry-on-exit", nil, "Only retry these exit codes")
+	cmd.Flags().IntSlice("no-retry-on-exit", nil, "Never retry these exit codes")
+	cmd.Flags().StringSlice("label", nil, "Label to attach (key=value, repeatable)")
+	cmd.Flags().StringArray("env", nil, "Environment variable for the job (NAME=value, repeatable)")
+	cmd.Flags().StringArray("secret", nil, "Expose a stored secret as an environment variable (NAME=secret-name, repeatable)")
+	cmd.Flags().Int("priority", 0, "Queue priority (-1000 to 1000); higher runs sooner")
+	cmd.Flags().Duration("timeout", 0, "Maximum run time, e.g. 90m or 6h (server default when unset)")
+}
+
 func init() {
-	submitCmd.Flags().String("cmd", "", "Command to run")
-	submitCmd.MarkFlagRequired("cmd")
-	submitCmd.Flags().String("storage", "", "Storage for Job")
+	addJobFlags(submitCmd)
+	submitCmd.Flags().StringP("file", "f", "", "Job spec file (YAML or JSON, - for stdin)")
 	submitCmd.Flags().String("maxRetries", "", "Attempts running a job")
+	submitCmd.Flags().MarkDeprecated("maxRetries", "use --retries")
+	submitCmd.Flags().String("deadline", "", "Absolute time (RFC3339) by which the job must finish")
+	submitCmd.Flags().String("run-at", "", "Hold the job until this time (RFC3339 or duration from now such as 30m)")
+	submitCmd.Flags().StringSlice("depends-on", nil, "Wait for another job: jobID or jobID:condition (on_success, on_completion, on_failure; repeatable)")
+	submitCmd.Flags().String("array", "", "Submit an array: START-END with an optional %LIMIT on concurrent jobs, e.g. 0-99%10")
+	submitCmd.Flags().StringArray("param", nil, "Sweep a parameter over values: name=v1,v2 (repeatable; one job per combination)")
 
 	rootCmd.AddCommand(submitCmd)
 }
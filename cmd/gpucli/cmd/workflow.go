@@ -0,0 +1,157 @@
+package cmd
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"sort"
+	"strings"
+	"text/tabwriter"
+
+	"github.com/spf13/cobra"
+)
+
+type workflow struct {
+	ID     string         `json:"id"`
+	Name   string         `json:"name"`
+	Status string         `json:"status"`
+	Counts map[string]int `json:"counts"`
+	Jobs   []struct {
+		ID           string `json:"id"`
+		Status       string `json:"status"`
+		Command      string `json:"command"`
+		WorkflowStep string `json:"workflow_step"`
+		DependsOn    []struct {
+			JobID     string `json:"job_id"`
+			Condition string `json:"condition"`
+		} `json:"depends_on"`
+	} `json:"jobs"`
+}
+
+var workflowCmd = &cobra.Command{
+	Use:   "workflow",
+	Short: "Submit and inspect DAGs of dependent jobs",
+}
+
+var workflowCreateCmd = &cobra.Command{
+	Use:   "create",
+	Short: "Submit a workflow from a JSON file",
+	Long: `Submit a workflow from a JSON file of the form
+
+  {"name": "nightly", "steps": [
+    {"name": "preprocess", "job": {"command": "..."}},
+    {"name": "train", "job": {"command": "..."}, "depends_on": [{"step": "preprocess"}]},
+    {"name": "alert", "job": {"command": "..."}, "depends_on": [{"step": "train", "condition": "on_failure"}]}
+  ]}`,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		file, _ := cmd.Flags().GetString("file")
+		var data []byte
+		var err error
+		if file == "-" {
+			data, err = io.ReadAll(os.Stdin)
+		} else {
+			data, err = os.ReadFile(file)
+		}
+		if err != nil {
+			return fmt.Errorf("read workflow: %w", err)
+		}
+		if !json.Valid(data) {
+			return fmt.Errorf("workflow file '%s' is not valid JSON", file)
+		}
+
+		payload, err := workflowRequest(http.MethodPost, "/workflows", data)
+		if err != nil {
+			return err
+		}
+		var wf workflow
+		if err := json.Unmarshal(payload, &wf); err != nil {
+			return fmt.Errorf("parse response: %w", err)
+		}
+		fmt.Printf("Workflow submitted: %s (%d jobs)\n", wf.ID, len(wf.Jobs))
+		return printWorkflowJobs(wf)
+	},
+}
+
+var workflowStatusCmd = &cobra.Command{
+	Use:   "status [workflowID]",
+	Short: "Show a workflow's status and its jobs",
+	Args:  cobra.ExactArgs(1),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		payload, err := workflowRequest(http.MethodGet, "/workflows/"+args[0], nil)
+		if err != nil {
+			return err
+		}
+		var wf workflow
+		if err := json.Unmarshal(payload, &wf); err != nil {
+			return fmt.Errorf("parse response: %w", err)
+		}
+
+		fmt.Printf("Workflow: %s", wf.ID)
+		if wf.Name != "" {
+			fmt.Printf(" (%s)", wf.Name)
+		}
+		fmt.Printf("\nStatus: %s\n", wf.Status)
+		statuses := make([]string, 0, len(wf.Counts))
+		for status := range wf.Counts {
+			statuses = append(statuses, status)
+		}
+		sort.Strings(statuses)
+		for i, status := range statuses {
+			statuses[i] = fmt.Sprintf("%s=%d", status, wf.Counts[status])
+		}
+		fmt.Printf("Jobs: %s\n", strings.Join(statuses, " "))
+		return printWorkflowJobs(wf)
+	},
+}
+
+func printWorkflowJobs(wf workflow) error {
+	tw := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(tw, "STEP\tJOB\tSTATUS\tDEPENDS ON\tCOMMAND")
+	for _, j := range wf.Jobs {
+		deps := make([]string, 0, len(j.DependsOn))
+		for _, d := range j.DependsOn {
+			deps = append(deps, d.JobID+":"+d.Condition)
+		}
+		fmt.Fprintf(tw, "%s\t%s\t%s\t%s\t%s\n",
+			j.WorkflowStep,
+			j.ID,
+			j.Status,
+			orDash(strings.Join(deps, ",")),
+			truncate(j.Command, 40),
+		)
+	}
+	return tw.Flush()
+}
+
+func workflowRequest(method, path string, data []byte) ([]byte, error) {
+	base := strings.TrimRight(server, "/")
+	req, err := http.NewRequest(method, base+path, bytes.NewReader(data))
+	if err != nil {
+		return nil, err
+	}
+	if data != nil {
+		req.Header.Set("Content-Type", "application/json")
+	}
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("workflow request failed: %w", err)
+	}
+	defer resp.Body.Close()
+
+	payload, _ := io.ReadAll(resp.Body)
+	if resp.StatusCode >= 300 {
+		return nil, fmt.Errorf("workflow failed (%s): %s", resp.Status, strings.TrimSpace(string(payload)))
+	}
+	return payload, nil
+}
+
+func init() {
+	workflowCreateCmd.Flags().StringP("file", "f", "", "Workflow definition (JSON), or - for stdin")
+	workflowCreateCmd.MarkFlagRequired("file")
+
+	workflowCmd.AddCommand(workflowCreateCmd, workflowStatusCmd)
+	rootCmd.AddCommand(workflowCmd)
+}
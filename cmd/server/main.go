@@ -4,20 +4,41 @@ import (
 	"context"
 	"gpu-runner/internal/api"
 	"gpu-runner/internal/executer"
+	"gpu-runner/internal/gpu"
 	"gpu-runner/internal/jobs"
 	"gpu-runner/internal/logger"
 	"gpu-runner/internal/redis"
 	"gpu-runner/internal/store"
+	"gpu-runner/internal/workspace"
 	"log"
 	"net/http"
+	"os"
+	"os/exec"
+	"strconv"
+	"strings"
+	"time"
 )
 
 var serverLogger = logger.Server
 
 
 func main() {
+    if executer.IsSandboxInit() {
+        executer.SandboxInit()
+    }
     serverLogger.Info("Starting GPU Runner server")
 
+    jobs.DefaultTimeout = durationFromEnv("GPU_RUNNER_DEFAULT_TIMEOUT", jobs.DefaultTimeout)
+    jobs.MaxTimeout = durationFromEnv("GPU_RUNNER_MAX_TIMEOUT", jobs.MaxTimeout)
+    serverLogger.Info("Job timeout limits configured", "default", jobs.DefaultTimeout, "max", jobs.MaxTimeout)
+
+    redis.LeaseDuration = durationFromEnv("GPU_RUNNER_LEASE_DURATION", redis.LeaseDuration)
+    jobs.HeartbeatInterval = redis.LeaseDuration / 3
+    serverLogger.Info("Job lease configured", "lease", redis.LeaseDuration, "heartbeat", jobs.HeartbeatInterval)
+
+    redis.PriorityAgingStep = durationFromEnv("GPU_RUNNER_PRIORITY_AGING", redis.PriorityAgingStep)
+    serverLogger.Info("Queue priority aging configured", "step", redis.PriorityAgingStep)
+
     serverLogger.Info("Initializing Redis client")
     client, err := redis.New()
     if err != nil {
@@ -29,11 +50,41 @@ func main() {
     streamSink := redis.NewStreamSink(client)
     serverLogger.Info("Stream sink created")
 
-    jobQueue := jobs.NewJobQueue(10)
-    serverLogger.Info("Job queue created", "capacity", 10)
+    // Jobs are handed straight to a free worker rather than buffered, so
+    // none waits between the adapter's lease heartbeat and a worker's.
+    jobQueue := jobs.NewJobQueue(0)
+    serverLogger.Info("Job queue created")
+
+    if parent := os.Getenv("GPU_RUNNER_CGROUP_PARENT"); parent != "" {
+        executer.CgroupParent = parent
+    }
+    defaultRuntime := os.Getenv("GPU_RUNNER_DEFAULT_RUNTIME")
+    if defaultRuntime == "" {
+        defaultRuntime = executer.LocalRuntime
+    }
+    runtimes := executer.NewRegistry(defaultRuntime)
+    runtimes.Register(executer.LocalRuntime, executer.NewLocal())
+    if os.Getenv("GPU_RUNNER_FAKE_RUNTIME") != "" {
+        // Jobs on the fake runtime run nothing and succeed; handy for
+        // exercising the queue without a GPU.
+        runtimes.Register(executer.FakeRuntime, executer.NewFake())
+    }
+    if binary := containerBinary(); binary != "" {
+        container, err := executer.NewContainer(binary, os.Getenv("GPU_RUNNER_CONTAINER_HOST"))
+        if err != nil {
+            log.Fatalf("Invalid container runtime: %v", err)
+        }
+        runtimes.Register(executer.ContainerRuntime, container)
+    } else {
+        serverLogger.Info("No container CLI found; jobs with an image will be rejected")
+    }
+    if !runtimes.Has(defaultRuntime) {
+        log.Fatalf("Default runtime %q is not available (have: %s)", defaultRuntime, strings.Join(runtimes.Names(), ", "))
+    }
+    jobQueue.Executor = runtimes
+    serverLogger.Info("Job executor created", "runtimes", runtimes.Names(), "default", defaultRuntime)
 
-    jobQueue.Executor = executer.NewExecutor()
-    serverLogger.Info("Job executor created")
+    jobQueue.Leases = client
 
     serverLogger.Info("Initializing job store database", "path", "/Users/itaischwarz/projects/gpu-runner/jobs.db")
     js, err := store.NewJobStore("/Users/itaischwarz/projects/gpu-runner/jobs.db")
@@ -41,9 +92,89 @@ func main() {
         serverLogger.Error("Failed to create job store", "error", err)
         log.Fatalf("Unable to create job store: %v", err)
     }
+    jobQueue.Statuses = js
+
+    executer.InheritedEnv = listFromEnv("GPU_RUNNER_INHERIT_ENV", executer.InheritedEnv)
+    if shell := os.Getenv("GPU_RUNNER_SHELL"); shell != "" {
+        executer.DefaultShell = shell
+    }
+    executer.KillGracePeriod = durationFromEnv("GPU_RUNNER_KILL_GRACE", executer.KillGracePeriod)
+    serverLogger.Info("Job environment configured", "kill_grace", executer.KillGracePeriod,
+        "inherited", executer.InheritedEnv, "default_shell", executer.DefaultShell)
+
+    if raw := os.Getenv("GPU_RUNNER_SANDBOX"); raw != "" {
+        executer.Sandboxing, err = executer.ParseSandboxPolicy(raw)
+        if err != nil {
+            log.Fatalf("Invalid sandbox policy: %v", err)
+        }
+    }
+    if executer.Sandboxing != executer.SandboxDisabled {
+        if err := executer.CheckSandbox(); err != nil {
+            if executer.Sandboxing == executer.SandboxRequired {
+                serverLogger.Error("Job sandbox unavailable", "error", err)
+                log.Fatalf("Sandboxing is required but unavailable: %v", err)
+            }
+            serverLogger.Warn("Job sandbox unavailable; sandboxed jobs will be rejected", "error", err)
+            executer.Sandboxing = executer.SandboxDisabled
+        }
+    }
+    serverLogger.Info("Job sandbox configured", "policy", executer.Sandboxing, "host_uid", executer.SandboxIDBase+executer.SandboxUID)
+
+    keyFile := os.Getenv("GPU_RUNNER_SECRET_KEY_FILE")
+    if keyFile == "" {
+        keyFile = "/Users/itaischwarz/projects/gpu-runner/secrets.key"
+    }
+    serverLogger.Info("Opening secret store", "key_file", keyFile)
+    secrets, err := store.NewSecretStore(js.DB, keyFile)
+    if err != nil {
+        serverLogger.Error("Failed to open secret store", "error", err)
+        log.Fatalf("Unable to open secret store: %v", err)
+    }
+    jobQueue.Secrets = secrets
+
+    jobs.DefaultStorage = sizeFromEnv("GPU_RUNNER_DEFAULT_STORAGE", jobs.DefaultStorage)
+    jobs.MaxStorage = sizeFromEnv("GPU_RUNNER_MAX_STORAGE", jobs.MaxStorage)
+    workspace.ScanInterval = durationFromEnv("GPU_RUNNER_WORKSPACE_SCAN_INTERVAL", workspace.ScanInterval)
+    workspaceRoot := os.Getenv("GPU_RUNNER_WORKSPACE_ROOT")
+    if workspaceRoot == "" {
+        workspaceRoot = "/var/lib/jobrunner/workspaces"
+    }
+    workspaceMode := workspace.ModeDir
+    if raw := os.Getenv("GPU_RUNNER_WORKSPACE_MODE"); raw != "" {
+        workspaceMode, err = workspace.ParseMode(raw)
+        if err != nil {
+            log.Fatalf("Invalid workspace mode: %v", err)
+        }
+    }
+    retention := workspace.Retention{
+        Success: retentionFromEnv("GPU_RUNNER_WORKSPACE_RETAIN_SUCCESS", 24*time.Hour),
+        Failure: retentionFromEnv("GPU_RUNNER_WORKSPACE_RETAIN_FAILURE", 72*time.Hour),
+    }
+    serverLogger.Info("Job storage configured", "default", jobs.FormatSize(jobs.DefaultStorage), "max", jobs.FormatSize(jobs.MaxStorage))
+    workspaces, err := workspace.NewManager(workspaceRoot, workspaceMode, retention)
+    if err != nil {
+        serverLogger.Error("Failed to set up job workspaces", "error", err, "root", workspaceRoot)
+        log.Fatalf("Unable to set up job workspaces: %v", err)
+    }
+    jobQueue.Workspaces = workspaces
+
+    if provider := gpuProvider(); provider != nil {
+        allocator, err := gpu.NewAllocator(provider)
+        if err != nil {
+            serverLogger.Error("Failed to read GPU inventory", "error", err)
+            log.Fatalf("Unable to read GPU inventory: %v", err)
+        }
+        jobQueue.GPUs = allocator
+        serverLogger.Info("GPU allocation enabled", "devices", len(allocator.Devices()))
+    } else {
+        serverLogger.Info("No GPUs found; jobs that ask for GPUs will be rejected")
+    }
 
     ctx := context.Background()
 
+    workspaces.StartJanitor(ctx, time.Minute)
+    serverLogger.Info("Workspace janitor started")
+
     serverLogger.Info("Starting Redis adapter")
     if err := client.StartRedisAdapter(ctx, jobQueue, streamSink); err != nil {
         serverLogger.Error("Failed to start Redis adapter", "error", err)
@@ -53,7 +184,13 @@ func main() {
     results := make(chan *jobs.Job, 100)
     serverLogger.Info("Created results channel", "buffer_size", 100)
 
+    // Every GPU can be busy with a job of its own while CPU-only jobs
+    // still get a worker.
     numWorkers := 3
+    if jobQueue.GPUs != nil {
+        numWorkers = max(numWorkers, len(jobQueue.GPUs.Devices())+1)
+    }
+    numWorkers = countFromEnv("GPU_RUNNER_WORKERS", numWorkers)
     serverLogger.Info("Starting workers", "count", numWorkers)
     for i := 1; i <= numWorkers; i++ {
         worker := jobs.NewWorker(i, jobQueue, results)
@@ -62,11 +199,22 @@ func main() {
     serverLogger.Info("All workers started successfully")
 
     handlers := api.NewHandlers(jobQueue, js, ctx, streamSink, client)
+    handlers.Secrets = secrets
+    handlers.Runtimes = runtimes
     serverLogger.Info("API handlers initialized")
 
     handlers.StartRedisAcknowledger(ctx, results)
     serverLogger.Info("Redis acknowledger started")
 
+    client.StartDelayedPromoter(ctx, time.Second, handlers.PromoteScheduled)
+    serverLogger.Info("Delayed job promoter started")
+
+    client.StartLeaseReaper(ctx, jobs.HeartbeatInterval, handlers.MarkLost)
+    serverLogger.Info("Lease reaper started")
+
+    handlers.StartScheduler(ctx, time.Second)
+    serverLogger.Info("Scheduler started")
+
     router := api.NewRouter(handlers)
     serverLogger.Info("HTTP router configured")
 
@@ -77,3 +225,119 @@ func main() {
         log.Fatal(err)
     }
 }
+
+// containerBinary picks the container CLI: GPU_RUNNER_CONTAINER_RUNTIME,
+// or the first of docker, podman and nerdctl found on PATH. Setting the
+// variable to "none" disables the container runtime.
+func containerBinary() string {
+    switch binary := os.Getenv("GPU_RUNNER_CONTAINER_RUNTIME"); binary {
+    case "none":
+        return ""
+    case "":
+    default:
+        return binary
+    }
+    for _, binary := range []string{"docker", "podman", "nerdctl"} {
+        if _, err := exec.LookPath(binary); err == nil {
+            return binary
+        }
+    }
+    return ""
+}
+
+// gpuProvider picks where the GPU inventory comes from: the devices
+// GPU_RUNNER_FAKE_GPUS describes (see gpu.ParseFake), or NVML when
+// nvidia-smi is on PATH. Nil means the server has no GPUs.
+func gpuProvider() gpu.Provider {
+    if spec := os.Getenv("GPU_RUNNER_FAKE_GPUS"); spec != "" {
+        fake, err := gpu.ParseFake(spec)
+        if err != nil {
+            log.Fatalf("Invalid fake GPUs: %v", err)
+        }
+        return fake
+    }
+    nvml, err := gpu.NewNVML("nvidia-smi")
+    if err != nil {
+        return nil
+    }
+    return nvml
+}
+
+// countFromEnv reads a positive integer from the environment, keeping def
+// when the variable is unset or invalid.
+func countFromEnv(name string, def int) int {
+    raw := os.Getenv(name)
+    if raw == "" {
+        return def
+    }
+    n, err := strconv.Atoi(raw)
+    if err != nil || n <= 0 {
+        serverLogger.Warn("Ignoring invalid count setting", "name", name, "value", raw)
+        return def
+    }
+    return n
+}
+
+// listFromEnv reads a comma-separated list from the environment, keeping
+// def when the variable is unset. An empty value means an empty list.
+func listFromEnv(name string, def []string) []string {
+    raw, ok := os.LookupEnv(name)
+    if !ok {
+        return def
+    }
+    var list []string
+    for _, item := range strings.Split(raw, ",") {
+        if item = strings.TrimSpace(item); item != "" {
+            list = append(list, item)
+        }
+    }
+    return list
+}
+
+// sizeFromEnv reads a jobs.ParseSize value from the environment, keeping
+// def when the variable is unset or invalid.
+func sizeFromEnv(name string, def jobs.JobStorage) jobs.JobStorage {
+    raw := os.Getenv(name)
+    if raw == "" {
+        return def
+    }
+    size, err := jobs.ParseSize(raw)
+    if err != nil || size <= 0 {
+        serverLogger.Warn("Ignoring invalid size setting", "name", name, "value", raw)
+        return def
+    }
+    return size
+}
+
+// retentionFromEnv reads a workspace retention period: a duration, 0 to
+// remove workspaces at once, or "forever" to keep them.
+func retentionFromEnv(name string, def time.Duration) time.Duration {
+    raw := os.Getenv(name)
+    switch raw {
+    case "":
+        return def
+    case "forever":
+        return -1
+    }
+    d, err := time.ParseDuration(raw)
+    if err != nil || d < 0 {
+        serverLogger.Warn("Ignoring invalid retention setting", "name", name, "value", raw)
+        return def
+    }
+    return d
+}
+
+// durationFromEnv reads a time.ParseDuration value from the environment,
+// keeping def when the variable is unset or invalid.
+func durationFromEnv(name string, def time.Duration) time.Duration {
+    raw := os.Getenv(name)
+    if raw == "" {
+        return def
+    }
+    d, err := time.ParseDuration(raw)
+    if err != nil || d <= 0 {
+        serverLogger.Warn("Ignoring invalid duration setting", "name", name, "value", raw)
+        return def
+    }
+    return d
+}
@@ -0,0 +1,110 @@
+package api
+
+import (
+	"gpu-runner/internal/jobs"
+	"gpu-runner/internal/logger"
+)
+
+// jobView is a job as returned by GET /jobs/{id}. Array parents carry a
+// summary of their children.
+type jobView struct {
+	*jobs.Job
+	ArrayStatus *arraySummary `json:"array_status,omitempty"`
+}
+
+type arraySummary struct {
+	Status jobs.JobStatus         `json:"status"`
+	Size   int                    `json:"size"`
+	Counts map[jobs.JobStatus]int `json:"counts"`
+}
+
+func (h *Handlers) arraySummary(parent *jobs.Job) (*arraySummary, error) {
+	counts, err := h.JobStore.ArrayCounts(parent.ID)
+	if err != nil {
+		return nil, err
+	}
+	return &arraySummary{
+		Status: jobs.AggregateStatus(counts),
+		Size:   parent.Array.Size(),
+		Counts: counts,
+	}, nil
+}
+
+// submitArray stores an array's children and queues those the throttle
+// allows. The parent has already been stored and is never queued itself.
+func (h *Handlers) submitArray(parent *jobs.Job) error {
+	children := parent.ArrayChildren()
+	ServerLogger.Info("Expanding job array", "job_id", parent.ID, "size", len(children), "max_concurrency", parent.Array.MaxConcurrency)
+	parent.Logger.Info("Expanding job array", logger.Item("size", len(children)), logger.Item("max_concurrency", parent.Array.MaxConcurrency))
+
+	for _, child := range children {
+		if err := h.JobStore.CreateJob(child); err != nil {
+			ServerLogger.Error("Failed to create array job in database", "error", err, "array_id", parent.ID, "array_index", *child.ArrayIndex)
+			return errCreateJob
+		}
+		child.Logger = logger.NewJobLogger(h.ctx, child.ID, h.StreamSink)
+		child.Logger.Info("Successfully created job!", logger.Item("array_id", parent.ID), logger.Item("array_index", *child.ArrayIndex))
+		if child.Status == jobs.StatusBlocked {
+			continue
+		}
+		if err := h.enqueueJob(child); err != nil {
+			return err
+		}
+	}
+
+	ServerLogger.Info("Job array submitted", "job_id", parent.ID, "size", len(children))
+	return nil
+}
+
+// advanceArray releases throttled children into the free slots and records
+// the parent's summarised status. It runs whenever a child changes status.
+func (h *Handlers) advanceArray(arrayID string) {
+	h.arrays.Lock()
+	defer h.arrays.Unlock()
+
+	parent, err := h.JobStore.GetJob(arrayID)
+	if err != nil || parent.Array == nil || parent.Status == jobs.StatusCancelled {
+		return
+	}
+	counts, err := h.JobStore.ArrayCounts(arrayID)
+	if err != nil {
+		return
+	}
+
+	if limit := parent.Array.MaxConcurrency; limit > 0 && counts[jobs.StatusBlocked] > 0 {
+		active := counts[jobs.StatusPending] + counts[jobs.StatusScheduled] + counts[jobs.StatusRunning]
+		if free := limit - active; free > 0 {
+			next, err := h.JobStore.ArrayJobs(arrayID, free, jobs.StatusBlocked)
+			if err == nil {
+				ServerLogger.Info("Releasing throttled array jobs", "array_id", arrayID, "count", len(next), "active", active, "max_concurrency", limit)
+				for _, child := range next {
+					h.releaseJob(child)
+					counts[jobs.StatusBlocked]--
+					counts[child.Status]++
+				}
+			}
+		}
+	}
+
+	if status := jobs.AggregateStatus(counts); status != parent.Status {
+		ServerLogger.Info("Array status changed", "array_id", arrayID, "from", parent.Status, "to", status)
+		if h.JobStore.SetArrayStatus(arrayID, status) == nil && status.IsTerminal() {
+			parent.Status = status
+			h.resolveDependents(parent)
+		}
+	}
+}
+
+// cancelArrayChildren cancels every child of a cancelled array that has
+// not finished yet.
+func (h *Handlers) cancelArrayChildren(parent *jobs.Job, reason string) {
+	children, err := h.JobStore.ArrayJobs(parent.ID, 0,
+		jobs.StatusBlocked, jobs.StatusPending, jobs.StatusScheduled, jobs.StatusRunning)
+	if err != nil {
+		return
+	}
+	ServerLogger.Info("Cancelling array jobs", "array_id", parent.ID, "count", len(children))
+	for _, child := range children {
+		h.cancelJob(child.ID, "array "+parent.ID+" cancelled: "+reason)
+	}
+}
@@ -0,0 +1,91 @@
+package api
+
+import (
+	"fmt"
+	"time"
+
+	"gpu-runner/internal/jobs"
+	"gpu-runner/internal/logger"
+)
+
+// checkDependencies rejects a new job that depends on a job that doesn't
+// exist.
+func (h *Handlers) checkDependencies(job *jobs.Job) error {
+	for _, dep := range job.DependsOn {
+		if _, err := h.JobStore.GetJob(dep.JobID); err != nil {
+			return fmt.Errorf("depends_on: job %s not found", dep.JobID)
+		}
+	}
+	return nil
+}
+
+// resolveDependents re-checks the blocked jobs waiting on parent, which has
+// just reached a terminal status.
+func (h *Handlers) resolveDependents(parent *jobs.Job) {
+	dependents, err := h.JobStore.BlockedDependents(parent.ID)
+	if err != nil {
+		return
+	}
+	for _, job := range dependents {
+		ServerLogger.Info("Resolving dependent job", "job_id", job.ID, "parent_id", parent.ID, "parent_status", parent.Status)
+		h.resolveBlocked(job)
+	}
+}
+
+// resolveBlocked checks a blocked job's dependencies. As soon as one can
+// no longer be met the job is cancelled, which cascades to its own
+// dependents; once all of them are met it is queued.
+func (h *Handlers) resolveBlocked(job *jobs.Job) {
+	waiting := false
+	for _, dep := range job.DependsOn {
+		parent, err := h.JobStore.GetJob(dep.JobID)
+		if err != nil {
+			h.cancelBlocked(job, fmt.Sprintf("dependency %s no longer exists", dep.JobID))
+			return
+		}
+		if !parent.Status.IsTerminal() {
+			waiting = true
+			continue
+		}
+		if !dep.SatisfiedBy(parent.Status) {
+			h.cancelBlocked(job, fmt.Sprintf("dependency %s ended %s, needed %s", parent.ID, parent.Status, dep.Condition))
+			return
+		}
+	}
+	if waiting {
+		return
+	}
+
+	ServerLogger.Info("Dependencies met", "job_id", job.ID)
+	h.releaseJob(job)
+}
+
+// releaseJob queues a blocked job, or holds it until its run_at. It does
+// nothing if another caller released the job first.
+func (h *Handlers) releaseJob(job *jobs.Job) {
+	status := jobs.StatusPending
+	if job.RunAt != nil && job.RunAt.After(time.Now()) {
+		status = jobs.StatusScheduled
+	}
+	released, err := h.JobStore.ReleaseBlocked(job.ID, status)
+	if err != nil || !released {
+		return
+	}
+	job.Status = status
+	ServerLogger.Info("Releasing blocked job", "job_id", job.ID, "status", status)
+
+	if job.Logger == nil {
+		job.Logger = logger.NewJobLogger(h.ctx, job.ID, h.StreamSink)
+	}
+	job.Logger.Info("Job released", logger.Item("status", status))
+	h.enqueueJob(job)
+}
+
+func (h *Handlers) cancelBlocked(job *jobs.Job, reason string) {
+	ServerLogger.Info("Dependency can no longer be met, cancelling job", "job_id", job.ID, "reason", reason)
+	cancelled, err := h.cancelJob(job.ID, reason)
+	if err != nil {
+		return
+	}
+	job.Status, job.FinishedAt = cancelled.Status, cancelled.FinishedAt
+}
@@ -0,0 +1,201 @@
+package api
+
+import (
+	"encoding/json"
+	"errors"
+	"io"
+	"net/http"
+	"strconv"
+	"time"
+
+	"gpu-runner/internal/jobs"
+	"gpu-runner/internal/logger"
+	"gpu-runner/internal/redis"
+
+	"github.com/gorilla/mux"
+)
+
+const (
+	defaultDLQLimit = 100
+	maxDLQLimit     = 1000
+)
+
+// ListDeadLetters returns the newest dead-letter entries.
+func (h *Handlers) ListDeadLetters(w http.ResponseWriter, r *http.Request) {
+	ServerLogger.Info("Received list dead letters request", "query", r.URL.RawQuery, "remote_addr", r.RemoteAddr)
+
+	limit := int64(defaultDLQLimit)
+	if s := r.URL.Query().Get("limit"); s != "" {
+		n, err := strconv.ParseInt(s, 10, 64)
+		if err != nil || n <= 0 {
+			http.Error(w, "limit must be a positive integer", http.StatusBadRequest)
+			return
+		}
+		limit = min(n, maxDLQLimit)
+	}
+
+	entries, err := h.Client.ListDeadLetters(r.Context(), limit)
+	if err != nil {
+		ServerLogger.Error("Failed to list dead letters", "error", err)
+		http.Error(w, "failed to read dead-letter queue", http.StatusInternalServerError)
+		return
+	}
+	if entries == nil {
+		entries = []redis.DeadLetter{}
+	}
+
+	ServerLogger.Info("Successfully listed dead letters", "count", len(entries))
+
+	w.Header().Set("Content-Type", "application/json")
+	if err := json.NewEncoder(w).Encode(map[string]any{"entries": entries}); err != nil {
+		ServerLogger.Error("Failed to encode dead letters response", "error", err)
+	}
+}
+
+// RetryDeadLetter puts a dead job back on the queue. The body may override
+// the command, labels, timeout, deadline and retry settings; anything left
+// out keeps the job's previous value.
+func (h *Handlers) RetryDeadLetter(w http.ResponseWriter, r *http.Request) {
+	id := mux.Vars(r)["id"]
+	ServerLogger.Info("Received dead letter retry request", "job_id", id, "remote_addr", r.RemoteAddr)
+
+	var body struct {
+		Command        string            `json:"command"`
+		Labels         map[string]string `json:"labels"`
+		TimeoutSeconds *int64            `json:"timeout_seconds"`
+		Deadline       *time.Time        `json:"deadline"`
+		MaxRetries     *int              `json:"max_retries"`
+		RetryPolicy    *jobs.RetryPolicy `json:"retry_policy"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&body); err != nil && !errors.Is(err, io.EOF) {
+		ServerLogger.Error("Failed to decode dead letter retry body", "error", err, "job_id", id)
+		http.Error(w, "invalid request body", http.StatusBadRequest)
+		return
+	}
+
+	job, err := h.JobStore.GetJob(id)
+	if err != nil {
+		ServerLogger.Error("Failed to fetch dead job", "error", err, "job_id", id)
+		http.Error(w, "job not found", http.StatusNotFound)
+		return
+	}
+	if job.Status != jobs.StatusDead {
+		ServerLogger.Warn("Refusing to retry job that is not dead", "job_id", id, "status", job.Status)
+		http.Error(w, "job is not dead: status is "+string(job.Status), http.StatusConflict)
+		return
+	}
+
+	if body.Command != "" {
+		job.Command = body.Command
+	}
+	if len(body.Labels) > 0 {
+		if job.Labels == nil {
+			job.Labels = make(map[string]string, len(body.Labels))
+		}
+		for k, v := range body.Labels {
+			job.Labels[k] = v
+		}
+	}
+
+	timeout, deadline := job.TimeoutSeconds, job.Deadline
+	if body.TimeoutSeconds != nil {
+		timeout = *body.TimeoutSeconds
+	}
+	if body.Deadline != nil {
+		deadline = body.Deadline
+	}
+	if job.TimeoutSeconds, err = jobs.ResolveTimeout(timeout, deadline, time.Now()); err != nil {
+		ServerLogger.Warn("Invalid dead letter retry timeout", "error", err, "job_id", id)
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	job.Deadline = deadline
+
+	// A new policy replaces the old one wholesale, but the retry count is
+	// carried over unless it is overridden too.
+	policy, maxRetries := body.RetryPolicy, body.MaxRetries
+	if job.RetryPolicy != nil {
+		if policy == nil {
+			previous := *job.RetryPolicy
+			previous.MaxRetries = nil
+			policy = &previous
+		}
+		if maxRetries == nil {
+			maxRetries = job.RetryPolicy.MaxRetries
+		}
+	}
+	if job.RetryPolicy, err = jobs.ResolveRetryPolicy(policy, maxRetries); err != nil {
+		ServerLogger.Warn("Invalid dead letter retry policy", "error", err, "job_id", id)
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	// Attempt numbers keep counting up so earlier attempts stay in the
+	// history; the retry allowance starts again from the next attempt.
+	job.JobTrial++
+	job.MaxRetries = job.JobTrial - 1 + job.RetryPolicy.Retries()
+
+	if err := h.JobStore.RequeueDeadJob(job); err != nil {
+		ServerLogger.Error("Failed to requeue dead job", "error", err, "job_id", id)
+		http.Error(w, "failed to requeue job", http.StatusConflict)
+		return
+	}
+
+	job.Logger = logger.NewJobLogger(h.ctx, job.ID, h.StreamSink)
+	job.Logger.Info("Job retried from dead-letter queue", logger.Item("trial", job.JobTrial))
+
+	if err := h.Client.Enqueue(h.ctx, *job); err != nil {
+		ServerLogger.Error("Failed to enqueue retried dead job", "error", err, "job_id", id)
+		http.Error(w, "failed to enqueue job", http.StatusInternalServerError)
+		return
+	}
+	if err := h.Client.RemoveDeadLetter(r.Context(), id); err != nil {
+		ServerLogger.Warn("Failed to remove retried job from dead-letter queue", "error", err, "job_id", id)
+	}
+
+	ServerLogger.Info("Dead job requeued", "job_id", id, "trial", job.JobTrial, "max_retries", job.MaxRetries)
+
+	w.Header().Set("Content-Type", "application/json")
+	if err := json.NewEncoder(w).Encode(job); err != nil {
+		ServerLogger.Error("Failed to encode dead letter retry response", "error", err, "job_id", id)
+	}
+}
+
+// PurgeDeadLetter drops one job from the dead-letter queue. The job keeps
+// its dead status.
+func (h *Handlers) PurgeDeadLetter(w http.ResponseWriter, r *http.Request) {
+	id := mux.Vars(r)["id"]
+	ServerLogger.Info("Received dead letter purge request", "job_id", id, "remote_addr", r.RemoteAddr)
+
+	if err := h.Client.RemoveDeadLetter(r.Context(), id); err != nil {
+		if errors.Is(err, redis.ErrDeadLetterNotFound) {
+			http.Error(w, err.Error(), http.StatusNotFound)
+			return
+		}
+		ServerLogger.Error("Failed to purge dead letter", "error", err, "job_id", id)
+		http.Error(w, "failed to purge dead letter", http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	if err := json.NewEncoder(w).Encode(map[string]int64{"purged": 1}); err != nil {
+		ServerLogger.Error("Failed to encode purge response", "error", err, "job_id", id)
+	}
+}
+
+// PurgeDeadLetters empties the dead-letter queue.
+func (h *Handlers) PurgeDeadLetters(w http.ResponseWriter, r *http.Request) {
+	ServerLogger.Info("Received dead-letter queue purge request", "remote_addr", r.RemoteAddr)
+
+	n, err := h.Client.PurgeDeadLetters(r.Context())
+	if err != nil {
+		ServerLogger.Error("Failed to purge dead-letter queue", "error", err)
+		http.Error(w, "failed to purge dead-letter queue", http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	if err := json.NewEncoder(w).Encode(map[string]int64{"purged": n}); err != nil {
+		ServerLogger.Error("Failed to encode purge response", "error", err)
+	}
+}
@@ -0,0 +1,38 @@
+package api
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+
+	"gpu-runner/internal/gpu"
+	"gpu-runner/internal/jobs"
+)
+
+// checkGPUs rejects a new job that asks for GPUs this server doesn't
+// have, counting busy ones as available: those jobs would otherwise wait
+// in the queue forever.
+func (h *Handlers) checkGPUs(job *jobs.Job) error {
+	if job.GPUs == nil {
+		return nil
+	}
+	if h.Queue.GPUs == nil {
+		return fmt.Errorf("job asks for %s but this server has no GPUs", job.GPUs)
+	}
+	return h.Queue.GPUs.Satisfiable(*job.GPUs)
+}
+
+// ListGPUs reports the server's GPUs and the jobs using them.
+func (h *Handlers) ListGPUs(w http.ResponseWriter, r *http.Request) {
+	ServerLogger.Info("Received GPU list request", "remote_addr", r.RemoteAddr)
+
+	devices := []gpu.Assignment{}
+	if h.Queue.GPUs != nil {
+		devices = h.Queue.GPUs.Devices()
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	if err := json.NewEncoder(w).Encode(devices); err != nil {
+		ServerLogger.Error("Failed to encode GPU list response", "error", err)
+	}
+}
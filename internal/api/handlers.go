@@ -3,11 +3,18 @@ package api
 import (
 	"context"
 	"encoding/json"
+	"errors"
+	"fmt"
+	"gpu-runner/internal/executer"
 	"gpu-runner/internal/jobs"
 	"gpu-runner/internal/logger"
 	"gpu-runner/internal/store"
 	"io"
+	"mime"
 	"net/http"
+	"strconv"
+	"strings"
+	"sync"
 	"time"
 
 	"gpu-runner/internal/redis"
@@ -24,6 +31,13 @@ type Handlers struct {
     ctx       context.Context
     StreamSink    *redis.StreamSink 
     Client        *redis.Client
+    // Secrets is optional; without it jobs can't use secrets.
+    Secrets       *store.SecretStore
+    // Runtimes is optional; without it jobs can't choose a runtime.
+    Runtimes      *executer.Registry
+    // arrays serialises array throttling so concurrent results don't
+    // release more children than the limit allows.
+    arrays        sync.Mutex
 }
 
 func NewHandlers(queue *jobs.JobQueue, store *store.JobStore, context context.Context, streamSink *redis.StreamSink, client *redis.Client) *Handlers {
@@ -50,59 +64,87 @@ func (h *Handlers) CreateJob(w http.ResponseWriter, r *http.Request) {
     }
     ServerLogger.Info("Raw request body", "body", string(bodyBytes), "remote_addr", r.RemoteAddr)
 
-    var body struct {
-        Command string          `json:"command"`
-        Storage jobs.JobStorage `json:"storage"`
-        MaxRetries int          `json:"max_retries"`
-    }
-
-    if err := json.Unmarshal(bodyBytes, &body); err != nil {
+    var body jobs.JobRequest
+    if isYAML(r.Header.Get("Content-Type")) {
+        // A job spec file posted as is, e.g. with curl --data-binary @job.yaml.
+        spec, err := jobs.ParseSpec(bodyBytes)
+        if err == nil {
+            body, err = spec.Request()
+        }
+        if err != nil {
+            ServerLogger.Error("Invalid job spec", "error", err, "remote_addr", r.RemoteAddr)
+            http.Error(w, err.Error(), http.StatusBadRequest)
+            return
+        }
+    } else if err := json.Unmarshal(bodyBytes, &body); err != nil {
         ServerLogger.Error("Failed to decode request body", "error", err, "raw_body", string(bodyBytes), "remote_addr", r.RemoteAddr)
         http.Error(w, "invalid request body", http.StatusBadRequest)
         return
     }
 
-    ServerLogger.Info("Parsed job request", "command", body.Command, "storage", body.Storage, "max_retries", body.MaxRetries)
+    ServerLogger.Info("Parsed job request", "command", body.Command, "storage", body.Storage, "retry_policy", body.RetryPolicy != nil)
 
+    job, err := body.NewJob(time.Now())
+    if err != nil {
+        ServerLogger.Error("Invalid job request", "error", err, "command", body.Command)
+        http.Error(w, err.Error(), http.StatusBadRequest)
+        return
+    }
+    if job.StorageBytes != body.Storage {
+        ServerLogger.Info("Adjusted storage size", "requested", body.Storage, "adjusted", job.StorageBytes)
+    }
+    if err := h.checkJob(job); err != nil {
+        ServerLogger.Error("Job cannot run on this server", "error", err, "command", body.Command)
+        http.Error(w, err.Error(), http.StatusBadRequest)
+        return
+    }
 
-    limits := [3]jobs.JobStorage{jobs.Volume10MB, jobs.Volume25MB,  jobs.Volume50MB}
+    if err := h.submitJob(job); err != nil {
+        http.Error(w, err.Error(), http.StatusInternalServerError)
+        return
+    }
 
+    w.Header().Set("Content-Type", "application/json")
+    if err := json.NewEncoder(w).Encode(job); err != nil {
+        ServerLogger.Error("Failed to encode response", "error", err, "job_id", job.ID)
+    }
+}
 
+// isYAML reports whether a request's Content-Type is a YAML job spec.
+func isYAML(contentType string) bool {
+    mediaType, _, _ := mime.ParseMediaType(contentType)
+    return mediaType == "application/yaml" || mediaType == "application/x-yaml" || mediaType == "text/yaml"
+}
 
-    for i, v := range(limits) {
-        if body.Storage < v {
-            ServerLogger.Info("Adjusted storage size", "requested", body.Storage, "adjusted", v)
-            body.Storage = v
-            break
-        }
-        if i == len(limits)-1 {
-            ServerLogger.Error("Job exceeds storage capacity", "command", body.Command, "requested_storage", body.Storage)
-            http.Error(w, "storage requirement exceeds maximum capacity", http.StatusBadRequest)
-            return
-        }
-    }
+var (
+    errCreateJob  = errors.New("failed to create job")
+    errEnqueueJob = errors.New("failed to enqueue job")
+)
 
-    if body.MaxRetries == 0{
-        body.MaxRetries = 3
-        ServerLogger.Info("Using default max_retries", "max_retries", body.MaxRetries)
+// checkJob runs the checks a new job needs against this server's state:
+// its dependencies, secrets, runtime and GPUs. Every way of creating a job
+// goes through it.
+func (h *Handlers) checkJob(job *jobs.Job) error {
+    if err := h.checkDependencies(job); err != nil {
+        return err
     }
-
-    job := &jobs.Job{
-        Command:   body.Command,
-        StorageBytes:   body.Storage,
-        VolumePath: jobs.VolumePaths[body.Storage],
-        Status:    jobs.StatusPending,
-        CreatedAt: time.Now(),
-        MaxRetries: body.MaxRetries,
-        JobTrial: 1,
+    if err := h.checkSecrets(job); err != nil {
+        return err
+    }
+    if err := h.checkRuntime(job); err != nil {
+        return err
     }
+    return h.checkGPUs(job)
+}
 
-    ServerLogger.Info("Creating job in database", "command", job.Command, "storage", job.StorageBytes, "volume_path", job.VolumePath)
+// submitJob stores a new job and queues it, or holds it in the delayed
+// queue until its run_at. Jobs created by schedules come through here too.
+func (h *Handlers) submitJob(job *jobs.Job) error {
+    ServerLogger.Info("Creating job in database", "command", job.Command, "storage", job.StorageBytes)
 
     if err := h.JobStore.CreateJob(job); err != nil {
         ServerLogger.Error("Failed to create job in database", "error", err, "command", job.Command)
-        http.Error(w, "failed to create job", http.StatusInternalServerError)
-        return
+        return errCreateJob
     }
 
     ServerLogger.Info("Job created in database", "job_id", job.ID, "command", job.Command)
@@ -110,18 +152,35 @@ func (h *Handlers) CreateJob(w http.ResponseWriter, r *http.Request) {
     job.Logger =  logger.NewJobLogger(h.ctx, job.ID, h.StreamSink)
     job.Logger.Info("Successfully created job!")
 
-    if err := h.Client.Enqueue(h.ctx, *job); err != nil {
+    if job.Array != nil {
+        return h.submitArray(job)
+    }
+    if job.Status == jobs.StatusBlocked {
+        ServerLogger.Info("Job blocked on dependencies", "job_id", job.ID, "depends_on", len(job.DependsOn))
+        // A parent may have finished before this job was stored, in which
+        // case no result will come along to release it.
+        h.resolveBlocked(job)
+        return nil
+    }
+    return h.enqueueJob(job)
+}
+
+// enqueueJob queues a stored job, or holds it in the delayed queue until
+// its run_at.
+func (h *Handlers) enqueueJob(job *jobs.Job) error {
+    var err error
+    if job.RunAt != nil {
+        err = h.Client.EnqueueAt(h.ctx, *job, *job.RunAt)
+    } else {
+        err = h.Client.Enqueue(h.ctx, *job)
+    }
+    if err != nil {
         ServerLogger.Error("Failed to enqueue job to Redis", "error", err, "job_id", job.ID)
-        http.Error(w, "failed to enqueue job", http.StatusInternalServerError)
-        return
+        return errEnqueueJob
     }
 
     ServerLogger.Info("Job enqueued successfully", "job_id", job.ID)
-
-    w.Header().Set("Content-Type", "application/json")
-    if err := json.NewEncoder(w).Encode(job); err != nil {
-        ServerLogger.Error("Failed to encode response", "error", err, "job_id", job.ID)
-    }
+    return nil
 }
 
 func (h *Handlers) CancelJob(w http.ResponseWriter, r *http.Request){
@@ -134,20 +193,41 @@ func (h *Handlers) CancelJob(w http.ResponseWriter, r *http.Request){
     if err := json.NewDecoder(r.Body).Decode(&body); err != nil {
         ServerLogger.Warn("Failed to decode cancel request body, proceeding without reason", "error", err, "job_id", id)
     }
-    reason := body.Reason
+
+    job, err := h.cancelJob(id, body.Reason)
+    if err != nil {
+        http.Error(w, "job not cancellable", http.StatusBadRequest)
+        return
+    }
+
+    w.Header().Set("Content-Type", "application/json")
+    if err := json.NewEncoder(w).Encode(job); err != nil {
+        ServerLogger.Error("Failed to encode cancel response", "error", err, "job_id", job.ID)
+    }
+}
+
+// cancelJob stops a job wherever it is: running on a worker, waiting in
+// the queue or held until its run_at. The store is updated first, so a
+// worker that has dequeued the job but not started it yet sees the
+// cancellation and drops it.
+func (h *Handlers) cancelJob(id, reason string) (*jobs.Job, error) {
+    job, err := h.JobStore.CancelJob(id)
+    if err != nil {
+        ServerLogger.Error("Unable to cancel job in store", "error", err, "job_id", id)
+        return nil, err
+    }
 
     ServerLogger.Info("Attempting to cancel running job", "job_id", id)
-    if err := h.Queue.Executor.CancelJob(id); err != nil {
+    if err := h.Queue.Executor.Cancel(id); err != nil {
         ServerLogger.Warn("Job not currently running or already completed", "error", err, "job_id", id)
     } else {
         ServerLogger.Info("Successfully cancelled running job execution", "job_id", id)
     }
 
-    job, err := h.JobStore.CancelJob(id)
-    if err != nil {
-        ServerLogger.Error("Unable to cancel job in store", "error", err, "job_id", id)
-        http.Error(w, "job not cancellable", http.StatusBadRequest)
-        return
+    if removed, err := h.Client.Remove(h.ctx, id); err != nil {
+        ServerLogger.Error("Failed to remove cancelled job from queue", "error", err, "job_id", id)
+    } else if removed {
+        ServerLogger.Info("Removed cancelled job from queue", "job_id", id)
     }
 
     ServerLogger.Info("Job cancelled in database", "job_id", id, "reason", reason)
@@ -158,10 +238,14 @@ func (h *Handlers) CancelJob(w http.ResponseWriter, r *http.Request){
     job.Logger.Info("Successfully cancelled job!",
             logger.Item("reason", reason))
 
-    w.Header().Set("Content-Type", "application/json")
-    if err := json.NewEncoder(w).Encode(job); err != nil {
-        ServerLogger.Error("Failed to encode cancel response", "error", err, "job_id", job.ID)
+    if job.Array != nil {
+        h.cancelArrayChildren(job, reason)
     }
+    h.resolveDependents(job)
+    if job.ArrayID != "" {
+        h.advanceArray(job.ArrayID)
+    }
+    return job, nil
 }
 
 func (h *Handlers) GetJob(w http.ResponseWriter, r *http.Request) {
@@ -177,13 +261,205 @@ func (h *Handlers) GetJob(w http.ResponseWriter, r *http.Request) {
 
     ServerLogger.Info("Successfully fetched job", "job_id", id, "status", job.Status)
 
+    view := jobView{Job: job}
+    if job.Array != nil {
+        if view.ArrayStatus, err = h.arraySummary(job); err != nil {
+            http.Error(w, "failed to summarise array", http.StatusInternalServerError)
+            return
+        }
+    }
+
     w.Header().Set("Content-Type", "application/json")
-    if err := json.NewEncoder(w).Encode(job); err != nil {
+    if err := json.NewEncoder(w).Encode(view); err != nil {
         ServerLogger.Error("Failed to encode job response", "error", err, "job_id", job.ID)
     }
 }
 
+func (h *Handlers) ListJobs(w http.ResponseWriter, r *http.Request) {
+    ServerLogger.Info("Received list jobs request", "query", r.URL.RawQuery, "remote_addr", r.RemoteAddr)
+
+    filter, err := parseJobFilter(r)
+    if err != nil {
+        ServerLogger.Warn("Invalid list jobs request", "error", err, "query", r.URL.RawQuery)
+        http.Error(w, err.Error(), http.StatusBadRequest)
+        return
+    }
+
+    page, err := h.JobStore.ListJobs(filter)
+    if errors.Is(err, store.ErrInvalidCursor) {
+        http.Error(w, err.Error(), http.StatusBadRequest)
+        return
+    }
+    if err != nil {
+        ServerLogger.Error("Failed to list jobs from database", "error", err)
+        http.Error(w, "failed to list jobs", http.StatusInternalServerError)
+        return
+    }
+
+    ServerLogger.Info("Successfully listed jobs", "count", len(page.Jobs), "has_more", page.NextCursor != "")
+
+    w.Header().Set("Content-Type", "application/json")
+    if err := json.NewEncoder(w).Encode(page); err != nil {
+        ServerLogger.Error("Failed to encode list response", "error", err)
+    }
+}
+
+// parseJobFilter reads the GET /jobs query string. Repeated and
+// comma-separated status values are both accepted; labels are given as
+// repeated label=key=value parameters.
+func parseJobFilter(r *http.Request) (store.JobFilter, error) {
+    q := r.URL.Query()
+    filter := store.JobFilter{
+        Command: q.Get("command"),
+        ArrayID: q.Get("array_id"),
+        SortBy:  q.Get("sort"),
+        Cursor:  q.Get("cursor"),
+    }
+
+    for _, raw := range q["status"] {
+        for _, st := range strings.Split(raw, ",") {
+            status := jobs.JobStatus(strings.TrimSpace(st))
+            if status == "" {
+                continue
+            }
+            if !status.Valid() {
+                return filter, fmt.Errorf("unknown status %q", status)
+            }
+            filter.Statuses = append(filter.Statuses, status)
+        }
+    }
+
+    if filter.SortBy != "" && !store.ValidSortKey(filter.SortBy) {
+        return filter, fmt.Errorf("unsupported sort key %q", filter.SortBy)
+    }
+    switch q.Get("order") {
+    case "", "desc":
+        filter.Descending = true
+    case "asc":
+    default:
+        return filter, fmt.Errorf("order must be asc or desc")
+    }
+
+    var err error
+    if v := q.Get("created_after"); v != "" {
+        if filter.CreatedAfter, err = time.Parse(time.RFC3339, v); err != nil {
+            return filter, fmt.Errorf("created_after must be RFC3339: %w", err)
+        }
+    }
+    if v := q.Get("created_before"); v != "" {
+        if filter.CreatedBefore, err = time.Parse(time.RFC3339, v); err != nil {
+            return filter, fmt.Errorf("created_before must be RFC3339: %w", err)
+        }
+    }
+    if v := q.Get("limit"); v != "" {
+        if filter.Limit, err = strconv.Atoi(v); err != nil || filter.Limit < 0 {
+            return filter, fmt.Errorf("limit must be a non-negative integer")
+        }
+    }
+
+    for _, raw := range q["label"] {
+        key, value, ok := strings.Cut(raw, "=")
+        if !ok || key == "" {
+            return filter, fmt.Errorf("label filter %q must be key=value", raw)
+        }
+        if filter.Labels == nil {
+            filter.Labels = make(map[string]string)
+        }
+        filter.Labels[key] = value
+    }
+    return filter, nil
+}
+
+func (h *Handlers) GetJobAttempts(w http.ResponseWriter, r *http.Request) {
+    id := mux.Vars(r)["id"]
+    ServerLogger.Info("Received job attempts request", "job_id", id, "remote_addr", r.RemoteAddr)
+
+    if _, err := h.JobStore.GetJob(id); err != nil {
+        ServerLogger.Error("Failed to fetch job for attempts", "error", err, "job_id", id)
+        http.Error(w, "job not found", http.StatusNotFound)
+        return
+    }
+
+    attempts, err := h.JobStore.ListAttempts(id)
+    if err != nil {
+        ServerLogger.Error("Failed to list job attempts", "error", err, "job_id", id)
+        http.Error(w, "failed to list attempts", http.StatusInternalServerError)
+        return
+    }
+
+    ServerLogger.Info("Successfully fetched job attempts", "job_id", id, "count", len(attempts))
+
+    w.Header().Set("Content-Type", "application/json")
+    if err := json.NewEncoder(w).Encode(map[string]any{"attempts": attempts}); err != nil {
+        ServerLogger.Error("Failed to encode attempts response", "error", err, "job_id", id)
+    }
+}
+
+// recordAttempt stores the attempt a worker result reports on. The log range
+// runs from the attempt's start until the result was received, which covers
+// everything the worker logged for it.
+func (h *Handlers) recordAttempt(res *jobs.Job) {
+    if res.StartedAt == nil {
+        return
+    }
+    attempt := res.CurrentAttempt()
+    attempt.LogStartID = redis.StreamIDAt(*res.StartedAt, false)
+    if res.Status.IsTerminal() {
+        attempt.LogEndID = redis.StreamIDAt(time.Now(), true)
+    }
+    if err := h.JobStore.RecordAttempt(attempt); err != nil {
+        ServerLogger.Error("Failed to record job attempt", "error", err, "job_id", res.ID, "attempt", res.JobTrial)
+    }
+}
+
+// PromoteScheduled marks a job pending once the delayed queue has released
+// it. Retries leaving their backoff are already pending and unaffected.
+func (h *Handlers) PromoteScheduled(jobID string) {
+    if err := h.JobStore.PromoteScheduled(jobID); err != nil {
+        ServerLogger.Error("Failed to promote scheduled job", "error", err, "job_id", jobID)
+    }
+}
 
+// MarkLost settles a job whose lease expired after the reaper took it
+// back. The running attempt is closed as lost, and the job's retry policy
+// decides what comes next as for any failed attempt: losing a lease counts
+// as being preempted.
+func (h *Handlers) MarkLost(lost jobs.Job) {
+    job, err := h.JobStore.GetJob(lost.ID)
+    if err != nil {
+        ServerLogger.Error("Failed to fetch lost job", "error", err, "job_id", lost.ID)
+        return
+    }
+    ServerLogger.Warn("Job lease expired, marking attempt lost", "job_id", job.ID, "trial", lost.JobTrial, "worker", job.WorkerID)
+
+    // The payload is what the job is retried with; the store knows when
+    // and where the attempt ran.
+    res := lost
+    res.StartedAt, res.WorkerID = job.StartedAt, job.WorkerID
+    now := time.Now()
+    res.Status = jobs.StatusLost
+    res.FailureReason = jobs.ReasonPreempted
+    res.FinishedAt = &now
+    res.Error = "lease expired: worker stopped sending heartbeats"
+    if res.StartedAt != nil {
+        attempt := res.CurrentAttempt()
+        attempt.LogStartID = redis.StreamIDAt(*res.StartedAt, false)
+        attempt.LogEndID = redis.StreamIDAt(now, true)
+        if err := h.JobStore.RecordAttempt(attempt); err != nil {
+            ServerLogger.Error("Failed to record lost attempt", "error", err, "job_id", res.ID, "attempt", res.JobTrial)
+        }
+    }
+
+    res.Status = jobs.StatusFailed
+    res.Logger = logger.NewJobLogger(h.ctx, res.ID, h.StreamSink)
+    res.Logger.Error("Job lease expired", logger.Item("trial", res.JobTrial))
+    h.settle(h.ctx, &res)
+}
+
+// StartRedisAcknowledger persists the results workers report. A failed
+// attempt is recorded as it ended, then the retry policy decides what the
+// job becomes, and the job is written once with that final status so
+// nothing watching it sees a retried attempt as the end of the job.
 func (h *Handlers) StartRedisAcknowledger(ctx context.Context, results chan *jobs.Job)  {
 	ServerLogger.Info("Starting Redis acknowledger goroutine")
 	go func(){
@@ -194,29 +470,106 @@ func (h *Handlers) StartRedisAcknowledger(ctx context.Context, results chan *job
 				return
 			case res := <- results:
 				ServerLogger.Info("Processing job result", "job_id", res.ID, "status", res.Status, "trial", res.JobTrial)
-                if err := h.JobStore.UpdateJob(res); err != nil {
-					ServerLogger.Error("Failed to update job", "error", err, "job_id", res.ID)
+				if h.superseded(res) {
+					ServerLogger.Warn("Dropping result of a superseded attempt", "job_id", res.ID, "status", res.Status, "trial", res.JobTrial)
+					continue
 				}
-				switch res.Status{
-				case jobs.StatusSuccess:
-					ServerLogger.Info("Acknowledging successful job", "job_id", res.ID)
-					if err := h.Client.Acknowledge(ctx, *res); err != nil {
-						ServerLogger.Error("Failed to acknowledge job", "error", err, "job_id", res.ID)
-					}
-				case jobs.StatusFailed:
-					if res.JobTrial >= res.MaxRetries{
-						ServerLogger.Warn("Job exhausted all retries", "job_id", res.ID, "trials", res.JobTrial, "max_retries", res.MaxRetries)
-						continue
-					}
-					res.JobTrial++
-					ServerLogger.Info("Retrying failed job", "job_id", res.ID, "trial", res.JobTrial, "max_retries", res.MaxRetries)
-					if err := h.Client.Enqueue(ctx, *res); err != nil {
-						ServerLogger.Error("Failed to re-enqueue job", "error", err, "job_id", res.ID)
-					}
-                default:
-					ServerLogger.Info("Updating job with status", "job_id", res.ID, "status", res.Status)
+				h.recordAttempt(res)
+				h.settle(ctx, res)
 			}
 		}
-	}
 	}()
-}
\ No newline at end of file
+}
+
+// settle applies the retry policy to a reported attempt, stores the job's
+// new state and moves it on in Redis: back to the queue, to the
+// dead-letter queue, or out of the processing list once it is finished.
+func (h *Handlers) settle(ctx context.Context, res *jobs.Job) {
+	var retryAt *time.Time
+	if res.Status == jobs.StatusFailed || res.Status == jobs.StatusTimedOut {
+		retryAt = h.applyRetryPolicy(res)
+	}
+	if err := h.JobStore.UpdateJob(res); err != nil {
+		ServerLogger.Error("Failed to update job", "error", err, "job_id", res.ID)
+	}
+	switch {
+	case retryAt != nil:
+		if err := h.Client.Requeue(ctx, *res, *retryAt); err != nil {
+			ServerLogger.Error("Failed to re-enqueue job", "error", err, "job_id", res.ID)
+		}
+	case res.Status == jobs.StatusDead:
+		if err := h.Client.DeadLetter(ctx, *res); err != nil {
+			ServerLogger.Error("Failed to dead-letter job", "error", err, "job_id", res.ID)
+		}
+		h.ackJob(ctx, res)
+	case res.Status.IsTerminal():
+		ServerLogger.Info("Acknowledging finished job", "job_id", res.ID, "status", res.Status)
+		h.ackJob(ctx, res)
+	default:
+		ServerLogger.Info("Updating job with status", "job_id", res.ID, "status", res.Status)
+	}
+	if res.Status.IsTerminal() {
+		h.resolveDependents(res)
+		h.releaseWorkspace(res)
+	}
+	if res.ArrayID != "" {
+		h.advanceArray(res.ArrayID)
+	}
+}
+
+// superseded reports whether a later attempt of the job has begun since
+// res ran, as when its lease expired and the job was retried. Reports from
+// the old attempt must not overwrite the new one's state.
+func (h *Handlers) superseded(res *jobs.Job) bool {
+	job, err := h.JobStore.GetJob(res.ID)
+	if err != nil {
+		ServerLogger.Warn("Failed to check job trial", "error", err, "job_id", res.ID)
+		return false
+	}
+	return job.JobTrial > res.JobTrial
+}
+
+func (h *Handlers) ackJob(ctx context.Context, res *jobs.Job) {
+	if err := h.Client.Ack(ctx, res.ID); err != nil {
+		ServerLogger.Error("Failed to acknowledge job", "error", err, "job_id", res.ID)
+	}
+}
+
+// releaseWorkspace starts the retention period of a finished job's
+// workspace.
+func (h *Handlers) releaseWorkspace(res *jobs.Job) {
+	if h.Queue.Workspaces == nil {
+		return
+	}
+	if err := h.Queue.Workspaces.Release(res.ID, res.Status == jobs.StatusSuccess); err != nil {
+		ServerLogger.Error("Failed to release job workspace", "error", err, "job_id", res.ID)
+	}
+}
+
+// applyRetryPolicy decides what a failed attempt leads to. A retry puts
+// the job back to pending for its next trial and returns when it may run;
+// retries go back through the delayed queue so the backoff doesn't hold a
+// worker. Otherwise the job stays failed, or is dead once the retries it
+// asked for are used up, and nil is returned.
+func (h *Handlers) applyRetryPolicy(res *jobs.Job) *time.Time {
+	switch res.NextAttempt() {
+	case jobs.NoRetry:
+		ServerLogger.Info("Job failure is not retried", "job_id", res.ID, "status", res.Status, "reason", res.FailureReason, "exit_code", res.ExitCode, "max_retries", res.MaxRetries)
+		return nil
+	case jobs.RetriesExhausted:
+		ServerLogger.Warn("Job exhausted all retries", "job_id", res.ID, "trials", res.JobTrial, "max_retries", res.MaxRetries)
+		res.Status = jobs.StatusDead
+		return nil
+	}
+
+	delay := res.RetryPolicy.Backoff(res.JobTrial)
+	res.JobTrial++
+	res.Status = jobs.StatusPending
+	res.StartedAt, res.FinishedAt, res.ExitCode, res.Signal = nil, nil, nil, ""
+	ServerLogger.Info("Retrying failed job", "job_id", res.ID, "trial", res.JobTrial, "max_retries", res.MaxRetries, "reason", res.FailureReason, "backoff", delay)
+	if res.Logger != nil {
+		res.Logger.Info("Retrying job after backoff", logger.Item("trial", res.JobTrial), logger.Item("backoff", delay.String()))
+	}
+	at := time.Now().Add(delay)
+	return &at
+}
@@ -0,0 +1,317 @@
+package api
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"gpu-runner/internal/logger"
+	"gpu-runner/internal/redis"
+
+	"github.com/gorilla/mux"
+)
+
+const (
+	defaultLogLimit   = 1000
+	maxLogLimit       = 10000
+	logStatusInterval = 2 * time.Second
+	sseKeepAlive      = 15 * time.Second
+)
+
+// logQuery holds the filters accepted by GET /jobs/{id}/logs.
+type logQuery struct {
+	levels  map[string]bool
+	streams map[string]bool
+	since   time.Time
+	until   time.Time
+	after   string
+	attempt int
+	tail    int64
+	limit   int64
+	follow  bool
+}
+
+func (q logQuery) match(rec logger.Record) bool {
+	if len(q.levels) > 0 && !q.levels[rec.Level] {
+		return false
+	}
+	if len(q.streams) > 0 && !q.streams[rec.Stream] {
+		return false
+	}
+	if !q.since.IsZero() && rec.Timestamp.Before(q.since) {
+		return false
+	}
+	if !q.until.IsZero() && rec.Timestamp.After(q.until) {
+		return false
+	}
+	return true
+}
+
+// streamRange narrows the Redis range using the millisecond timestamps
+// embedded in stream IDs, so time filters don't scan the whole stream.
+func (q logQuery) streamRange() (string, string) {
+	start, end := "-", "+"
+	if q.after != "" {
+		start = "(" + q.after
+	} else if !q.since.IsZero() {
+		start = strconv.FormatInt(q.since.UnixMilli(), 10)
+	}
+	if !q.until.IsZero() {
+		end = strconv.FormatInt(q.until.UnixMilli(), 10)
+	}
+	return start, end
+}
+
+func parseLogQuery(r *http.Request) (logQuery, error) {
+	v := r.URL.Query()
+	q := logQuery{
+		after:  v.Get("after"),
+		limit:  defaultLogLimit,
+		follow: v.Get("follow") == "true" || v.Get("follow") == "1",
+	}
+
+	q.levels = splitSet(v["level"])
+	q.streams = splitSet(v["stream"])
+
+	var err error
+	if s := v.Get("since"); s != "" {
+		if q.since, err = time.Parse(time.RFC3339, s); err != nil {
+			return q, fmt.Errorf("since must be RFC3339: %w", err)
+		}
+	}
+	if s := v.Get("until"); s != "" {
+		if q.until, err = time.Parse(time.RFC3339, s); err != nil {
+			return q, fmt.Errorf("until must be RFC3339: %w", err)
+		}
+	}
+	if s := v.Get("limit"); s != "" {
+		if q.limit, err = strconv.ParseInt(s, 10, 64); err != nil || q.limit <= 0 {
+			return q, fmt.Errorf("limit must be a positive integer")
+		}
+		if q.limit > maxLogLimit {
+			q.limit = maxLogLimit
+		}
+	}
+	if s := v.Get("attempt"); s != "" {
+		if q.attempt, err = strconv.Atoi(s); err != nil || q.attempt <= 0 {
+			return q, fmt.Errorf("attempt must be a positive integer")
+		}
+	}
+	if s := v.Get("tail"); s != "" {
+		if q.tail, err = strconv.ParseInt(s, 10, 64); err != nil || q.tail <= 0 {
+			return q, fmt.Errorf("tail must be a positive integer")
+		}
+	}
+	return q, nil
+}
+
+// splitSet collects repeated and comma-separated query values.
+func splitSet(raw []string) map[string]bool {
+	var set map[string]bool
+	for _, r := range raw {
+		for _, item := range strings.Split(r, ",") {
+			if item = strings.TrimSpace(item); item != "" {
+				if set == nil {
+					set = make(map[string]bool)
+				}
+				set[item] = true
+			}
+		}
+	}
+	return set
+}
+
+// GetJobLogs returns the stored log entries for a job. With follow=true the
+// response is a Server-Sent Events stream that ends once the job reaches a
+// terminal status; each event ID is the Redis stream ID, so clients resume
+// by sending it back as Last-Event-ID.
+func (h *Handlers) GetJobLogs(w http.ResponseWriter, r *http.Request) {
+	id := mux.Vars(r)["id"]
+	ServerLogger.Info("Received job logs request", "job_id", id, "query", r.URL.RawQuery, "remote_addr", r.RemoteAddr)
+
+	q, err := parseLogQuery(r)
+	if err != nil {
+		ServerLogger.Warn("Invalid job logs request", "error", err, "job_id", id)
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	if _, err := h.JobStore.GetJob(id); err != nil {
+		ServerLogger.Error("Failed to fetch job for logs", "error", err, "job_id", id)
+		http.Error(w, "job not found", http.StatusNotFound)
+		return
+	}
+
+	var attemptStart, attemptEnd string
+	if q.attempt > 0 {
+		attempts, err := h.JobStore.ListAttempts(id)
+		if err != nil {
+			ServerLogger.Error("Failed to list job attempts for logs", "error", err, "job_id", id)
+			http.Error(w, "failed to read attempts", http.StatusInternalServerError)
+			return
+		}
+		for _, a := range attempts {
+			if a.Attempt == q.attempt {
+				attemptStart, attemptEnd = a.LogStartID, a.LogEndID
+			}
+		}
+		if attemptStart == "" {
+			http.Error(w, "attempt not found", http.StatusNotFound)
+			return
+		}
+		if q.follow && attemptEnd != "" {
+			// A finished attempt has nothing left to follow.
+			q.follow = false
+		}
+	}
+
+	if q.follow {
+		h.followJobLogs(w, r, id, q, attemptStart)
+		return
+	}
+
+	var entries []redis.StreamEntry
+	if q.tail > 0 {
+		entries, err = h.StreamSink.Tail(r.Context(), id, q.tail)
+	} else {
+		start, end := q.streamRange()
+		if attemptStart != "" && q.after == "" {
+			start = attemptStart
+		}
+		if attemptEnd != "" {
+			end = attemptEnd
+		}
+		entries, err = h.StreamSink.Entries(r.Context(), id, start, end, q.limit)
+	}
+	if err != nil {
+		ServerLogger.Error("Failed to read job logs", "error", err, "job_id", id)
+		http.Error(w, "failed to read logs", http.StatusInternalServerError)
+		return
+	}
+
+	resp := struct {
+		Entries []logger.Record `json:"entries"`
+		LastID  string          `json:"last_id,omitempty"`
+	}{Entries: []logger.Record{}}
+	for _, entry := range entries {
+		resp.LastID = entry.ID
+		rec, err := logger.ParseRecord(entry.ID, entry.Message)
+		if err != nil {
+			ServerLogger.Warn("Skipping undecodable log entry", "error", err, "job_id", id, "entry_id", entry.ID)
+			continue
+		}
+		if q.match(rec) {
+			resp.Entries = append(resp.Entries, rec)
+		}
+	}
+
+	ServerLogger.Info("Successfully fetched job logs", "job_id", id, "count", len(resp.Entries))
+
+	w.Header().Set("Content-Type", "application/json")
+	if err := json.NewEncoder(w).Encode(resp); err != nil {
+		ServerLogger.Error("Failed to encode logs response", "error", err, "job_id", id)
+	}
+}
+
+func (h *Handlers) followJobLogs(w http.ResponseWriter, r *http.Request, id string, q logQuery, attemptStart string) {
+	flusher, ok := w.(http.Flusher)
+	if !ok {
+		http.Error(w, "streaming unsupported", http.StatusInternalServerError)
+		return
+	}
+
+	from := r.Header.Get("Last-Event-ID")
+	if from == "" {
+		from = q.after
+	}
+	if from == "" && attemptStart != "" {
+		// XREAD is exclusive, so start just before the attempt's first ID.
+		if ms, _, ok := strings.Cut(attemptStart, "-"); ok {
+			if n, err := strconv.ParseInt(ms, 10, 64); err == nil {
+				from = redis.StreamIDAt(time.UnixMilli(n-1), true)
+			}
+		}
+	}
+	if from == "" && !q.since.IsZero() {
+		from = fmt.Sprintf("%d-0", q.since.UnixMilli()-1)
+	}
+
+	ctx, cancel := context.WithCancel(r.Context())
+	defer cancel()
+
+	entries, err := h.StreamSink.Follow(ctx, id, from)
+	if err != nil {
+		ServerLogger.Error("Failed to follow job logs", "error", err, "job_id", id)
+		http.Error(w, "failed to stream logs", http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "text/event-stream")
+	w.Header().Set("Cache-Control", "no-cache")
+	w.Header().Set("Connection", "keep-alive")
+	w.Header().Set("X-Accel-Buffering", "no")
+	w.WriteHeader(http.StatusOK)
+	flusher.Flush()
+
+	ServerLogger.Info("Streaming job logs", "job_id", id, "from", from)
+
+	lastID := from
+	send := func(entry redis.StreamEntry) {
+		lastID = entry.ID
+		rec, err := logger.ParseRecord(entry.ID, entry.Message)
+		if err != nil || !q.match(rec) {
+			return
+		}
+		fmt.Fprintf(w, "id: %s\nevent: log\ndata: %s\n\n", entry.ID, entry.Message)
+	}
+
+	statusTicker := time.NewTicker(logStatusInterval)
+	defer statusTicker.Stop()
+	keepAlive := time.NewTicker(sseKeepAlive)
+	defer keepAlive.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			ServerLogger.Info("Log stream client disconnected", "job_id", id)
+			return
+		case entry, ok := <-entries:
+			if !ok {
+				ServerLogger.Warn("Log stream closed by Redis", "job_id", id)
+				return
+			}
+			send(entry)
+			flusher.Flush()
+		case <-keepAlive.C:
+			fmt.Fprint(w, ": keep-alive\n\n")
+			flusher.Flush()
+		case <-statusTicker.C:
+			job, err := h.JobStore.GetJob(id)
+			if err != nil || !job.Status.IsTerminal() {
+				continue
+			}
+			// Stop following and flush whatever was appended since the last
+			// delivered entry before telling the client the job is done.
+			cancel()
+			start := "-"
+			if lastID != "" && lastID != "0" {
+				start = "(" + lastID
+			}
+			rest, err := h.StreamSink.Entries(r.Context(), id, start, "+", 0)
+			if err != nil {
+				ServerLogger.Warn("Failed to drain job logs", "error", err, "job_id", id)
+			}
+			for _, entry := range rest {
+				send(entry)
+			}
+			fmt.Fprintf(w, "event: end\ndata: {\"status\":%q}\n\n", job.Status)
+			flusher.Flush()
+			ServerLogger.Info("Job finished, closing log stream", "job_id", id, "status", job.Status)
+			return
+		}
+	}
+}
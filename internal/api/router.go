@@ -8,8 +8,26 @@ func NewRouter(h *Handlers) *mux.Router {
     r := mux.NewRouter()
 
     r.HandleFunc("/jobs", h.CreateJob).Methods("POST")
+    r.HandleFunc("/jobs", h.ListJobs).Methods("GET")
     r.HandleFunc("/endjobs/{id}", h.CancelJob).Methods("POST")
     r.HandleFunc("/jobs/{id}", h.GetJob).Methods("GET")
+    r.HandleFunc("/jobs/{id}/logs", h.GetJobLogs).Methods("GET")
+    r.HandleFunc("/jobs/{id}/attempts", h.GetJobAttempts).Methods("GET")
+    r.HandleFunc("/jobs/{id}/stats", h.GetJobStats).Methods("GET")
+    r.HandleFunc("/gpus", h.ListGPUs).Methods("GET")
+    r.HandleFunc("/dlq", h.ListDeadLetters).Methods("GET")
+    r.HandleFunc("/dlq", h.PurgeDeadLetters).Methods("DELETE")
+    r.HandleFunc("/dlq/{id}", h.PurgeDeadLetter).Methods("DELETE")
+    r.HandleFunc("/dlq/{id}/retry", h.RetryDeadLetter).Methods("POST")
+    r.HandleFunc("/schedules", h.CreateSchedule).Methods("POST")
+    r.HandleFunc("/schedules", h.ListSchedules).Methods("GET")
+    r.HandleFunc("/schedules/{id}", h.GetSchedule).Methods("GET")
+    r.HandleFunc("/schedules/{id}", h.DeleteSchedule).Methods("DELETE")
+    r.HandleFunc("/workflows", h.CreateWorkflow).Methods("POST")
+    r.HandleFunc("/workflows/{id}", h.GetWorkflow).Methods("GET")
+    r.HandleFunc("/secrets", h.ListSecrets).Methods("GET")
+    r.HandleFunc("/secrets/{name}", h.PutSecret).Methods("PUT")
+    r.HandleFunc("/secrets/{name}", h.DeleteSecret).Methods("DELETE")
     
     return r
 }
\ No newline at end of file
@@ -0,0 +1,48 @@
+package api
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+
+	"gpu-runner/internal/executer"
+	"gpu-runner/internal/jobs"
+
+	"github.com/gorilla/mux"
+)
+
+// checkRuntime rejects a new job that asks for a runtime this server
+// doesn't have. Without a registry every job runs on the queue's executor.
+func (h *Handlers) checkRuntime(job *jobs.Job) error {
+	if job.Runtime == "" || h.Runtimes == nil {
+		return nil
+	}
+	if !h.Runtimes.Has(job.Runtime) {
+		return fmt.Errorf("unknown runtime %q (available: %s)", job.Runtime, strings.Join(h.Runtimes.Names(), ", "))
+	}
+	return nil
+}
+
+// GetJobStats samples a running job on the executor running it.
+func (h *Handlers) GetJobStats(w http.ResponseWriter, r *http.Request) {
+	id := mux.Vars(r)["id"]
+	ServerLogger.Info("Received job stats request", "job_id", id, "remote_addr", r.RemoteAddr)
+
+	stats, err := h.Queue.Executor.Stats(id)
+	if errors.Is(err, executer.ErrNotRunning) {
+		http.Error(w, "job is not running", http.StatusNotFound)
+		return
+	}
+	if err != nil {
+		ServerLogger.Error("Failed to sample job", "error", err, "job_id", id)
+		http.Error(w, "failed to get job stats", http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	if err := json.NewEncoder(w).Encode(stats); err != nil {
+		ServerLogger.Error("Failed to encode job stats response", "error", err, "job_id", id)
+	}
+}
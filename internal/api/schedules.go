@@ -0,0 +1,229 @@
+package api
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"maps"
+	"net/http"
+	"time"
+
+	"gpu-runner/internal/jobs"
+
+	"github.com/gorilla/mux"
+)
+
+// ScheduleLabel is set on every job a schedule creates, to the schedule's ID.
+const ScheduleLabel = "schedule"
+
+// CreateSchedule stores a schedule after checking its cron expression,
+// timezone and job template.
+func (h *Handlers) CreateSchedule(w http.ResponseWriter, r *http.Request) {
+	ServerLogger.Info("Received create schedule request", "remote_addr", r.RemoteAddr)
+
+	var body struct {
+		Name     string             `json:"name"`
+		Cron     string             `json:"cron"`
+		Timezone string             `json:"timezone"`
+		Overlap  jobs.OverlapPolicy `json:"overlap"`
+		Job      jobs.JobRequest    `json:"job"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&body); err != nil {
+		ServerLogger.Error("Failed to decode schedule request body", "error", err, "remote_addr", r.RemoteAddr)
+		http.Error(w, "invalid request body", http.StatusBadRequest)
+		return
+	}
+
+	sc := &jobs.Schedule{
+		Name:     body.Name,
+		Cron:     body.Cron,
+		Timezone: body.Timezone,
+		Overlap:  body.Overlap,
+		Job:      body.Job,
+	}
+	if err := sc.Validate(); err != nil {
+		ServerLogger.Warn("Invalid schedule", "error", err, "cron", sc.Cron, "timezone", sc.Timezone)
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	now := time.Now()
+	template, err := sc.Job.NewJob(now)
+	if err == nil {
+		err = h.checkJob(template)
+	}
+	if err != nil {
+		ServerLogger.Warn("Invalid schedule job template", "error", err, "command", sc.Job.Command)
+		http.Error(w, "invalid job: "+err.Error(), http.StatusBadRequest)
+		return
+	}
+	sc.NextRunAt, _ = sc.Next(now)
+
+	if err := h.JobStore.CreateSchedule(sc); err != nil {
+		ServerLogger.Error("Failed to create schedule in database", "error", err)
+		http.Error(w, "failed to create schedule", http.StatusInternalServerError)
+		return
+	}
+
+	ServerLogger.Info("Schedule created", "schedule_id", sc.ID, "cron", sc.Cron, "timezone", sc.Timezone, "next_run_at", sc.NextRunAt)
+
+	w.Header().Set("Content-Type", "application/json")
+	if err := json.NewEncoder(w).Encode(sc); err != nil {
+		ServerLogger.Error("Failed to encode schedule response", "error", err, "schedule_id", sc.ID)
+	}
+}
+
+func (h *Handlers) ListSchedules(w http.ResponseWriter, r *http.Request) {
+	ServerLogger.Info("Received list schedules request", "remote_addr", r.RemoteAddr)
+
+	schedules, err := h.JobStore.ListSchedules()
+	if err != nil {
+		ServerLogger.Error("Failed to list schedules", "error", err)
+		http.Error(w, "failed to list schedules", http.StatusInternalServerError)
+		return
+	}
+	if schedules == nil {
+		schedules = []*jobs.Schedule{}
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	if err := json.NewEncoder(w).Encode(map[string]any{"schedules": schedules}); err != nil {
+		ServerLogger.Error("Failed to encode schedules response", "error", err)
+	}
+}
+
+func (h *Handlers) GetSchedule(w http.ResponseWriter, r *http.Request) {
+	id := mux.Vars(r)["id"]
+	ServerLogger.Info("Received get schedule request", "schedule_id", id, "remote_addr", r.RemoteAddr)
+
+	sc, err := h.JobStore.GetSchedule(id)
+	if err != nil {
+		http.Error(w, "schedule not found", http.StatusNotFound)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	if err := json.NewEncoder(w).Encode(sc); err != nil {
+		ServerLogger.Error("Failed to encode schedule response", "error", err, "schedule_id", id)
+	}
+}
+
+// DeleteSchedule stops a schedule. Jobs it already created carry on.
+func (h *Handlers) DeleteSchedule(w http.ResponseWriter, r *http.Request) {
+	id := mux.Vars(r)["id"]
+	ServerLogger.Info("Received delete schedule request", "schedule_id", id, "remote_addr", r.RemoteAddr)
+
+	if err := h.JobStore.DeleteSchedule(id); err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			http.Error(w, "schedule not found", http.StatusNotFound)
+			return
+		}
+		http.Error(w, "failed to delete schedule", http.StatusInternalServerError)
+		return
+	}
+
+	ServerLogger.Info("Schedule deleted", "schedule_id", id)
+
+	w.Header().Set("Content-Type", "application/json")
+	if err := json.NewEncoder(w).Encode(map[string]string{"deleted": id}); err != nil {
+		ServerLogger.Error("Failed to encode delete schedule response", "error", err, "schedule_id", id)
+	}
+}
+
+// StartScheduler fires due schedules every interval until ctx is done.
+func (h *Handlers) StartScheduler(ctx context.Context, interval time.Duration) {
+	ServerLogger.Info("Starting scheduler", "interval", interval)
+	go func() {
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-ctx.Done():
+				ServerLogger.Info("Scheduler shutting down")
+				return
+			case now := <-ticker.C:
+				due, err := h.JobStore.DueSchedules(now)
+				if err != nil {
+					continue
+				}
+				for _, sc := range due {
+					h.fireSchedule(ctx, sc, now)
+				}
+			}
+		}
+	}()
+}
+
+// fireSchedule runs one due schedule and moves it on to its next run. Runs
+// missed while no server was up are caught up with a single job. Servers
+// share the work through a claim on each run, so only one of them creates
+// the job.
+func (h *Handlers) fireSchedule(ctx context.Context, sc *jobs.Schedule, now time.Time) {
+	next, err := sc.Next(now)
+	if err != nil {
+		ServerLogger.Error("Failed to compute next schedule run", "error", err, "schedule_id", sc.ID)
+		return
+	}
+
+	claimed, err := h.Client.ClaimScheduleRun(ctx, sc.ID, sc.NextRunAt)
+	if err != nil {
+		return
+	}
+	if !claimed {
+		ServerLogger.Info("Schedule run already fired by another server", "schedule_id", sc.ID, "run_at", sc.NextRunAt)
+		h.JobStore.AdvanceSchedule(sc.ID, next, nil, "")
+		return
+	}
+
+	job := h.runSchedule(sc, now)
+	if job == nil {
+		h.JobStore.AdvanceSchedule(sc.ID, next, nil, "")
+		return
+	}
+	if err := h.JobStore.AdvanceSchedule(sc.ID, next, &now, job.ID); err == nil {
+		ServerLogger.Info("Schedule fired", "schedule_id", sc.ID, "job_id", job.ID, "next_run_at", next)
+	}
+}
+
+// runSchedule applies the overlap policy and creates the schedule's job
+// the same way POST /jobs does. It returns nil when no job was created.
+func (h *Handlers) runSchedule(sc *jobs.Schedule, now time.Time) *jobs.Job {
+	req := sc.Job
+	req.Labels = make(map[string]string, len(sc.Job.Labels)+1)
+	maps.Copy(req.Labels, sc.Job.Labels)
+	req.Labels[ScheduleLabel] = sc.ID
+
+	if sc.LastJobID != "" {
+		prev, err := h.JobStore.GetJob(sc.LastJobID)
+		if err == nil && !prev.Status.IsTerminal() {
+			switch sc.Overlap {
+			case jobs.OverlapSkip:
+				ServerLogger.Info("Skipping schedule run, previous job still active", "schedule_id", sc.ID, "job_id", prev.ID, "status", prev.Status)
+				return nil
+			case jobs.OverlapQueue:
+				ServerLogger.Info("Queueing schedule run behind previous job", "schedule_id", sc.ID, "job_id", prev.ID, "status", prev.Status)
+				req.DependsOn = []jobs.Dependency{{JobID: prev.ID, Condition: jobs.OnCompletion}}
+			case jobs.OverlapCancelPrevious:
+				ServerLogger.Info("Cancelling previous scheduled job", "schedule_id", sc.ID, "job_id", prev.ID, "status", prev.Status)
+				if _, err := h.cancelJob(prev.ID, "replaced by next run of schedule "+sc.ID); err != nil {
+					ServerLogger.Warn("Failed to cancel previous scheduled job", "error", err, "schedule_id", sc.ID, "job_id", prev.ID)
+				}
+			}
+		}
+	}
+
+	job, err := req.NewJob(now)
+	if err == nil {
+		// Secrets, runtimes or GPUs may have gone since the schedule was
+		// created.
+		err = h.checkJob(job)
+	}
+	if err != nil {
+		ServerLogger.Error("Schedule job template is no longer valid", "error", err, "schedule_id", sc.ID)
+		return nil
+	}
+	if err := h.submitJob(job); err != nil {
+		return nil
+	}
+	return job
+}
@@ -0,0 +1,124 @@
+package api
+
+import (
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+
+	"gpu-runner/internal/jobs"
+
+	"github.com/gorilla/mux"
+)
+
+// MaxSecretBytes caps the size of one secret value.
+const MaxSecretBytes = 64 * 1024
+
+// checkSecrets rejects a new job that refers to secrets that don't exist.
+// They are looked up again when the job starts.
+func (h *Handlers) checkSecrets(job *jobs.Job) error {
+	names := job.SecretNames()
+	if len(names) == 0 {
+		return nil
+	}
+	if h.Secrets == nil {
+		return errors.New("secrets: this server has no secret store configured")
+	}
+	missing, err := h.Secrets.MissingSecrets(names)
+	if err != nil {
+		return fmt.Errorf("secrets: %w", err)
+	}
+	if len(missing) > 0 {
+		return fmt.Errorf("secrets: %s not found", strings.Join(missing, ", "))
+	}
+	return nil
+}
+
+// PutSecret creates or replaces a secret. The request body is never
+// logged.
+func (h *Handlers) PutSecret(w http.ResponseWriter, r *http.Request) {
+	name := mux.Vars(r)["name"]
+	ServerLogger.Info("Received put secret request", "name", name, "remote_addr", r.RemoteAddr)
+	if h.Secrets == nil {
+		http.Error(w, "secret store not configured", http.StatusServiceUnavailable)
+		return
+	}
+	if err := jobs.ValidateSecretName(name); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	var body struct {
+		Value string `json:"value"`
+	}
+	if err := json.NewDecoder(http.MaxBytesReader(w, r.Body, MaxSecretBytes+1024)).Decode(&body); err != nil {
+		ServerLogger.Error("Failed to decode secret request body", "error", err, "name", name, "remote_addr", r.RemoteAddr)
+		http.Error(w, "invalid request body", http.StatusBadRequest)
+		return
+	}
+	if body.Value == "" || len(body.Value) > MaxSecretBytes {
+		http.Error(w, fmt.Sprintf("secret value must be 1 to %d bytes", MaxSecretBytes), http.StatusBadRequest)
+		return
+	}
+
+	if err := h.Secrets.PutSecret(name, body.Value); err != nil {
+		http.Error(w, "failed to store secret", http.StatusInternalServerError)
+		return
+	}
+	ServerLogger.Info("Secret stored", "name", name)
+
+	w.Header().Set("Content-Type", "application/json")
+	if err := json.NewEncoder(w).Encode(map[string]string{"stored": name}); err != nil {
+		ServerLogger.Error("Failed to encode put secret response", "error", err, "name", name)
+	}
+}
+
+// ListSecrets returns the stored secrets' names, never their values.
+func (h *Handlers) ListSecrets(w http.ResponseWriter, r *http.Request) {
+	ServerLogger.Info("Received list secrets request", "remote_addr", r.RemoteAddr)
+	if h.Secrets == nil {
+		http.Error(w, "secret store not configured", http.StatusServiceUnavailable)
+		return
+	}
+
+	list, err := h.Secrets.ListSecrets()
+	if err != nil {
+		ServerLogger.Error("Failed to list secrets", "error", err)
+		http.Error(w, "failed to list secrets", http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	if err := json.NewEncoder(w).Encode(map[string]any{"secrets": list}); err != nil {
+		ServerLogger.Error("Failed to encode secrets response", "error", err)
+	}
+}
+
+// DeleteSecret removes a secret. Jobs still referring to it fail when
+// they start.
+func (h *Handlers) DeleteSecret(w http.ResponseWriter, r *http.Request) {
+	name := mux.Vars(r)["name"]
+	ServerLogger.Info("Received delete secret request", "name", name, "remote_addr", r.RemoteAddr)
+	if h.Secrets == nil {
+		http.Error(w, "secret store not configured", http.StatusServiceUnavailable)
+		return
+	}
+
+	if err := h.Secrets.DeleteSecret(name); err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			http.Error(w, "secret not found", http.StatusNotFound)
+			return
+		}
+		ServerLogger.Error("Failed to delete secret", "error", err, "name", name)
+		http.Error(w, "failed to delete secret", http.StatusInternalServerError)
+		return
+	}
+	ServerLogger.Info("Secret deleted", "name", name)
+
+	w.Header().Set("Content-Type", "application/json")
+	if err := json.NewEncoder(w).Encode(map[string]string{"deleted": name}); err != nil {
+		ServerLogger.Error("Failed to encode delete secret response", "error", err, "name", name)
+	}
+}
@@ -0,0 +1,107 @@
+package api
+
+import (
+	"encoding/json"
+	"net/http"
+	"time"
+
+	"gpu-runner/internal/jobs"
+
+	"github.com/gorilla/mux"
+)
+
+// workflowView is a workflow with its jobs and their aggregate status.
+type workflowView struct {
+	*jobs.Workflow
+	Status jobs.JobStatus         `json:"status"`
+	Counts map[jobs.JobStatus]int `json:"counts"`
+	Jobs   []*jobs.Job            `json:"jobs"`
+}
+
+func newWorkflowView(wf *jobs.Workflow, list []*jobs.Job) workflowView {
+	counts := make(map[jobs.JobStatus]int)
+	for _, j := range list {
+		counts[j.Status]++
+	}
+	if list == nil {
+		list = []*jobs.Job{}
+	}
+	return workflowView{Workflow: wf, Status: jobs.AggregateStatus(counts), Counts: counts, Jobs: list}
+}
+
+// CreateWorkflow submits a DAG of jobs. Steps are stored parents first so
+// each dependent can refer to its parents' job IDs; steps without
+// dependencies are queued straight away.
+func (h *Handlers) CreateWorkflow(w http.ResponseWriter, r *http.Request) {
+	ServerLogger.Info("Received create workflow request", "remote_addr", r.RemoteAddr)
+
+	var req jobs.WorkflowRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		ServerLogger.Error("Failed to decode workflow request body", "error", err, "remote_addr", r.RemoteAddr)
+		http.Error(w, "invalid request body", http.StatusBadRequest)
+		return
+	}
+
+	list, err := req.NewJobs(time.Now())
+	if err != nil {
+		ServerLogger.Warn("Invalid workflow", "error", err, "name", req.Name)
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	for _, job := range list {
+		if err := h.checkJob(job); err != nil {
+			ServerLogger.Warn("Invalid workflow step", "error", err, "step", job.WorkflowStep)
+			http.Error(w, "step "+job.WorkflowStep+": "+err.Error(), http.StatusBadRequest)
+			return
+		}
+	}
+
+	wf := &jobs.Workflow{Name: req.Name}
+	if err := h.JobStore.CreateWorkflow(wf); err != nil {
+		ServerLogger.Error("Failed to create workflow in database", "error", err)
+		http.Error(w, "failed to create workflow", http.StatusInternalServerError)
+		return
+	}
+	ServerLogger.Info("Workflow created", "workflow_id", wf.ID, "name", wf.Name, "steps", len(list))
+
+	ids := make(map[string]string, len(list))
+	for _, job := range list {
+		req.Link(job, ids)
+		job.WorkflowID = wf.ID
+		if err := h.submitJob(job); err != nil {
+			ServerLogger.Error("Failed to submit workflow step", "error", err, "workflow_id", wf.ID, "step", job.WorkflowStep)
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+		ids[job.WorkflowStep] = job.ID
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	if err := json.NewEncoder(w).Encode(newWorkflowView(wf, list)); err != nil {
+		ServerLogger.Error("Failed to encode workflow response", "error", err, "workflow_id", wf.ID)
+	}
+}
+
+func (h *Handlers) GetWorkflow(w http.ResponseWriter, r *http.Request) {
+	id := mux.Vars(r)["id"]
+	ServerLogger.Info("Received get workflow request", "workflow_id", id, "remote_addr", r.RemoteAddr)
+
+	wf, err := h.JobStore.GetWorkflow(id)
+	if err != nil {
+		http.Error(w, "workflow not found", http.StatusNotFound)
+		return
+	}
+	list, err := h.JobStore.WorkflowJobs(id)
+	if err != nil {
+		http.Error(w, "failed to list workflow jobs", http.StatusInternalServerError)
+		return
+	}
+
+	view := newWorkflowView(wf, list)
+	ServerLogger.Info("Successfully fetched workflow", "workflow_id", id, "status", view.Status, "jobs", len(list))
+
+	w.Header().Set("Content-Type", "application/json")
+	if err := json.NewEncoder(w).Encode(view); err != nil {
+		ServerLogger.Error("Failed to encode workflow response", "error", err, "workflow_id", id)
+	}
+}
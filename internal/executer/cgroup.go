@@ -0,0 +1,63 @@
+package executer
+
+import (
+	"fmt"
+
+	"gpu-runner/internal/logger"
+)
+
+// CgroupParent is the cgroup v2 directory each job gets its own cgroup
+// under. The server must be able to create it and must not run inside it.
+var CgroupParent = "/sys/fs/cgroup/gpu-runner"
+
+// Limits caps the resources a job's processes may use together. A zero
+// field leaves that resource unlimited.
+type Limits struct {
+	// CPU is a number of cores, and may be fractional.
+	CPU         float64
+	MemoryBytes int64
+	MaxPids     int
+}
+
+// IsZero reports whether no limit is set.
+func (l Limits) IsZero() bool {
+	return l == Limits{}
+}
+
+// usage is what a job's cgroup recorded about its processes.
+type usage struct {
+	OOMKilled       bool
+	PeakMemoryBytes int64
+	CPUSeconds      float64
+}
+
+// newJobCgroup creates the cgroup jobID runs in. When that isn't possible
+// the job runs without one, and the job log says so if the job asked for
+// limits that now won't be enforced.
+func (e *Local) newJobCgroup(jobID string, limits Limits, jobLogger *logger.JobLogger) *jobCgroup {
+	cg, err := e.cgroups.create(jobID, limits)
+	if err == nil {
+		jobLogger.Info("Created job cgroup",
+			logger.Item("cgroup", cg.path),
+			logger.Item("cpu", limits.CPU),
+			logger.Item("memory_bytes", limits.MemoryBytes),
+			logger.Item("max_pids", limits.MaxPids))
+		return cg
+	}
+	if e.cgroups.err == nil {
+		executorLogger.Warn("Failed to create job cgroup; running the job without one", "job_id", jobID, "error", err)
+	}
+	if !limits.IsZero() {
+		jobLogger.Warn("Resource limits are not enforced on this host", logger.Item("reason", err.Error()))
+	}
+	return nil
+}
+
+// oomError describes a run that failed after the kernel killed one of its
+// processes for exceeding the memory limit.
+func oomError(limits Limits, exitCode string, command Command) error {
+	if limits.MemoryBytes > 0 {
+		return fmt.Errorf("out of memory: exceeded the %d byte memory limit (exit %s): %s", limits.MemoryBytes, exitCode, command)
+	}
+	return fmt.Errorf("out of memory (exit %s): %s", exitCode, command)
+}
@@ -0,0 +1,211 @@
+//go:build linux
+
+package executer
+
+import (
+	"bufio"
+	"bytes"
+	"errors"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"slices"
+	"strconv"
+	"strings"
+	"syscall"
+	"time"
+)
+
+// cgroupControllers are the cgroup v2 controllers job limits need.
+var cgroupControllers = []string{"cpu", "memory", "pids"}
+
+// cpuPeriod is the cpu.max period in microseconds; a job's quota is its
+// CPU limit times this.
+const cpuPeriod = 100000
+
+// cgroups creates the per-job cgroups under one parent directory. err says
+// why jobs can't get cgroups on this host, and is nil when they can.
+type cgroups struct {
+	parent string
+	err    error
+}
+
+// setupCgroups prepares parent for job cgroups: it must be on a cgroup v2
+// hierarchy with the cpu, memory and pids controllers available, which are
+// then delegated to the job cgroups below it.
+func setupCgroups(parent string) *cgroups {
+	c := &cgroups{parent: parent}
+	c.err = c.setup()
+	return c
+}
+
+func (c *cgroups) setup() error {
+	if c.parent == "" {
+		return errors.New("no cgroup parent is configured")
+	}
+	root := filepath.Dir(c.parent)
+	if _, err := os.Stat(filepath.Join(root, "cgroup.controllers")); err != nil {
+		return fmt.Errorf("%s is not on a cgroup v2 hierarchy", root)
+	}
+	if err := os.MkdirAll(c.parent, 0o755); err != nil {
+		return fmt.Errorf("create cgroup %s: %w", c.parent, err)
+	}
+	// The parent only gets the controllers its own parent delegates. This
+	// fails harmlessly when they are already enabled or can't be.
+	for _, name := range cgroupControllers {
+		os.WriteFile(filepath.Join(root, "cgroup.subtree_control"), []byte("+"+name), 0)
+	}
+
+	data, err := os.ReadFile(filepath.Join(c.parent, "cgroup.controllers"))
+	if err != nil {
+		return err
+	}
+	available := strings.Fields(string(data))
+	var missing []string
+	for _, name := range cgroupControllers {
+		if !slices.Contains(available, name) {
+			missing = append(missing, name)
+		}
+	}
+	if len(missing) > 0 {
+		return fmt.Errorf("cgroup controllers %s are not available in %s", strings.Join(missing, ", "), c.parent)
+	}
+
+	enable := "+" + strings.Join(cgroupControllers, " +")
+	if err := os.WriteFile(filepath.Join(c.parent, "cgroup.subtree_control"), []byte(enable), 0); err != nil {
+		return fmt.Errorf("enable cgroup controllers in %s: %w", c.parent, err)
+	}
+	return nil
+}
+
+// jobCgroup is the cgroup one run of a job is started in. dir stays open
+// so the process can be cloned straight into it.
+type jobCgroup struct {
+	path string
+	dir  *os.File
+}
+
+// create makes the cgroup for jobID and applies limits to it.
+func (c *cgroups) create(jobID string, limits Limits) (*jobCgroup, error) {
+	if c.err != nil {
+		return nil, c.err
+	}
+	path := filepath.Join(c.parent, "job-"+jobID)
+	err := os.Mkdir(path, 0o755)
+	if errors.Is(err, os.ErrExist) {
+		// Left behind by an earlier run of the job that was interrupted.
+		if err := removeCgroup(path); err != nil {
+			return nil, err
+		}
+		err = os.Mkdir(path, 0o755)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("create cgroup: %w", err)
+	}
+
+	if err := applyLimits(path, limits); err != nil {
+		removeCgroup(path)
+		return nil, err
+	}
+	dir, err := os.Open(path)
+	if err != nil {
+		removeCgroup(path)
+		return nil, err
+	}
+	return &jobCgroup{path: path, dir: dir}, nil
+}
+
+// applyLimits writes limits to the cgroup at path.
+func applyLimits(path string, limits Limits) error {
+	var settings [][2]string
+	if limits.MemoryBytes > 0 {
+		settings = append(settings, [2]string{"memory.max", strconv.FormatInt(limits.MemoryBytes, 10)})
+	}
+	if limits.CPU > 0 {
+		quota := max(int64(limits.CPU*cpuPeriod), 1000)
+		settings = append(settings, [2]string{"cpu.max", fmt.Sprintf("%d %d", quota, cpuPeriod)})
+	}
+	if limits.MaxPids > 0 {
+		settings = append(settings, [2]string{"pids.max", strconv.Itoa(limits.MaxPids)})
+	}
+	for _, s := range settings {
+		if err := os.WriteFile(filepath.Join(path, s[0]), []byte(s[1]), 0); err != nil {
+			return fmt.Errorf("set cgroup %s to %s: %w", s[0], s[1], err)
+		}
+	}
+	if limits.MemoryBytes > 0 {
+		// Without this the memory limit only pushes the job into swap.
+		// Hosts without swap accounting have no such file.
+		os.WriteFile(filepath.Join(path, "memory.swap.max"), []byte("0"), 0)
+	}
+	return nil
+}
+
+// attach makes cmd start inside the cgroup.
+func (g *jobCgroup) attach(cmd *exec.Cmd) {
+	if cmd.SysProcAttr == nil {
+		cmd.SysProcAttr = &syscall.SysProcAttr{}
+	}
+	cmd.SysProcAttr.UseCgroupFD = true
+	cmd.SysProcAttr.CgroupFD = int(g.dir.Fd())
+}
+
+// finish reads what the cgroup recorded about the run, then kills anything
+// still in it and removes it.
+func (g *jobCgroup) finish() usage {
+	g.dir.Close()
+	var u usage
+	u.OOMKilled = readCgroupStat(g.path, "memory.events", "oom_kill") > 0
+	if data, err := os.ReadFile(filepath.Join(g.path, "memory.peak")); err == nil {
+		u.PeakMemoryBytes, _ = strconv.ParseInt(strings.TrimSpace(string(data)), 10, 64)
+	}
+	u.CPUSeconds = float64(readCgroupStat(g.path, "cpu.stat", "usage_usec")) / 1e6
+	if err := removeCgroup(g.path); err != nil {
+		executorLogger.Warn("Failed to remove job cgroup", "cgroup", g.path, "error", err)
+	}
+	return u
+}
+
+// sample returns the cgroup's current memory use and the CPU time it has
+// used so far.
+func (g *jobCgroup) sample() (int64, float64) {
+	var memory int64
+	if data, err := os.ReadFile(filepath.Join(g.path, "memory.current")); err == nil {
+		memory, _ = strconv.ParseInt(strings.TrimSpace(string(data)), 10, 64)
+	}
+	return memory, float64(readCgroupStat(g.path, "cpu.stat", "usage_usec")) / 1e6
+}
+
+// readCgroupStat returns the value of key in a flat-keyed cgroup file such
+// as memory.events, or 0 if it can't be read.
+func readCgroupStat(path, file, key string) int64 {
+	data, err := os.ReadFile(filepath.Join(path, file))
+	if err != nil {
+		return 0
+	}
+	scanner := bufio.NewScanner(bytes.NewReader(data))
+	for scanner.Scan() {
+		name, value, ok := strings.Cut(scanner.Text(), " ")
+		if ok && name == key {
+			n, _ := strconv.ParseInt(value, 10, 64)
+			return n
+		}
+	}
+	return 0
+}
+
+// removeCgroup kills every process left in the cgroup at path and removes
+// it, waiting briefly for the killed processes to go away.
+func removeCgroup(path string) error {
+	os.WriteFile(filepath.Join(path, "cgroup.kill"), []byte("1"), 0)
+	var err error
+	for range 50 {
+		err = os.Remove(path)
+		if err == nil || errors.Is(err, os.ErrNotExist) {
+			return nil
+		}
+		time.Sleep(20 * time.Millisecond)
+	}
+	return err
+}
@@ -0,0 +1,36 @@
+//go:build !linux
+
+package executer
+
+import (
+	"errors"
+	"os/exec"
+)
+
+// cgroups is a stand-in for the Linux implementation: jobs on other
+// systems run without resource limits.
+type cgroups struct {
+	err error
+}
+
+func setupCgroups(parent string) *cgroups {
+	return &cgroups{err: errors.New("cgroups are only available on Linux")}
+}
+
+type jobCgroup struct {
+	path string
+}
+
+func (c *cgroups) create(jobID string, limits Limits) (*jobCgroup, error) {
+	return nil, c.err
+}
+
+func (g *jobCgroup) attach(cmd *exec.Cmd) {}
+
+func (g *jobCgroup) finish() usage {
+	return usage{}
+}
+
+func (g *jobCgroup) sample() (int64, float64) {
+	return 0, 0
+}
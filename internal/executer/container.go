@@ -0,0 +1,204 @@
+package executer
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"os/exec"
+	"path"
+	"slices"
+	"strconv"
+	"strings"
+
+	"gpu-runner/internal/logger"
+)
+
+// ContainerRuntime is the name the Container executor is registered under.
+const ContainerRuntime = "container"
+
+// ContainerWorkspace is where the job's workspace is mounted inside its
+// container. A relative Command.Dir is resolved against it.
+const ContainerWorkspace = "/workspace"
+
+// ContainerShell runs shell-string commands in containers when the job
+// doesn't name a shell. Unlike DefaultShell it has to exist in whatever
+// image the job picked, and many images have no bash.
+var ContainerShell = "sh"
+
+// Container is the Executor that runs each job in an OCI container
+// created from the job's image, with the job's workspace bind-mounted at
+// ContainerWorkspace. It drives a container CLI that understands docker's
+// run, inspect and rm commands: docker, podman or nerdctl, any of which
+// can be set up to use runc or crun underneath.
+type Container struct {
+	// Binary is the container CLI.
+	Binary string
+	// Host, when set, is the Docker-compatible API socket the CLI talks
+	// to, e.g. unix:///run/podman/podman.sock.
+	Host string
+
+	runs runSet
+}
+
+// NewContainer returns a container executor using binary, which is looked
+// up on PATH unless it is a path.
+func NewContainer(binary, host string) (*Container, error) {
+	resolved, err := exec.LookPath(binary)
+	if err != nil {
+		return nil, fmt.Errorf("container runtime: %w", err)
+	}
+	executorLogger.Info("Container runtime available", "binary", resolved, "host", host)
+	return &Container{Binary: resolved, Host: host, runs: newRunSet()}, nil
+}
+
+// Start runs the job's container in the background. The CLI's stdout and
+// stderr, which carry the container's, are streamed into the job log.
+func (c *Container) Start(ctx context.Context, spec Spec) error {
+	if spec.Image == "" {
+		return fmt.Errorf("job %s has no image to run on the %s runtime", spec.JobID, ContainerRuntime)
+	}
+	return c.runs.start(ctx, spec.JobID, func(ctx context.Context, run *processRun) (Result, error) {
+		return c.run(ctx, spec, run)
+	})
+}
+
+func (c *Container) Wait(jobID string) (Result, error) {
+	return c.runs.wait(jobID)
+}
+
+// Stats reports the container CLI's process. Resource usage is left to
+// the container engine.
+func (c *Container) Stats(jobID string) (Stats, error) {
+	stats, _, err := c.runs.stats(jobID, ContainerRuntime)
+	return stats, err
+}
+
+func (c *Container) Cancel(jobID string) error {
+	return c.runs.cancel(jobID)
+}
+
+// run runs the container in the foreground of the CLI, so that stopping
+// the CLI stops the container: the CLI passes SIGTERM on to it, and the
+// container is removed once the CLI has exited, however that happened.
+func (c *Container) run(ctx context.Context, spec Spec, run *processRun) (Result, error) {
+	jobLogger := spec.Logger
+	name := containerName(spec.JobID)
+
+	// A container left behind by an earlier attempt, e.g. when the server
+	// died mid-run, would hold the name.
+	c.remove(name)
+
+	spec.Env = append(slices.Clip(spec.Env), gpuEnv(spec.GPUs, true)...)
+	args := c.runArgs(spec, name)
+	cmd := exec.CommandContext(ctx, c.Binary, args...)
+	cmd.Env = c.environ(spec.Env)
+
+	jobLogger.Info("Starting job container",
+		logger.Item("image", spec.Image),
+		logger.Item("container", name),
+		logger.Item("command", spec.Command.String()),
+		logger.Item("gpus", spec.GPUs),
+		logger.Item("runtime_binary", c.Binary))
+	executorLogger.Info("Starting job container", "job_id", spec.JobID, "image", spec.Image, "container", name)
+
+	return runProcess(ctx, spec, cmd, run, func(result *Result) {
+		oomKilled, err := c.inspectOOM(name)
+		if err != nil {
+			executorLogger.Warn("Failed to inspect job container", "job_id", spec.JobID, "container", name, "error", err)
+		}
+		result.OOMKilled = oomKilled
+		if err := c.remove(name); err != nil {
+			executorLogger.Warn("Failed to remove job container", "job_id", spec.JobID, "container", name, "error", err)
+		}
+	})
+}
+
+// runArgs builds the CLI's run command. Job environment variables are
+// passed by name only, with their values in the CLI's own environment,
+// so secrets don't show up in the process table.
+func (c *Container) runArgs(spec Spec, name string) []string {
+	command := spec.Command
+	dir := ContainerWorkspace
+	if command.Dir != "" {
+		dir = path.Join(ContainerWorkspace, command.Dir)
+		if path.IsAbs(command.Dir) {
+			dir = command.Dir
+		}
+	}
+
+	args := []string{"run", "--name", name, "--init",
+		"--label", "gpu-runner.job-id=" + spec.JobID,
+		"--volume", spec.VolumePath + ":" + ContainerWorkspace,
+		"--workdir", dir,
+	}
+	if command.Limits.CPU > 0 {
+		args = append(args, "--cpus", strconv.FormatFloat(command.Limits.CPU, 'f', -1, 64))
+	}
+	if command.Limits.MemoryBytes > 0 {
+		memory := strconv.FormatInt(command.Limits.MemoryBytes, 10) + "b"
+		args = append(args, "--memory", memory, "--memory-swap", memory)
+	}
+	if command.Limits.MaxPids > 0 {
+		args = append(args, "--pids-limit", strconv.Itoa(command.Limits.MaxPids))
+	}
+	// Containers are sandboxed anyway; only the network is up to the job.
+	if spec.Sandbox.NoNetwork {
+		args = append(args, "--network", "none")
+	}
+	if len(spec.GPUs) > 0 {
+		args = append(args, "--gpus", containerGPUs(spec.GPUs))
+	}
+	seen := make(map[string]bool)
+	for _, kv := range spec.Env {
+		key, _, _ := strings.Cut(kv, "=")
+		if !seen[key] {
+			seen[key] = true
+			args = append(args, "--env", key)
+		}
+	}
+
+	args = append(args, spec.Image)
+	if len(command.Argv) > 0 {
+		return append(args, command.Argv...)
+	}
+	shell := command.Shell
+	if shell == "" {
+		shell = ContainerShell
+	}
+	return append(args, shell, "-c", command.Script)
+}
+
+// environ is the CLI's environment: the server's, pointed at Host, with
+// the job's variables for the CLI to pass on.
+func (c *Container) environ(jobEnv []string) []string {
+	env := os.Environ()
+	if c.Host != "" {
+		env = append(env, "DOCKER_HOST="+c.Host, "CONTAINER_HOST="+c.Host)
+	}
+	return append(env, jobEnv...)
+}
+
+// inspectOOM reports whether the engine OOM-killed the container.
+func (c *Container) inspectOOM(name string) (bool, error) {
+	cmd := exec.Command(c.Binary, "inspect", "--format", "{{.State.OOMKilled}}", name)
+	cmd.Env = c.environ(nil)
+	out, err := cmd.Output()
+	if err != nil {
+		return false, err
+	}
+	return strings.TrimSpace(string(out)) == "true", nil
+}
+
+// remove force-removes the container if it exists.
+func (c *Container) remove(name string) error {
+	cmd := exec.Command(c.Binary, "rm", "--force", name)
+	cmd.Env = c.environ(nil)
+	if out, err := cmd.CombinedOutput(); err != nil {
+		return fmt.Errorf("%w: %s", err, strings.TrimSpace(string(out)))
+	}
+	return nil
+}
+
+func containerName(jobID string) string {
+	return "gpu-runner-job-" + jobID
+}
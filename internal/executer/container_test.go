@@ -0,0 +1,181 @@
+package executer
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+	"slices"
+	"strconv"
+	"strings"
+	"testing"
+
+	"gpu-runner/internal/logger"
+)
+
+// stubRuntime is a container CLI on PATH that records the arguments of
+// every call. Its run exits with runExit, and inspect reports oomKilled.
+type stubRuntime struct {
+	dir string
+}
+
+func newStubRuntime(t *testing.T, runExit int, oomKilled bool) *stubRuntime {
+	t.Helper()
+	dir := t.TempDir()
+	if err := os.Mkdir(filepath.Join(dir, "calls"), 0o755); err != nil {
+		t.Fatal(err)
+	}
+	script := fmt.Sprintf(`#!/bin/sh
+dir=%q
+n=$(ls "$dir/calls" | wc -l)
+printf '%%s\0' "$@" > "$dir/calls/$((n))"
+case "$1" in
+run)
+	printf '%%s' "$JOB_TOKEN" > "$dir/token"
+	echo started
+	exit %d
+	;;
+inspect)
+	echo %t
+	;;
+esac
+`, dir, runExit, oomKilled)
+	if err := os.WriteFile(filepath.Join(dir, "ctr"), []byte(script), 0o755); err != nil {
+		t.Fatal(err)
+	}
+	t.Setenv("PATH", dir+string(os.PathListSeparator)+os.Getenv("PATH"))
+	return &stubRuntime{dir: dir}
+}
+
+// calls returns the arguments of each call, in order.
+func (s *stubRuntime) calls(t *testing.T) [][]string {
+	t.Helper()
+	entries, err := os.ReadDir(filepath.Join(s.dir, "calls"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	calls := make([][]string, len(entries))
+	for _, e := range entries {
+		i, err := strconv.Atoi(e.Name())
+		if err != nil || i >= len(calls) {
+			t.Fatalf("unexpected call record %q", e.Name())
+		}
+		data, err := os.ReadFile(filepath.Join(s.dir, "calls", e.Name()))
+		if err != nil {
+			t.Fatal(err)
+		}
+		calls[i] = strings.Split(strings.TrimSuffix(string(data), "\x00"), "\x00")
+	}
+	return calls
+}
+
+func runContainer(t *testing.T, spec Spec) (Result, error) {
+	t.Helper()
+	c, err := NewContainer("ctr", "")
+	if err != nil {
+		t.Fatalf("NewContainer: %v", err)
+	}
+	spec.Logger = *logger.NewJobLogger(context.Background(), spec.JobID, discardSink{})
+	if err := c.Start(context.Background(), spec); err != nil {
+		t.Fatalf("Start: %v", err)
+	}
+	return c.Wait(spec.JobID)
+}
+
+// hasFlag reports whether args contain flag immediately followed by value.
+func hasFlag(args []string, flag, value string) bool {
+	for i := 0; i+1 < len(args); i++ {
+		if args[i] == flag && args[i+1] == value {
+			return true
+		}
+	}
+	return false
+}
+
+func TestContainerRunArgs(t *testing.T) {
+	stub := newStubRuntime(t, 0, false)
+	volume := t.TempDir()
+	spec := Spec{
+		JobID:      "7",
+		Image:      "pytorch:latest",
+		Command:    Command{Script: "python train.py", Limits: Limits{CPU: 1.5, MemoryBytes: 1 << 30, MaxPids: 64}},
+		VolumePath: volume,
+		Env:        []string{"JOB_TOKEN=s3cret"},
+		Sandbox:    Sandbox{NoNetwork: true},
+		GPUs:       []int{0, 2},
+	}
+
+	result, err := runContainer(t, spec)
+	if err != nil {
+		t.Fatalf("Wait: %v", err)
+	}
+	if result.ExitCode != 0 || result.OOMKilled {
+		t.Errorf("result = %+v, want exit 0 and no OOM kill", result)
+	}
+
+	calls := stub.calls(t)
+	if len(calls) != 4 {
+		t.Fatalf("calls = %q, want rm, run, inspect and rm", calls)
+	}
+	run := calls[1]
+	if run[0] != "run" {
+		t.Fatalf("second call = %q, want run", run)
+	}
+	for _, want := range [][2]string{
+		{"--name", "gpu-runner-job-7"},
+		{"--volume", volume + ":" + ContainerWorkspace},
+		{"--workdir", ContainerWorkspace},
+		{"--cpus", "1.5"},
+		{"--memory", "1073741824b"},
+		{"--memory-swap", "1073741824b"},
+		{"--pids-limit", "64"},
+		{"--network", "none"},
+		{"--gpus", `"device=0,2"`},
+		{"--env", "JOB_TOKEN"},
+		{"--env", "CUDA_VISIBLE_DEVICES"},
+	} {
+		if !hasFlag(run, want[0], want[1]) {
+			t.Errorf("run args %q lack %s %s", run, want[0], want[1])
+		}
+	}
+	if tail := run[len(run)-4:]; !slices.Equal(tail, []string{"pytorch:latest", "sh", "-c", "python train.py"}) {
+		t.Errorf("run args end with %q, want the image and the shell command", tail)
+	}
+	for _, arg := range run {
+		if strings.Contains(arg, "s3cret") {
+			t.Errorf("environment value leaked into run args: %q", arg)
+		}
+	}
+	token, err := os.ReadFile(filepath.Join(stub.dir, "token"))
+	if err != nil || string(token) != "s3cret" {
+		t.Errorf("CLI saw JOB_TOKEN=%q (%v), want it in its environment", token, err)
+	}
+}
+
+func TestContainerOOMKilled(t *testing.T) {
+	stub := newStubRuntime(t, 137, true)
+	spec := Spec{
+		JobID:      "8",
+		Image:      "alpine",
+		Command:    Command{Argv: []string{"python", "big.py"}, Limits: Limits{MemoryBytes: 1 << 20}},
+		VolumePath: t.TempDir(),
+	}
+
+	result, err := runContainer(t, spec)
+	if err == nil || !strings.Contains(err.Error(), "out of memory") {
+		t.Errorf("Wait error = %v, want an out of memory error", err)
+	}
+	if !result.OOMKilled || result.ExitCode != 137 {
+		t.Errorf("result = %+v, want OOMKilled with exit 137", result)
+	}
+
+	calls := stub.calls(t)
+	inspect := []string{"inspect", "--format", "{{.State.OOMKilled}}", "gpu-runner-job-8"}
+	rm := []string{"rm", "--force", "gpu-runner-job-8"}
+	if len(calls) != 4 || !slices.Equal(calls[0], rm) || !slices.Equal(calls[2], inspect) || !slices.Equal(calls[3], rm) {
+		t.Fatalf("calls = %q, want rm, run, inspect and rm", calls)
+	}
+	if tail := calls[1][len(calls[1])-3:]; !slices.Equal(tail, []string{"alpine", "python", "big.py"}) {
+		t.Errorf("run args end with %q, want the image and argv without a shell", tail)
+	}
+}
@@ -1,100 +1,313 @@
 package executer
 
 import (
-	"bytes"
 	"context"
+	"errors"
 	"fmt"
 	"os"
 	"os/exec"
-	"sync"
+	"path/filepath"
+	"strings"
+	"syscall"
 	"gpu-runner/internal/logger"
 )
 
 var executorLogger = logger.Server
 
-type Executor struct {
-	cancels map[string]context.CancelFunc
-	mu      sync.RWMutex
+// InheritedEnv lists the server environment variables passed on to jobs.
+// Everything else in the server's environment stays out of them.
+var InheritedEnv = []string{"HOME", "LANG", "LC_ALL", "TZ", "TMPDIR", "LD_LIBRARY_PATH", "CUDA_HOME"}
+
+// baseEnv is the environment every job starts from: the inherited
+// variables, USER, and PATH with the job's volume first.
+func baseEnv(volumePath string) []string {
+	env := make([]string, 0, len(InheritedEnv)+2)
+	for _, name := range InheritedEnv {
+		if v, ok := os.LookupEnv(name); ok && name != "PATH" && name != "USER" {
+			env = append(env, name+"="+v)
+		}
+	}
+	return append(env,
+		"USER=jobrunner",
+		fmt.Sprintf("PATH=%s:%s", volumePath, os.Getenv("PATH")),
+	)
+}
+
+// DefaultShell runs shell-string commands that don't name a shell.
+var DefaultShell = "bash"
+
+// Command is what a job runs: Script through Shell with -c, or, when Argv
+// is set, the program Argv[0] with the rest as its arguments and no shell
+// involved. Dir is the working directory, relative to the job's volume
+// unless absolute. Limits are enforced through the job's cgroup.
+type Command struct {
+	Script string
+	Shell  string
+	Argv   []string
+	Dir    string
+	Limits Limits
+}
+
+// String describes the command for logs and error messages.
+func (c Command) String() string {
+	if len(c.Argv) > 0 {
+		return strings.Join(c.Argv, " ")
+	}
+	return c.Script
 }
 
-func NewExecutor() *Executor {
-	return &Executor{
-		cancels: make(map[string]context.CancelFunc),
+func (c Command) build(ctx context.Context, volumePath string) *exec.Cmd {
+	var cmd *exec.Cmd
+	if len(c.Argv) > 0 {
+		cmd = exec.CommandContext(ctx, c.Argv[0], c.Argv[1:]...)
+	} else {
+		shell := c.Shell
+		if shell == "" {
+			shell = DefaultShell
+		}
+		cmd = exec.CommandContext(ctx, shell, "-c", c.Script)
+	}
+	cmd.Dir = volumePath
+	if c.Dir != "" {
+		cmd.Dir = filepath.Join(volumePath, c.Dir)
+		if filepath.IsAbs(c.Dir) {
+			cmd.Dir = c.Dir
+		}
+	}
+	return cmd
+}
+
+// LocalRuntime is the name of the Local executor in a Registry.
+const LocalRuntime = "local"
+
+// Local is the Executor that runs jobs as processes on this machine.
+type Local struct {
+	runs    runSet
+	cgroups *cgroups
+}
+
+// NewLocal returns an executor that runs each job in its own cgroup under
+// CgroupParent. Without usable cgroups jobs still run, with a warning, but
+// their resource limits aren't enforced.
+func NewLocal() *Local {
+	e := &Local{
+		runs:    newRunSet(),
+		cgroups: setupCgroups(CgroupParent),
+	}
+	if e.cgroups.err != nil {
+		executorLogger.Warn("Cgroups unavailable; job resource limits will not be enforced", "parent", CgroupParent, "reason", e.cgroups.err)
+	} else {
+		executorLogger.Info("Job cgroups enabled", "parent", CgroupParent)
+	}
+	return e
+}
+
+// Start runs the job's command in the background. Its stdout and stderr
+// are streamed line by line into the job log while it runs.
+func (e *Local) Start(ctx context.Context, spec Spec) error {
+	return e.runs.start(ctx, spec.JobID, func(ctx context.Context, run *processRun) (Result, error) {
+		return e.run(ctx, spec, run)
+	})
+}
+
+// Wait returns the result of the job's run. Result.Output holds only the
+// last OutputTailBytes of each stream; the full output lives in the job
+// log.
+func (e *Local) Wait(jobID string) (Result, error) {
+	return e.runs.wait(jobID)
+}
+
+// Stats reports the job's process and, when it has a cgroup, its current
+// memory and CPU use.
+func (e *Local) Stats(jobID string) (Stats, error) {
+	stats, run, err := e.runs.stats(jobID, LocalRuntime)
+	if err != nil {
+		return stats, err
+	}
+	run.mu.Lock()
+	defer run.mu.Unlock()
+	if run.cgroup != nil {
+		stats.MemoryBytes, stats.CPUSeconds = run.cgroup.sample()
 	}
+	return stats, nil
 }
 
-func (e *Executor) RunJob(command, jobID, volumePath string, ctx context.Context, jobLogger logger.JobLogger) (string, error) {
-	defer e.RemoveCancelFunc(jobID)
+func (e *Local) Cancel(jobID string) error {
+	return e.runs.cancel(jobID)
+}
+
+// run runs the command with baseEnv plus spec.Env, whose KEY=value pairs
+// take precedence, and the GPU variables for spec.GPUs over both.
+func (e *Local) run(ctx context.Context, spec Spec, run *processRun) (Result, error) {
+	command, jobID, volumePath, jobLogger := spec.Command, spec.JobID, spec.VolumePath, spec.Logger
 
 	jobLogger.Info("Setting up command execution environment", logger.Item("volume_path", volumePath))
 
-	cmd := exec.CommandContext(ctx, "bash", "-c", command)
-	cmd.Dir = volumePath
-	cmd.Env = append(
-		os.Environ(),
-		"USER=jobrunner",
-		fmt.Sprintf("PATH=%s:%s", volumePath, os.Getenv("PATH")),
-	)
+	cmd := command.build(ctx, volumePath)
+	cmd.Env = append(baseEnv(volumePath), spec.Env...)
+	cmd.Env = append(cmd.Env, gpuEnv(spec.GPUs, false)...)
+
+	if len(command.Argv) > 0 {
+		jobLogger.Info("Executing command", logger.Item("argv", command.Argv), logger.Item("dir", cmd.Dir))
+	} else {
+		jobLogger.Info("Executing command", logger.Item("command", command.Script), logger.Item("shell", cmd.Path), logger.Item("dir", cmd.Dir))
+	}
 
-	var stdout, stderr bytes.Buffer
-	cmd.Stdout = &stdout
-	cmd.Stderr = &stderr
+	sandbox := spec.Sandbox
+	if Sandboxing == SandboxRequired {
+		sandbox.Enabled = true
+	}
+	if sandbox.Enabled {
+		// The server's HOME is out of reach and read-only in the sandbox.
+		cmd.Env = append(cmd.Env, "HOME="+volumePath)
+		sandboxed, err := sandboxCommand(ctx, cmd, volumePath, sandbox)
+		if err != nil {
+			jobLogger.Error("Failed to set up the job sandbox", logger.Item("error", err))
+			return Result{ExitCode: -1}, err
+		}
+		cmd = sandboxed
+		jobLogger.Info("Running the job in a sandbox",
+			logger.Item("uid", SandboxUID),
+			logger.Item("host_uid", SandboxIDBase+SandboxUID),
+			logger.Item("network", !sandbox.NoNetwork))
+	}
+
+	cgroup := e.newJobCgroup(jobID, command.Limits, &jobLogger)
+	if cgroup != nil {
+		cgroup.attach(cmd)
+		run.mu.Lock()
+		run.cgroup = cgroup
+		run.mu.Unlock()
+	}
+	return runProcess(ctx, spec, cmd, run, func(result *Result) {
+		if sandbox.Enabled {
+			sandboxExitSignal(result)
+		}
+		if cgroup == nil {
+			return
+		}
+		run.mu.Lock()
+		run.cgroup = nil
+		run.mu.Unlock()
+		used := cgroup.finish()
+		result.OOMKilled = used.OOMKilled
+		result.PeakMemoryBytes = used.PeakMemoryBytes
+		result.CPUSeconds = used.CPUSeconds
+		jobLogger.Info("Job resource usage",
+			logger.Item("peak_memory_bytes", used.PeakMemoryBytes),
+			logger.Item("cpu_seconds", used.CPUSeconds))
+	})
+}
 
-	jobLogger.Info("Executing command", logger.Item("command", command))
+// runProcess runs cmd as the job's process group, streaming its output
+// into the job log, and reports how it ended. finish runs once the
+// process has exited, to fill in what the executor knows beyond the exit
+// status, such as whether the job was OOM-killed.
+func runProcess(ctx context.Context, spec Spec, cmd *exec.Cmd, run *processRun, finish func(*Result)) (Result, error) {
+	command, jobID, jobLogger := spec.Command, spec.JobID, spec.Logger
 
-	if err := cmd.Run(); err != nil {
-		output := stdout.String() + stderr.String()
+	stdout := newLineWriter(logger.StreamStdout, &jobLogger)
+	stderr := newLineWriter(logger.StreamStderr, &jobLogger)
+	cmd.Stdout = stdout
+	cmd.Stderr = stderr
+
+	group := newProcessGroup(cmd)
+	err := cmd.Start()
+	if err == nil {
+		run.mu.Lock()
+		run.pid = cmd.Process.Pid
+		run.mu.Unlock()
+		err = cmd.Wait()
+	}
+	stdout.Close()
+	stderr.Close()
+	termSignal, leftovers := group.finish()
+	if leftovers {
+		jobLogger.Info("Killed processes the job left running")
+		executorLogger.Info("Killed leftover job processes", "job_id", jobID)
+	}
+	if errors.Is(err, exec.ErrWaitDelay) && ctx.Err() == nil && cmd.ProcessState.Success() {
+		// The job finished, but processes it left behind kept its output
+		// open; they have been killed above.
+		err = nil
+	}
+
+	result := Result{Output: stdout.Tail() + stderr.Tail(), ExitCode: -1, TermSignal: termSignal}
+	if cmd.ProcessState != nil {
+		result.ExitCode = cmd.ProcessState.ExitCode()
+		result.Signal = exitSignal(cmd.ProcessState)
+	}
+	finish(&result)
+	if result.OOMKilled {
+		jobLogger.Error("The kernel killed a job process for exceeding the memory limit",
+			logger.Item("memory_limit_bytes", command.Limits.MemoryBytes))
+		executorLogger.Warn("Job process OOM-killed", "job_id", jobID)
+	}
+
+	if err != nil {
 		exitCode := "unknown"
-		if ee, ok := err.(*exec.ExitError); ok {
-			exitCode = fmt.Sprintf("%d", ee.ExitCode())
+		if result.ExitCode >= 0 {
+			exitCode = fmt.Sprintf("%d", result.ExitCode)
+		} else if result.Signal != "" {
+			exitCode = result.Signal
 		}
 
 		// Check if it was a context cancellation
 		if ctx.Err() == context.Canceled {
-			jobLogger.Info("Command execution cancelled", logger.Item("exit_code", exitCode))
+			jobLogger.Info("Command execution cancelled", logger.Item("exit_code", exitCode), logger.Item("term_signal", termSignal))
 			executorLogger.Info("Job cancelled by context", "job_id", jobID)
+			return result, fmt.Errorf("%w (exit %s): %s", ErrCancelled, exitCode, command)
 		} else if ctx.Err() == context.DeadlineExceeded {
-			jobLogger.Error("Command execution timed out", logger.Item("exit_code", exitCode))
+			jobLogger.Error("Command execution timed out", logger.Item("exit_code", exitCode), logger.Item("term_signal", termSignal))
 			executorLogger.Warn("Job timed out", "job_id", jobID)
+			return result, fmt.Errorf("%w (exit %s): %s", ErrTimedOut, exitCode, command)
 		} else {
 			jobLogger.Error("Command execution failed",
 				logger.Item("exit_code", exitCode),
-				logger.Item("error", err),
-				logger.Item("stderr", stderr.String()))
+				logger.Item("error", err))
+		}
+		if result.OOMKilled {
+			return result, oomError(command.Limits, exitCode, command)
 		}
 
-		return output, fmt.Errorf("command failed (exit %s): %s\nstderr:\n%s", exitCode, command, stderr.String())
+		return result, fmt.Errorf("command failed (exit %s): %s\nstderr:\n%s", exitCode, command, lastBytes(stderr.Tail(), errorTailBytes))
 	}
 
-	output := stdout.String() + stderr.String()
-	jobLogger.Info("Successfully executed command", logger.Item("output_length", len(output)))
-	return output, nil
-}
-
-
-func (e *Executor) SetCancelFunc(jobID string, cancel context.CancelFunc) {
-	e.mu.Lock()
-	defer e.mu.Unlock()
-	e.cancels[jobID] = cancel
+	jobLogger.Info("Successfully executed command",
+		logger.Item("stdout_bytes", stdout.Written()),
+		logger.Item("stderr_bytes", stderr.Written()))
+	return result, nil
 }
 
-func (e *Executor) RemoveCancelFunc(jobID string) {
-	e.mu.Lock()
-	defer e.mu.Unlock()
-	delete(e.cancels, jobID)
+// exitSignal names the signal that terminated the process, if any.
+func exitSignal(state *os.ProcessState) string {
+	if ws, ok := state.Sys().(syscall.WaitStatus); ok && ws.Signaled() {
+		return signalName(ws.Signal())
+	}
+	return ""
 }
 
-func (e *Executor) CancelJob(jobID string) error {
-	e.mu.RLock()
-	cancel := e.cancels[jobID]
-	e.mu.RUnlock()
-
-	if cancel == nil{
-		executorLogger.Warn("Attempted to cancel non-existent or already completed job", "job_id", jobID)
-		return fmt.Errorf("job %s cannot be cancelled because it does not exist", jobID)
+// signalName returns the conventional name of sig, e.g. "SIGKILL".
+func signalName(sig syscall.Signal) string {
+	switch sig {
+	case syscall.SIGKILL:
+		return "SIGKILL"
+	case syscall.SIGTERM:
+		return "SIGTERM"
+	case syscall.SIGINT:
+		return "SIGINT"
+	case syscall.SIGHUP:
+		return "SIGHUP"
+	case syscall.SIGQUIT:
+		return "SIGQUIT"
+	case syscall.SIGABRT:
+		return "SIGABRT"
+	case syscall.SIGSEGV:
+		return "SIGSEGV"
+	case syscall.SIGBUS:
+		return "SIGBUS"
+	case syscall.SIGPIPE:
+		return "SIGPIPE"
 	}
-
-	executorLogger.Info("Cancelling job execution", "job_id", jobID)
-	cancel()
-	return nil
+	return strings.ToUpper(sig.String())
 }
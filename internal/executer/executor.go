@@ -0,0 +1,85 @@
+package executer
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"gpu-runner/internal/logger"
+)
+
+// Errors wrapped by Wait when the job context ended before the command
+// finished, so callers can tell these outcomes from a failing command.
+var (
+	ErrCancelled = errors.New("job cancelled")
+	ErrTimedOut  = errors.New("job timed out")
+)
+
+// ErrNotRunning is returned for a job the executor has no run of.
+var ErrNotRunning = errors.New("job is not running on this executor")
+
+// Executor runs jobs. Start launches a run, Wait blocks until it ends and
+// reports how, Cancel stops it early and Stats samples it while it runs.
+// Runs are identified by job ID, so a job has at most one at a time.
+// Implementations are safe for concurrent use.
+type Executor interface {
+	// Start launches spec. The run ends early, as cancelled or timed out,
+	// when ctx is cancelled or its deadline passes.
+	Start(ctx context.Context, spec Spec) error
+	// Wait returns the result of the job's run once it has ended. Each
+	// run is waited for once.
+	Wait(jobID string) (Result, error)
+	Cancel(jobID string) error
+	Stats(jobID string) (Stats, error)
+}
+
+// Spec is one run of a job.
+type Spec struct {
+	JobID string
+	// Runtime names the backend that runs the job. Only the Registry
+	// looks at it.
+	Runtime string
+	// Image is the container image the job runs in, for runtimes that
+	// run containers.
+	Image   string
+	Command Command
+	// VolumePath is the job's workspace; a relative Command.Dir is
+	// resolved against it.
+	VolumePath string
+	// Env holds KEY=value pairs that take precedence over the backend's
+	// base environment.
+	Env     []string
+	Sandbox Sandbox
+	// GPUs are the indices of the devices allocated to the job. When
+	// non-nil the job can only see those devices; an empty, non-nil GPUs
+	// hides them all. See gpuEnv.
+	GPUs   []int
+	Logger logger.JobLogger
+}
+
+// Result describes how a job process ended. ExitCode is -1 when the process
+// never started or was killed by a signal, in which case Signal names it.
+// TermSignal is the signal the executor sent to stop the job on
+// cancellation or timeout: SIGTERM, or SIGKILL when the grace period ran
+// out. OOMKilled, PeakMemoryBytes and CPUSeconds come from the job's cgroup
+// and are zero when it ran without one.
+type Result struct {
+	Output          string
+	ExitCode        int
+	Signal          string
+	TermSignal      string
+	OOMKilled       bool
+	PeakMemoryBytes int64
+	CPUSeconds      float64
+}
+
+// Stats is a sample of a running job. Usage fields are zero when the
+// backend can't measure them.
+type Stats struct {
+	JobID       string    `json:"job_id"`
+	Runtime     string    `json:"runtime,omitempty"`
+	StartedAt   time.Time `json:"started_at"`
+	PID         int       `json:"pid,omitempty"`
+	MemoryBytes int64     `json:"memory_bytes,omitempty"`
+	CPUSeconds  float64   `json:"cpu_seconds,omitempty"`
+}
@@ -0,0 +1,170 @@
+package executer
+
+import (
+	"context"
+	"fmt"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+
+	"gpu-runner/internal/logger"
+)
+
+// FakeRuntime is the name the Fake executor is registered under.
+const FakeRuntime = "fake"
+
+// FakeOutcome scripts one fake run: it lasts Duration, writes Output to the
+// job log as stdout, and then ends like a process that exited with
+// ExitCode or, when Signal is set, was killed by it.
+type FakeOutcome struct {
+	Duration  time.Duration
+	ExitCode  int
+	Signal    string
+	Output    string
+	OOMKilled bool
+	// Err fails the run before it starts, as a missing program would.
+	Err error
+}
+
+// Fake is an Executor that runs nothing. Each job plays the outcomes
+// scripted for it in order, one per run, then Default. It records every
+// Spec it is started with so callers can check what they asked for, with
+// the GPU variables a local run would add to Env.
+type Fake struct {
+	Default FakeOutcome
+
+	mu       sync.Mutex
+	scripted map[string][]FakeOutcome
+	runs     map[string]*fakeRun
+	started  []Spec
+}
+
+type fakeRun struct {
+	cancel    context.CancelFunc
+	startedAt time.Time
+	done      chan struct{}
+	result    Result
+	err       error
+}
+
+func NewFake() *Fake {
+	return &Fake{
+		scripted: make(map[string][]FakeOutcome),
+		runs:     make(map[string]*fakeRun),
+	}
+}
+
+// Script queues outcomes for the next runs of jobID.
+func (f *Fake) Script(jobID string, outcomes ...FakeOutcome) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.scripted[jobID] = append(f.scripted[jobID], outcomes...)
+}
+
+// Started returns the specs of every run so far, oldest first.
+func (f *Fake) Started() []Spec {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return append([]Spec(nil), f.started...)
+}
+
+func (f *Fake) Start(ctx context.Context, spec Spec) error {
+	f.mu.Lock()
+	if _, running := f.runs[spec.JobID]; running {
+		f.mu.Unlock()
+		return fmt.Errorf("job %s is already running", spec.JobID)
+	}
+	outcome := f.Default
+	if queue := f.scripted[spec.JobID]; len(queue) > 0 {
+		outcome, f.scripted[spec.JobID] = queue[0], queue[1:]
+	}
+	spec.Env = append(slices.Clip(spec.Env), gpuEnv(spec.GPUs, false)...)
+	f.started = append(f.started, spec)
+	ctx, cancel := context.WithCancel(ctx)
+	run := &fakeRun{cancel: cancel, startedAt: time.Now(), done: make(chan struct{})}
+	f.runs[spec.JobID] = run
+	f.mu.Unlock()
+
+	go func() {
+		defer close(run.done)
+		defer cancel()
+		run.result, run.err = playOutcome(ctx, spec, outcome)
+	}()
+	return nil
+}
+
+// playOutcome waits out the outcome's duration and reports it the way
+// Local reports a real process.
+func playOutcome(ctx context.Context, spec Spec, outcome FakeOutcome) (Result, error) {
+	jobLogger := spec.Logger
+	jobLogger.Info("Executing command on the fake runtime", logger.Item("command", spec.Command.String()), logger.Item("duration", outcome.Duration.String()))
+	if outcome.Err != nil {
+		return Result{ExitCode: -1}, outcome.Err
+	}
+
+	timer := time.NewTimer(outcome.Duration)
+	defer timer.Stop()
+	select {
+	case <-ctx.Done():
+		result := Result{ExitCode: -1, Signal: "SIGTERM", TermSignal: "SIGTERM"}
+		if ctx.Err() == context.DeadlineExceeded {
+			return result, fmt.Errorf("%w (exit SIGTERM): %s", ErrTimedOut, spec.Command)
+		}
+		return result, fmt.Errorf("%w (exit SIGTERM): %s", ErrCancelled, spec.Command)
+	case <-timer.C:
+	}
+
+	for _, line := range strings.Split(strings.TrimSuffix(outcome.Output, "\n"), "\n") {
+		if line != "" {
+			jobLogger.Output(logger.StreamStdout, line)
+		}
+	}
+	result := Result{Output: outcome.Output, ExitCode: outcome.ExitCode, Signal: outcome.Signal, OOMKilled: outcome.OOMKilled}
+	exitCode := fmt.Sprintf("%d", outcome.ExitCode)
+	if outcome.Signal != "" {
+		result.ExitCode, exitCode = -1, outcome.Signal
+	}
+	switch {
+	case outcome.OOMKilled:
+		return result, oomError(spec.Command.Limits, exitCode, spec.Command)
+	case result.ExitCode != 0:
+		return result, fmt.Errorf("command failed (exit %s): %s", exitCode, spec.Command)
+	}
+	return result, nil
+}
+
+func (f *Fake) Wait(jobID string) (Result, error) {
+	f.mu.Lock()
+	run := f.runs[jobID]
+	f.mu.Unlock()
+	if run == nil {
+		return Result{ExitCode: -1}, ErrNotRunning
+	}
+	<-run.done
+	f.mu.Lock()
+	delete(f.runs, jobID)
+	f.mu.Unlock()
+	return run.result, run.err
+}
+
+func (f *Fake) Cancel(jobID string) error {
+	f.mu.Lock()
+	run := f.runs[jobID]
+	f.mu.Unlock()
+	if run == nil {
+		return fmt.Errorf("job %s cannot be cancelled: %w", jobID, ErrNotRunning)
+	}
+	run.cancel()
+	return nil
+}
+
+func (f *Fake) Stats(jobID string) (Stats, error) {
+	f.mu.Lock()
+	run := f.runs[jobID]
+	f.mu.Unlock()
+	if run == nil {
+		return Stats{}, ErrNotRunning
+	}
+	return Stats{JobID: jobID, Runtime: FakeRuntime, StartedAt: run.startedAt}, nil
+}
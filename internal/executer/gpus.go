@@ -0,0 +1,48 @@
+package executer
+
+import (
+	"strconv"
+	"strings"
+)
+
+// gpuEnv is the environment that limits a job to the GPUs with the given
+// host indices. CUDA numbers devices the way nvidia-smi does once
+// CUDA_DEVICE_ORDER is PCI_BUS_ID. Inside a container only the allocated
+// devices exist, renumbered from zero, so CUDA_VISIBLE_DEVICES counts from
+// zero there; NVIDIA_VISIBLE_DEVICES keeps the host indices, which is what
+// the NVIDIA container toolkit expects. Nil devices means the job's GPUs
+// are not managed, and leaves the environment alone.
+func gpuEnv(devices []int, renumbered bool) []string {
+	if devices == nil {
+		return nil
+	}
+	host := make([]string, len(devices))
+	cuda := make([]string, len(devices))
+	for i, index := range devices {
+		host[i] = strconv.Itoa(index)
+		cuda[i] = host[i]
+		if renumbered {
+			cuda[i] = strconv.Itoa(i)
+		}
+	}
+	nvidia := strings.Join(host, ",")
+	if len(devices) == 0 {
+		nvidia = "none"
+	}
+	return []string{
+		"CUDA_DEVICE_ORDER=PCI_BUS_ID",
+		"CUDA_VISIBLE_DEVICES=" + strings.Join(cuda, ","),
+		"NVIDIA_VISIBLE_DEVICES=" + nvidia,
+	}
+}
+
+// containerGPUs is the value of the container CLI's --gpus flag that
+// passes the given host devices through. The quotes are part of the
+// value: the CLI parses it as CSV, and the commas separate devices.
+func containerGPUs(devices []int) string {
+	ids := make([]string, len(devices))
+	for i, index := range devices {
+		ids[i] = strconv.Itoa(index)
+	}
+	return `"device=` + strings.Join(ids, ",") + `"`
+}
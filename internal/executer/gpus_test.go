@@ -0,0 +1,39 @@
+package executer
+
+import (
+	"slices"
+	"testing"
+)
+
+func TestGPUEnv(t *testing.T) {
+	for _, tc := range []struct {
+		name       string
+		devices    []int
+		renumbered bool
+		want       []string
+	}{
+		{"not managed", nil, false, nil},
+		{"host devices", []int{1, 3}, false, []string{
+			"CUDA_DEVICE_ORDER=PCI_BUS_ID", "CUDA_VISIBLE_DEVICES=1,3", "NVIDIA_VISIBLE_DEVICES=1,3",
+		}},
+		{"renumbered in a container", []int{1, 3}, true, []string{
+			"CUDA_DEVICE_ORDER=PCI_BUS_ID", "CUDA_VISIBLE_DEVICES=0,1", "NVIDIA_VISIBLE_DEVICES=1,3",
+		}},
+		{"none allocated", []int{}, false, []string{
+			"CUDA_DEVICE_ORDER=PCI_BUS_ID", "CUDA_VISIBLE_DEVICES=", "NVIDIA_VISIBLE_DEVICES=none",
+		}},
+	} {
+		if got := gpuEnv(tc.devices, tc.renumbered); !slices.Equal(got, tc.want) {
+			t.Errorf("%s: gpuEnv = %q, want %q", tc.name, got, tc.want)
+		}
+	}
+}
+
+func TestContainerGPUs(t *testing.T) {
+	if got, want := containerGPUs([]int{0, 2}), `"device=0,2"`; got != want {
+		t.Errorf("containerGPUs = %s, want %s", got, want)
+	}
+	if got, want := containerGPUs([]int{5}), `"device=5"`; got != want {
+		t.Errorf("containerGPUs = %s, want %s", got, want)
+	}
+}
@@ -0,0 +1,163 @@
+package executer
+
+import (
+	"bytes"
+	"encoding/base64"
+	"sync"
+	"unicode/utf8"
+
+	"gpu-runner/internal/logger"
+)
+
+const (
+	// MaxLineBytes caps a single output entry. Longer lines are split into
+	// several entries marked partial.
+	MaxLineBytes = 16 * 1024
+	// OutputTailBytes is how much of each stream an executor keeps in
+	// memory for the run's Result and error message.
+	OutputTailBytes = 64 * 1024
+
+	// errorTailBytes is how much stderr is quoted in a failed run's
+	// error; the full stream is already in the job log.
+	errorTailBytes = 2 * 1024
+)
+
+// lineWriter forwards process output to a job logger one line at a time
+// while the process runs. Lines that are not valid UTF-8 are stored base64
+// encoded so binary output survives the JSON wire format.
+type lineWriter struct {
+	stream string
+	log    *logger.JobLogger
+
+	mu      sync.Mutex
+	pending []byte
+	tail    tailBuffer
+	written int64
+}
+
+func newLineWriter(stream string, log *logger.JobLogger) *lineWriter {
+	return &lineWriter{stream: stream, log: log, tail: tailBuffer{max: OutputTailBytes}}
+}
+
+func (w *lineWriter) Write(p []byte) (int, error) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	w.written += int64(len(p))
+	w.tail.Write(p)
+	w.pending = append(w.pending, p...)
+
+	for {
+		i := bytes.IndexByte(w.pending, '\n')
+		if i < 0 {
+			break
+		}
+		w.emit(w.pending[:i], false)
+		w.pending = w.pending[i+1:]
+	}
+	// A secret crossing a chunk boundary would be masked in neither chunk,
+	// so a long line is masked before it is cut, and only cut once the
+	// bytes past the cut cover any secret starting before it.
+	overlap := max(w.log.LongestSecret()-1, 0)
+	if overlap > 0 && len(w.pending) > MaxLineBytes+overlap {
+		w.pending = []byte(w.log.Redact(string(w.pending)))
+	}
+	w.split(MaxLineBytes + overlap)
+	return len(p), nil
+}
+
+// Close flushes a trailing line that had no newline.
+func (w *lineWriter) Close() error {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	if len(w.pending) > 0 {
+		// Nothing more is coming, so once masked the rest can be cut
+		// anywhere.
+		w.pending = []byte(w.log.Redact(string(w.pending)))
+		w.split(MaxLineBytes)
+		w.emit(w.pending, false)
+		w.pending = nil
+	}
+	return nil
+}
+
+// split emits the start of the pending line as partial entries of at most
+// MaxLineBytes until no more than keep bytes are left.
+func (w *lineWriter) split(keep int) {
+	for len(w.pending) > keep {
+		n := chunkBoundary(w.pending, MaxLineBytes)
+		w.emit(w.pending[:n], true)
+		w.pending = w.pending[n:]
+	}
+}
+
+// Tail returns the last OutputTailBytes written to the stream.
+func (w *lineWriter) Tail() string {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	return w.tail.String()
+}
+
+// Written returns the total number of bytes written to the stream.
+func (w *lineWriter) Written() int64 {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	return w.written
+}
+
+func (w *lineWriter) emit(line []byte, partial bool) {
+	line = bytes.TrimSuffix(line, []byte("\r"))
+	// Mask secrets before encoding; base64 would hide them from the logger.
+	line = []byte(w.log.Redact(string(line)))
+
+	var fields []logger.Field
+	if partial {
+		fields = append(fields, logger.Item("partial", true))
+	}
+	if !utf8.Valid(line) {
+		fields = append(fields, logger.String("encoding", "base64"))
+		w.log.Output(w.stream, base64.StdEncoding.EncodeToString(line), fields...)
+		return
+	}
+	w.log.Output(w.stream, string(line), fields...)
+}
+
+// chunkBoundary returns the largest n <= max that does not split a UTF-8
+// sequence, falling back to max for data that is not text.
+func chunkBoundary(b []byte, max int) int {
+	for n := max; n > max-utf8.UTFMax && n > 0; n-- {
+		if utf8.RuneStart(b[n]) {
+			return n
+		}
+	}
+	return max
+}
+
+// tailBuffer keeps the last max bytes written to it.
+type tailBuffer struct {
+	max int
+	buf []byte
+}
+
+func (t *tailBuffer) Write(p []byte) {
+	if len(p) >= t.max {
+		t.buf = append(t.buf[:0], p[len(p)-t.max:]...)
+		return
+	}
+	if over := len(t.buf) + len(p) - t.max; over > 0 {
+		t.buf = append(t.buf[:0], t.buf[over:]...)
+	}
+	t.buf = append(t.buf, p...)
+}
+
+func (t *tailBuffer) String() string {
+	return string(t.buf)
+}
+
+func lastBytes(s string, n int) string {
+	if len(s) <= n {
+		return s
+	}
+	return s[len(s)-n:]
+}
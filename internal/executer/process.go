@@ -0,0 +1,76 @@
+package executer
+
+import (
+	"os/exec"
+	"sync"
+	"syscall"
+	"time"
+)
+
+// KillGracePeriod is how long a cancelled or timed-out job's processes get
+// to exit after SIGTERM before the whole group is sent SIGKILL.
+var KillGracePeriod = 10 * time.Second
+
+// pipeGracePeriod is how long output is still read after the job's main
+// process exited or was killed, for children still holding it open.
+const pipeGracePeriod = 2 * time.Second
+
+// processGroup runs a command as the leader of its own process group, so
+// that stopping a job reaches every process it started and not just the
+// shell: the training script, its dataloader workers and anything else
+// still holding the GPU.
+type processGroup struct {
+	cmd *exec.Cmd
+
+	mu    sync.Mutex
+	sent  string
+	timer *time.Timer
+}
+
+// newProcessGroup arranges for cmd to start in a new process group and for
+// context cancellation to terminate the group.
+func newProcessGroup(cmd *exec.Cmd) *processGroup {
+	g := &processGroup{cmd: cmd}
+	if cmd.SysProcAttr == nil {
+		cmd.SysProcAttr = &syscall.SysProcAttr{}
+	}
+	cmd.SysProcAttr.Setpgid = true
+	cmd.Cancel = g.terminate
+	cmd.WaitDelay = KillGracePeriod + pipeGracePeriod
+	return g
+}
+
+// terminate sends SIGTERM to the group and SIGKILL once the grace period
+// has passed.
+func (g *processGroup) terminate() error {
+	g.signal(syscall.SIGTERM)
+	g.mu.Lock()
+	g.timer = time.AfterFunc(KillGracePeriod, func() { g.signal(syscall.SIGKILL) })
+	g.mu.Unlock()
+	return nil
+}
+
+func (g *processGroup) signal(sig syscall.Signal) {
+	if syscall.Kill(-g.cmd.Process.Pid, sig) == nil {
+		g.mu.Lock()
+		g.sent = signalName(sig)
+		g.mu.Unlock()
+	}
+}
+
+// finish kills whatever is left of the group after the leader has exited.
+// It returns the strongest signal terminate sent, if any, and whether
+// leftover processes had to be killed.
+func (g *processGroup) finish() (sent string, leftovers bool) {
+	if g.cmd.Process == nil {
+		return "", false
+	}
+	g.mu.Lock()
+	if g.timer != nil {
+		g.timer.Stop()
+	}
+	sent = g.sent
+	g.mu.Unlock()
+	leftovers = syscall.Kill(-g.cmd.Process.Pid, syscall.SIGKILL) == nil
+	return sent, leftovers
+}
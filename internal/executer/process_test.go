@@ -0,0 +1,174 @@
+package executer
+
+import (
+	"context"
+	"errors"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"syscall"
+	"testing"
+	"time"
+
+	"gpu-runner/internal/logger"
+)
+
+// discardSink drops job log entries.
+type discardSink struct{}
+
+func (discardSink) Append(context.Context, string, string) error { return nil }
+
+// newTestLocal returns a Local that runs jobs without cgroups, so only the
+// process group stands between a stopped job and its leftover processes.
+func newTestLocal() *Local {
+	return &Local{runs: newRunSet(), cgroups: &cgroups{err: errors.New("disabled in tests")}}
+}
+
+func scriptSpec(t *testing.T, jobID, script string) Spec {
+	t.Helper()
+	return Spec{
+		JobID:      jobID,
+		Command:    Command{Script: script, Shell: "bash"},
+		VolumePath: t.TempDir(),
+		Logger:     *logger.NewJobLogger(context.Background(), jobID, discardSink{}),
+	}
+}
+
+func setKillGracePeriod(t *testing.T, d time.Duration) {
+	t.Helper()
+	old := KillGracePeriod
+	KillGracePeriod = d
+	t.Cleanup(func() { KillGracePeriod = old })
+}
+
+// childPID waits for the job to write the PID of the process it started
+// in the background to child.pid.
+func childPID(t *testing.T, dir string) int {
+	t.Helper()
+	deadline := time.Now().Add(5 * time.Second)
+	for time.Now().Before(deadline) {
+		data, err := os.ReadFile(filepath.Join(dir, "child.pid"))
+		if err == nil && strings.HasSuffix(string(data), "\n") {
+			pid, err := strconv.Atoi(strings.TrimSpace(string(data)))
+			if err != nil {
+				t.Fatalf("bad child.pid %q: %v", data, err)
+			}
+			return pid
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+	t.Fatal("job did not report its background process")
+	return 0
+}
+
+// alive reports whether pid is still running. Zombies count as dead: the
+// orphaned process is reaped by whatever adopted it, not by the test.
+func alive(pid int) bool {
+	if syscall.Kill(pid, 0) != nil {
+		return false
+	}
+	stat, err := os.ReadFile("/proc/" + strconv.Itoa(pid) + "/stat")
+	if err != nil {
+		return !os.IsNotExist(err)
+	}
+	fields := strings.Fields(string(stat[strings.LastIndexByte(string(stat), ')')+1:]))
+	return len(fields) == 0 || fields[0] != "Z"
+}
+
+func waitGone(t *testing.T, pid int, within time.Duration) {
+	t.Helper()
+	deadline := time.Now().Add(within)
+	for alive(pid) {
+		if time.Now().After(deadline) {
+			t.Fatalf("process %d still running after %v", pid, within)
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+}
+
+func TestCancelTerminatesGrandchildren(t *testing.T) {
+	// Far longer than the test may take: everything has to go on SIGTERM.
+	setKillGracePeriod(t, time.Minute)
+	e := newTestLocal()
+	spec := scriptSpec(t, "cancel", "sleep 1000 & echo $! > child.pid; wait")
+	if err := e.Start(context.Background(), spec); err != nil {
+		t.Fatalf("Start: %v", err)
+	}
+	pid := childPID(t, spec.VolumePath)
+
+	if err := e.Cancel(spec.JobID); err != nil {
+		t.Fatalf("Cancel: %v", err)
+	}
+	done := make(chan struct{})
+	var result Result
+	var err error
+	go func() {
+		result, err = e.Wait(spec.JobID)
+		close(done)
+	}()
+	select {
+	case <-done:
+	case <-time.After(5 * time.Second):
+		t.Fatal("Wait did not return after SIGTERM; the background sleep kept the job alive")
+	}
+
+	if !errors.Is(err, ErrCancelled) {
+		t.Errorf("Wait error = %v, want ErrCancelled", err)
+	}
+	if result.TermSignal != "SIGTERM" {
+		t.Errorf("TermSignal = %q, want SIGTERM", result.TermSignal)
+	}
+	waitGone(t, pid, time.Second)
+}
+
+func TestTimeoutKillsGrandchildrenAfterGracePeriod(t *testing.T) {
+	setKillGracePeriod(t, time.Second)
+	e := newTestLocal()
+	// Ignoring SIGTERM is inherited by the background sleep, so only the
+	// SIGKILL at the end of the grace period stops it.
+	spec := scriptSpec(t, "timeout", "trap '' TERM; sleep 1000 & echo $! > child.pid; wait")
+	ctx, cancel := context.WithTimeout(context.Background(), 300*time.Millisecond)
+	defer cancel()
+	if err := e.Start(ctx, spec); err != nil {
+		t.Fatalf("Start: %v", err)
+	}
+	pid := childPID(t, spec.VolumePath)
+
+	<-ctx.Done()
+	time.Sleep(300 * time.Millisecond)
+	if !alive(pid) {
+		t.Fatal("background process died before the grace period ran out")
+	}
+
+	result, err := e.Wait(spec.JobID)
+	if !errors.Is(err, ErrTimedOut) {
+		t.Errorf("Wait error = %v, want ErrTimedOut", err)
+	}
+	if result.TermSignal != "SIGKILL" {
+		t.Errorf("TermSignal = %q, want SIGKILL", result.TermSignal)
+	}
+	if result.Signal != "SIGKILL" {
+		t.Errorf("Signal = %q, want SIGKILL", result.Signal)
+	}
+	waitGone(t, pid, time.Second)
+}
+
+func TestFinishedJobLeftoversAreKilled(t *testing.T) {
+	setKillGracePeriod(t, 100*time.Millisecond)
+	e := newTestLocal()
+	spec := scriptSpec(t, "leftover", "sleep 1000 & echo $! > child.pid")
+	if err := e.Start(context.Background(), spec); err != nil {
+		t.Fatalf("Start: %v", err)
+	}
+	pid := childPID(t, spec.VolumePath)
+
+	result, err := e.Wait(spec.JobID)
+	if err != nil {
+		t.Errorf("Wait error = %v, want the job to succeed", err)
+	}
+	if result.ExitCode != 0 || result.TermSignal != "" {
+		t.Errorf("result = %+v, want exit 0 and no termination signal", result)
+	}
+	waitGone(t, pid, time.Second)
+}
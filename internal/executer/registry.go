@@ -0,0 +1,127 @@
+package executer
+
+import (
+	"context"
+	"fmt"
+	"maps"
+	"slices"
+	"sync"
+)
+
+// Registry is an Executor that hands each job to the backend named by its
+// Spec.Runtime, or to Default when the job doesn't name one.
+type Registry struct {
+	Default string
+
+	mu       sync.RWMutex
+	backends map[string]Executor
+	// running maps the ID of each started job to its runtime.
+	running map[string]string
+}
+
+func NewRegistry(defaultRuntime string) *Registry {
+	return &Registry{
+		Default:  defaultRuntime,
+		backends: make(map[string]Executor),
+		running:  make(map[string]string),
+	}
+}
+
+// Register makes e available as runtime name, replacing any backend
+// registered under that name before.
+func (r *Registry) Register(name string, e Executor) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.backends[name] = e
+	executorLogger.Info("Registered job runtime", "runtime", name, "default", name == r.Default)
+}
+
+// Has reports whether runtime names a registered backend. The empty name
+// stands for Default.
+func (r *Registry) Has(runtime string) bool {
+	_, err := r.backend(runtime)
+	return err == nil
+}
+
+// Names returns the registered runtimes, sorted.
+func (r *Registry) Names() []string {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	return slices.Sorted(maps.Keys(r.backends))
+}
+
+func (r *Registry) backend(runtime string) (Executor, error) {
+	if runtime == "" {
+		runtime = r.Default
+	}
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	e, ok := r.backends[runtime]
+	if !ok {
+		return nil, fmt.Errorf("unknown runtime %q", runtime)
+	}
+	return e, nil
+}
+
+// route returns the runtime and backend the job was started on.
+func (r *Registry) route(jobID string) (string, Executor, error) {
+	r.mu.RLock()
+	runtime, ok := r.running[jobID]
+	e := r.backends[runtime]
+	r.mu.RUnlock()
+	if !ok || e == nil {
+		return "", nil, ErrNotRunning
+	}
+	return runtime, e, nil
+}
+
+func (r *Registry) Start(ctx context.Context, spec Spec) error {
+	runtime := spec.Runtime
+	if runtime == "" {
+		runtime = r.Default
+	}
+	e, err := r.backend(runtime)
+	if err != nil {
+		return err
+	}
+	r.mu.Lock()
+	r.running[spec.JobID] = runtime
+	r.mu.Unlock()
+	if err := e.Start(ctx, spec); err != nil {
+		r.mu.Lock()
+		delete(r.running, spec.JobID)
+		r.mu.Unlock()
+		return err
+	}
+	return nil
+}
+
+func (r *Registry) Wait(jobID string) (Result, error) {
+	_, e, err := r.route(jobID)
+	if err != nil {
+		return Result{ExitCode: -1}, err
+	}
+	result, err := e.Wait(jobID)
+	r.mu.Lock()
+	delete(r.running, jobID)
+	r.mu.Unlock()
+	return result, err
+}
+
+func (r *Registry) Cancel(jobID string) error {
+	_, e, err := r.route(jobID)
+	if err != nil {
+		return fmt.Errorf("job %s cannot be cancelled: %w", jobID, err)
+	}
+	return e.Cancel(jobID)
+}
+
+func (r *Registry) Stats(jobID string) (Stats, error) {
+	runtime, e, err := r.route(jobID)
+	if err != nil {
+		return Stats{}, err
+	}
+	stats, err := e.Stats(jobID)
+	stats.Runtime = runtime
+	return stats, err
+}
@@ -0,0 +1,95 @@
+package executer
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"time"
+)
+
+// processRun is a job a process-based executor has started and nobody has
+// waited for yet.
+type processRun struct {
+	cancel    context.CancelFunc
+	startedAt time.Time
+	done      chan struct{}
+	result    Result
+	err       error
+
+	mu     sync.Mutex
+	pid    int
+	cgroup *jobCgroup
+}
+
+// runSet tracks the runs of an executor by job ID, for the Executor
+// methods that only get the ID.
+type runSet struct {
+	mu   sync.RWMutex
+	runs map[string]*processRun
+}
+
+func newRunSet() runSet {
+	return runSet{runs: make(map[string]*processRun)}
+}
+
+// start runs fn in the background as the job's run. fn's context ends
+// when ctx does or the run is cancelled.
+func (s *runSet) start(ctx context.Context, jobID string, fn func(context.Context, *processRun) (Result, error)) error {
+	ctx, cancel := context.WithCancel(ctx)
+	run := &processRun{cancel: cancel, startedAt: time.Now(), done: make(chan struct{})}
+	s.mu.Lock()
+	if _, running := s.runs[jobID]; running {
+		s.mu.Unlock()
+		cancel()
+		return fmt.Errorf("job %s is already running", jobID)
+	}
+	s.runs[jobID] = run
+	s.mu.Unlock()
+
+	go func() {
+		run.result, run.err = fn(ctx, run)
+		cancel()
+		close(run.done)
+	}()
+	return nil
+}
+
+func (s *runSet) get(jobID string) *processRun {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return s.runs[jobID]
+}
+
+func (s *runSet) wait(jobID string) (Result, error) {
+	run := s.get(jobID)
+	if run == nil {
+		return Result{ExitCode: -1}, ErrNotRunning
+	}
+	<-run.done
+	s.mu.Lock()
+	delete(s.runs, jobID)
+	s.mu.Unlock()
+	return run.result, run.err
+}
+
+func (s *runSet) cancel(jobID string) error {
+	run := s.get(jobID)
+	if run == nil {
+		executorLogger.Warn("Attempted to cancel non-existent or already completed job", "job_id", jobID)
+		return fmt.Errorf("job %s cannot be cancelled: %w", jobID, ErrNotRunning)
+	}
+	executorLogger.Info("Cancelling job execution", "job_id", jobID)
+	run.cancel()
+	return nil
+}
+
+// stats describes the run's main process.
+func (s *runSet) stats(jobID, runtime string) (Stats, *processRun, error) {
+	run := s.get(jobID)
+	if run == nil {
+		return Stats{}, nil, ErrNotRunning
+	}
+	run.mu.Lock()
+	defer run.mu.Unlock()
+	return Stats{JobID: jobID, Runtime: runtime, StartedAt: run.startedAt, PID: run.pid}, run, nil
+}
@@ -0,0 +1,87 @@
+package executer
+
+import (
+	"fmt"
+	"os"
+	"syscall"
+)
+
+// Sandbox asks for a job's processes to be isolated from the host. A
+// sandboxed local job runs in its own user, PID and mount namespaces, as
+// SandboxUID, with the host's root filesystem mounted read-only and only
+// its own workspace, /tmp and /dev/shm writable. Other jobs' workspaces
+// are hidden.
+type Sandbox struct {
+	Enabled bool
+	// NoNetwork also gives the job its own network namespace, which has
+	// nothing but a loopback interface.
+	NoNetwork bool
+}
+
+// SandboxPolicy is the server's stance on sandboxing local jobs.
+type SandboxPolicy string
+
+const (
+	// SandboxDisabled rejects jobs that ask for a sandbox.
+	SandboxDisabled SandboxPolicy = "disabled"
+	// SandboxOptional sandboxes the jobs that ask for it.
+	SandboxOptional SandboxPolicy = "optional"
+	// SandboxRequired sandboxes every local job.
+	SandboxRequired SandboxPolicy = "required"
+)
+
+// ParseSandboxPolicy parses a policy name.
+func ParseSandboxPolicy(s string) (SandboxPolicy, error) {
+	switch p := SandboxPolicy(s); p {
+	case SandboxDisabled, SandboxOptional, SandboxRequired:
+		return p, nil
+	}
+	return "", fmt.Errorf("unknown sandbox policy %q (want disabled, optional or required)", s)
+}
+
+// Sandboxing is the server's sandbox policy.
+var Sandboxing = SandboxOptional
+
+// SandboxIDBase is the first of the 65536 host uids and gids a sandbox's
+// user namespace maps its ids onto, so uid 0 inside is SandboxIDBase on
+// the host. Nothing else on the host should own ids in that range.
+var SandboxIDBase = 100000
+
+// SandboxUID is the uid and gid sandboxed jobs run as inside their user
+// namespace; on the host they are SandboxIDBase+SandboxUID.
+const SandboxUID = 1000
+
+const sandboxIDCount = 65536
+
+// sandboxInitName is argv[0] of the server binary re-executed as a
+// sandbox's init process. The logger package knows it too.
+const sandboxInitName = "gpu-runner-sandbox-init"
+
+// sandboxSetupFailed is the exit status of a sandbox that could not be set
+// up; the reason is on its stderr, and so in the job log.
+const sandboxSetupFailed = 125
+
+// sandboxExitSignal turns the 128+n status the sandbox init exits with for
+// a job killed by signal n back into the signal, so a sandboxed run's
+// result reads like an unsandboxed one's.
+func sandboxExitSignal(result *Result) {
+	if result.Signal == "" && result.ExitCode > 128 && result.ExitCode <= 128+64 {
+		result.Signal = signalName(syscall.Signal(result.ExitCode - 128))
+		result.ExitCode = -1
+	}
+}
+
+// sandboxConfig is passed from Local to the sandbox's init process.
+type sandboxConfig struct {
+	// Workspace is mounted writable at the same path inside the sandbox.
+	Workspace string `json:"workspace,omitempty"`
+	Dir       string `json:"dir,omitempty"`
+	NoNetwork bool   `json:"no_network,omitempty"`
+}
+
+// IsSandboxInit reports whether this process was started by Local as a
+// sandbox's init process. The server calls SandboxInit first thing when
+// it was.
+func IsSandboxInit() bool {
+	return len(os.Args) > 2 && os.Args[0] == sandboxInitName
+}
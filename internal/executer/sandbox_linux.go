@@ -0,0 +1,313 @@
+package executer
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"os/exec"
+	"os/signal"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"syscall"
+	"time"
+	"unsafe"
+)
+
+// oPath is O_PATH, which the syscall package lacks.
+const oPath = 0x200000
+
+// sandboxCommand wraps cmd so that it runs in a sandbox. The wrapper is
+// the server binary re-executed in new namespaces as the sandbox's init
+// process: it sets up the sandbox's mounts, runs cmd as SandboxUID and
+// passes signals on to it.
+func sandboxCommand(ctx context.Context, cmd *exec.Cmd, volumePath string, sandbox Sandbox) (*exec.Cmd, error) {
+	raw, err := json.Marshal(sandboxConfig{Workspace: volumePath, Dir: cmd.Dir, NoNetwork: sandbox.NoNetwork})
+	if err != nil {
+		return nil, err
+	}
+	if volumePath != "" {
+		if err := chownTree(volumePath, SandboxIDBase+SandboxUID); err != nil {
+			return nil, fmt.Errorf("sandbox: hand the workspace to the job's user: %w", err)
+		}
+	}
+
+	wrapped := exec.CommandContext(ctx, "/proc/self/exe", append([]string{string(raw)}, cmd.Args...)...)
+	wrapped.Args[0] = sandboxInitName
+	wrapped.Env = cmd.Env
+	flags := uintptr(syscall.CLONE_NEWUSER | syscall.CLONE_NEWNS | syscall.CLONE_NEWPID)
+	if sandbox.NoNetwork {
+		flags |= syscall.CLONE_NEWNET
+	}
+	ids := []syscall.SysProcIDMap{{ContainerID: 0, HostID: SandboxIDBase, Size: sandboxIDCount}}
+	wrapped.SysProcAttr = &syscall.SysProcAttr{
+		Cloneflags:                 flags,
+		UidMappings:                ids,
+		GidMappings:                ids,
+		GidMappingsEnableSetgroups: true,
+		// Become the namespace's root, which keeps its capabilities
+		// there across the exec.
+		Credential: &syscall.Credential{Uid: 0, Gid: 0},
+	}
+	return wrapped, nil
+}
+
+// chownTree gives path and everything under it to uid, with the same gid.
+func chownTree(path string, uid int) error {
+	return filepath.WalkDir(path, func(p string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		return os.Lchown(p, uid, uid)
+	})
+}
+
+// CheckSandbox runs a trivial command in a sandbox, to find out whether
+// this host and server can sandbox jobs at all.
+func CheckSandbox() error {
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+	cmd, err := sandboxCommand(ctx, exec.Command("true"), "", Sandbox{Enabled: true, NoNetwork: true})
+	if err != nil {
+		return err
+	}
+	cmd.Env = []string{"PATH=/usr/local/bin:/usr/bin:/bin"}
+	if out, err := cmd.CombinedOutput(); err != nil {
+		return fmt.Errorf("%w: %s", err, strings.TrimSpace(string(out)))
+	}
+	return nil
+}
+
+// SandboxInit is the sandbox's init process. It never returns: it exits
+// with the job's exit status, or 128 plus the number of the signal that
+// killed it.
+func SandboxInit() {
+	var cfg sandboxConfig
+	err := json.Unmarshal([]byte(os.Args[1]), &cfg)
+	if err == nil {
+		err = setupSandbox(cfg)
+	}
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "sandbox: %v\n", err)
+		os.Exit(sandboxSetupFailed)
+	}
+	os.Exit(superviseSandboxed(cfg, os.Args[2:]))
+}
+
+// setupSandbox builds the sandbox's root: a read-only recursive bind of
+// the host's, with a fresh /proc for the new PID namespace, private /tmp
+// and /dev/shm, and the workspace writable, alone in its parent. It then
+// pivots into it.
+func setupSandbox(cfg sandboxConfig) error {
+	// The workspace is held open so it can be bound from here even if
+	// the staging tmpfs below covers it.
+	workspace := -1
+	if cfg.Workspace != "" {
+		fd, err := syscall.Open(cfg.Workspace, oPath|syscall.O_DIRECTORY|syscall.O_CLOEXEC, 0)
+		if err != nil {
+			return fmt.Errorf("open workspace: %w", err)
+		}
+		defer syscall.Close(fd)
+		workspace = fd
+	}
+
+	if err := syscall.Mount("", "/", "", syscall.MS_REC|syscall.MS_PRIVATE, ""); err != nil {
+		return fmt.Errorf("make mounts private: %w", err)
+	}
+	// The new root is staged on a tmpfs over /tmp, which only this mount
+	// namespace sees.
+	if err := syscall.Mount("tmpfs", "/tmp", "tmpfs", 0, "mode=0700"); err != nil {
+		return fmt.Errorf("mount staging tmpfs: %w", err)
+	}
+	root := "/tmp/root"
+	if err := os.Mkdir(root, 0o700); err != nil {
+		return err
+	}
+	if err := syscall.Mount("/", root, "", syscall.MS_BIND|syscall.MS_REC, ""); err != nil {
+		return fmt.Errorf("bind root: %w", err)
+	}
+	if err := remountReadOnly(root); err != nil {
+		return err
+	}
+
+	if err := syscall.Mount("proc", filepath.Join(root, "proc"), "proc", syscall.MS_NOSUID|syscall.MS_NODEV|syscall.MS_NOEXEC, ""); err != nil {
+		return fmt.Errorf("mount /proc: %w", err)
+	}
+	for _, dir := range []string{"tmp", "dev/shm"} {
+		target := filepath.Join(root, dir)
+		if _, err := os.Stat(target); err != nil {
+			continue
+		}
+		if err := syscall.Mount("tmpfs", target, "tmpfs", syscall.MS_NOSUID|syscall.MS_NODEV, "mode=1777"); err != nil {
+			return fmt.Errorf("mount /%s: %w", dir, err)
+		}
+	}
+
+	if workspace >= 0 {
+		// After /tmp, which may hold the workspace.
+		target := filepath.Join(root, cfg.Workspace)
+		if err := os.MkdirAll(filepath.Dir(target), 0o755); err != nil {
+			return err
+		}
+		if err := syscall.Mount("tmpfs", filepath.Dir(target), "tmpfs", syscall.MS_NOSUID|syscall.MS_NODEV, "mode=0755"); err != nil {
+			return fmt.Errorf("hide other workspaces: %w", err)
+		}
+		if err := os.Mkdir(target, 0o755); err != nil {
+			return err
+		}
+		if err := syscall.Mount(fmt.Sprintf("/proc/self/fd/%d", workspace), target, "", syscall.MS_BIND|syscall.MS_REC, ""); err != nil {
+			return fmt.Errorf("bind workspace: %w", err)
+		}
+	}
+
+	if err := os.Chdir(root); err != nil {
+		return err
+	}
+	if err := syscall.PivotRoot(".", "."); err != nil {
+		return fmt.Errorf("pivot root: %w", err)
+	}
+	if err := syscall.Unmount(".", syscall.MNT_DETACH); err != nil {
+		return fmt.Errorf("detach old root: %w", err)
+	}
+	if err := os.Chdir("/"); err != nil {
+		return err
+	}
+
+	if cfg.NoNetwork {
+		if err := loopbackUp(); err != nil {
+			return fmt.Errorf("bring up loopback: %w", err)
+		}
+	}
+	return nil
+}
+
+// remountReadOnly makes root and every mount below it read-only. The
+// flags a user namespace may not clear are carried over, or the kernel
+// refuses the remount.
+func remountReadOnly(root string) error {
+	mounts, err := mountPoints(root)
+	if err != nil {
+		return err
+	}
+	const keep = syscall.MS_NOSUID | syscall.MS_NODEV | syscall.MS_NOEXEC | syscall.MS_NOATIME | syscall.MS_NODIRATIME | syscall.MS_RELATIME
+	for _, m := range mounts {
+		var st syscall.Statfs_t
+		if err := syscall.Statfs(m, &st); err != nil {
+			if m == root {
+				return fmt.Errorf("statfs %s: %w", m, err)
+			}
+			// Mounts the sandbox's user can't reach can't be written
+			// to either.
+			continue
+		}
+		flags := uintptr(syscall.MS_BIND|syscall.MS_REMOUNT|syscall.MS_RDONLY) | uintptr(st.Flags)&keep
+		if err := syscall.Mount("", m, "", flags, ""); err != nil {
+			return fmt.Errorf("remount %s read-only: %w", m, err)
+		}
+	}
+	return nil
+}
+
+// mountPoints lists the mount points at or below root, parents first.
+func mountPoints(root string) ([]string, error) {
+	f, err := os.Open("/proc/self/mountinfo")
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	var mounts []string
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		fields := strings.Fields(scanner.Text())
+		if len(fields) < 5 {
+			continue
+		}
+		m := unescapeMountPath(fields[4])
+		if m == root || strings.HasPrefix(m, root+"/") {
+			mounts = append(mounts, m)
+		}
+	}
+	return mounts, scanner.Err()
+}
+
+// unescapeMountPath undoes the octal escapes mountinfo uses for spaces
+// and other special characters in paths.
+func unescapeMountPath(s string) string {
+	var b strings.Builder
+	for i := 0; i < len(s); i++ {
+		if s[i] == '\\' && i+3 < len(s) {
+			if n, err := strconv.ParseUint(s[i+1:i+4], 8, 8); err == nil {
+				b.WriteByte(byte(n))
+				i += 3
+				continue
+			}
+		}
+		b.WriteByte(s[i])
+	}
+	return b.String()
+}
+
+// loopbackUp brings up lo, the only interface of a new network namespace.
+func loopbackUp() error {
+	fd, err := syscall.Socket(syscall.AF_INET, syscall.SOCK_DGRAM|syscall.SOCK_CLOEXEC, 0)
+	if err != nil {
+		return err
+	}
+	defer syscall.Close(fd)
+	var req struct {
+		Name  [syscall.IFNAMSIZ]byte
+		Flags uint16
+		_     [22]byte
+	}
+	copy(req.Name[:], "lo")
+	if _, _, errno := syscall.Syscall(syscall.SYS_IOCTL, uintptr(fd), syscall.SIOCGIFFLAGS, uintptr(unsafe.Pointer(&req))); errno != 0 {
+		return errno
+	}
+	req.Flags |= syscall.IFF_UP | syscall.IFF_RUNNING
+	if _, _, errno := syscall.Syscall(syscall.SYS_IOCTL, uintptr(fd), syscall.SIOCSIFFLAGS, uintptr(unsafe.Pointer(&req))); errno != 0 {
+		return errno
+	}
+	return nil
+}
+
+// superviseSandboxed runs the job as SandboxUID and waits for it. As PID 1
+// of the namespace the init process only gets the signals it handles, so
+// it catches the ones used to stop a job and passes them on.
+func superviseSandboxed(cfg sandboxConfig, argv []string) int {
+	const prSetNoNewPrivs = 38
+	if _, _, errno := syscall.RawSyscall(syscall.SYS_PRCTL, prSetNoNewPrivs, 1, 0); errno != 0 {
+		fmt.Fprintf(os.Stderr, "sandbox: set no_new_privs: %v\n", errno)
+		return sandboxSetupFailed
+	}
+
+	cmd := exec.Command(argv[0], argv[1:]...)
+	cmd.Dir = cfg.Dir
+	cmd.Stdin, cmd.Stdout, cmd.Stderr = os.Stdin, os.Stdout, os.Stderr
+	cmd.SysProcAttr = &syscall.SysProcAttr{
+		Setpgid:    true,
+		Credential: &syscall.Credential{Uid: SandboxUID, Gid: SandboxUID},
+	}
+	signals := make(chan os.Signal, 1)
+	signal.Notify(signals, syscall.SIGTERM, syscall.SIGINT, syscall.SIGHUP, syscall.SIGQUIT)
+	if err := cmd.Start(); err != nil {
+		fmt.Fprintf(os.Stderr, "sandbox: %v\n", err)
+		if errors.Is(err, exec.ErrNotFound) || errors.Is(err, fs.ErrNotExist) {
+			return 127
+		}
+		return 126
+	}
+	go func() {
+		for sig := range signals {
+			syscall.Kill(-cmd.Process.Pid, sig.(syscall.Signal))
+		}
+	}()
+	cmd.Wait()
+	if ws, ok := cmd.ProcessState.Sys().(syscall.WaitStatus); ok && ws.Signaled() {
+		return 128 + int(ws.Signal())
+	}
+	return cmd.ProcessState.ExitCode()
+}
@@ -0,0 +1,26 @@
+//go:build !linux
+
+package executer
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"os/exec"
+)
+
+var errNoSandbox = errors.New("sandboxed jobs are only available on Linux")
+
+func sandboxCommand(ctx context.Context, cmd *exec.Cmd, volumePath string, sandbox Sandbox) (*exec.Cmd, error) {
+	return nil, errNoSandbox
+}
+
+func CheckSandbox() error {
+	return errNoSandbox
+}
+
+func SandboxInit() {
+	fmt.Fprintf(os.Stderr, "sandbox: %v\n", errNoSandbox)
+	os.Exit(sandboxSetupFailed)
+}
@@ -0,0 +1,174 @@
+package gpu
+
+import (
+	"errors"
+	"fmt"
+	"sort"
+	"sync"
+
+	"gpu-runner/internal/logger"
+)
+
+var gpuLogger = logger.Server
+
+// ErrBusy is returned by Allocate when the devices a request needs exist
+// but some of them are in use.
+var ErrBusy = errors.New("not enough free GPUs")
+
+// Allocator hands the devices of an inventory out to jobs, each device to
+// at most one job at a time.
+type Allocator struct {
+	mu      sync.Mutex
+	devices []Device
+	owners  map[int]string
+}
+
+// Assignment is a device and the job using it, if any.
+type Assignment struct {
+	Device
+	JobID string `json:"job_id,omitempty"`
+}
+
+// NewAllocator reads the provider's inventory once. Devices that come and
+// go later are not noticed until the server restarts.
+func NewAllocator(provider Provider) (*Allocator, error) {
+	devices, err := provider.Devices()
+	if err != nil {
+		return nil, err
+	}
+	sort.Slice(devices, func(i, j int) bool { return devices[i].Index < devices[j].Index })
+	for _, d := range devices {
+		gpuLogger.Info("GPU available", "index", d.Index, "model", d.Model, "memory_mib", d.MemoryBytes>>20, "uuid", d.UUID)
+	}
+	return &Allocator{devices: devices, owners: make(map[int]string)}, nil
+}
+
+// Devices returns the inventory and who is using each device.
+func (a *Allocator) Devices() []Assignment {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	out := make([]Assignment, len(a.devices))
+	for i, d := range a.devices {
+		out[i] = Assignment{Device: d, JobID: a.owners[d.Index]}
+	}
+	return out
+}
+
+// Satisfiable reports why req could never be met by this inventory, even
+// with every device free.
+func (a *Allocator) Satisfiable(req Request) error {
+	if err := req.Validate(); err != nil {
+		return err
+	}
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	if n := len(a.candidates(req, false)); n < req.Count {
+		return fmt.Errorf("job asks for %s but this server has only %d that match", req, n)
+	}
+	return nil
+}
+
+// Fits reports whether req could be allocated right now.
+func (a *Allocator) Fits(req Request) bool {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	return len(a.candidates(req, true)) >= req.Count
+}
+
+// Allocate assigns req.Count free matching devices to jobID and returns
+// them, ordered by index; see pick for which. A job that already holds
+// devices gets the same ones back.
+func (a *Allocator) Allocate(jobID string, req Request) ([]Device, error) {
+	if err := req.Validate(); err != nil {
+		return nil, err
+	}
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	if held := a.held(jobID); len(held) > 0 {
+		return held, nil
+	}
+	free := a.candidates(req, true)
+	if len(free) < req.Count {
+		return nil, fmt.Errorf("%w: job %s asks for %s, %d free", ErrBusy, jobID, req, len(free))
+	}
+	picked := pick(free, req.Count)
+	sort.Slice(picked, func(i, j int) bool { return picked[i].Index < picked[j].Index })
+	indices := make([]int, len(picked))
+	for i, d := range picked {
+		a.owners[d.Index] = jobID
+		indices[i] = d.Index
+	}
+	gpuLogger.Info("Allocated GPUs", "job_id", jobID, "devices", indices)
+	return picked, nil
+}
+
+// Release frees the devices held by jobID, if any.
+func (a *Allocator) Release(jobID string) {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	var indices []int
+	for index, owner := range a.owners {
+		if owner == jobID {
+			delete(a.owners, index)
+			indices = append(indices, index)
+		}
+	}
+	if len(indices) > 0 {
+		sort.Ints(indices)
+		gpuLogger.Info("Released GPUs", "job_id", jobID, "devices", indices)
+	}
+}
+
+// pick chooses count of the free devices, keeping the ones with the most
+// memory for the jobs that need them: the devices with the least memory go
+// first. A job that needs several devices gets devices of one model when
+// enough of one model are free, the smallest such model.
+func pick(free []Device, count int) []Device {
+	sort.SliceStable(free, func(i, j int) bool { return free[i].MemoryBytes < free[j].MemoryBytes })
+	byModel := make(map[string][]Device)
+	for _, d := range free {
+		byModel[d.Model] = append(byModel[d.Model], d)
+	}
+	for _, d := range free {
+		if same := byModel[d.Model]; len(same) >= count {
+			return same[:count]
+		}
+	}
+	return free[:count]
+}
+
+// candidates lists the devices matching req, in index order, leaving out
+// the busy ones when free is set. The caller holds a.mu.
+func (a *Allocator) candidates(req Request, free bool) []Device {
+	var out []Device
+	for _, d := range a.devices {
+		if free && a.owners[d.Index] != "" {
+			continue
+		}
+		if req.Matches(d) {
+			out = append(out, d)
+		}
+	}
+	return out
+}
+
+// held lists the devices jobID holds, in index order. The caller holds
+// a.mu.
+func (a *Allocator) held(jobID string) []Device {
+	var out []Device
+	for _, d := range a.devices {
+		if a.owners[d.Index] == jobID {
+			out = append(out, d)
+		}
+	}
+	return out
+}
+
+// Indices returns the devices' indices.
+func Indices(devices []Device) []int {
+	out := make([]int, len(devices))
+	for i, d := range devices {
+		out[i] = d.Index
+	}
+	return out
+}
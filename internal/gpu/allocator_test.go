@@ -0,0 +1,175 @@
+package gpu
+
+import (
+	"errors"
+	"slices"
+	"testing"
+)
+
+// newTestAllocator returns an Allocator over a fake inventory given as a
+// ParseFake spec.
+func newTestAllocator(t *testing.T, spec string) *Allocator {
+	t.Helper()
+	fake, err := ParseFake(spec)
+	if err != nil {
+		t.Fatalf("ParseFake(%q): %v", spec, err)
+	}
+	a, err := NewAllocator(fake)
+	if err != nil {
+		t.Fatalf("NewAllocator: %v", err)
+	}
+	return a
+}
+
+func allocate(t *testing.T, a *Allocator, jobID string, req Request) []int {
+	t.Helper()
+	devices, err := a.Allocate(jobID, req)
+	if err != nil {
+		t.Fatalf("Allocate(%s, %s): %v", jobID, req, err)
+	}
+	return Indices(devices)
+}
+
+func TestParseFake(t *testing.T) {
+	fake, err := ParseFake("2xA100:81920, T4:15360")
+	if err != nil {
+		t.Fatalf("ParseFake: %v", err)
+	}
+	want := []Device{
+		{Index: 0, UUID: "GPU-fake-0000", Model: "A100", MemoryBytes: 81920 << 20},
+		{Index: 1, UUID: "GPU-fake-0001", Model: "A100", MemoryBytes: 81920 << 20},
+		{Index: 2, UUID: "GPU-fake-0002", Model: "T4", MemoryBytes: 15360 << 20},
+	}
+	if !slices.Equal(fake.Inventory, want) {
+		t.Errorf("inventory = %+v, want %+v", fake.Inventory, want)
+	}
+}
+
+func TestParseFakeRejects(t *testing.T) {
+	for _, spec := range []string{"", " , ", "A100", ":1024", "A100:lots", "A100:-1", "0xA100:1024"} {
+		if fake, err := ParseFake(spec); err == nil {
+			t.Errorf("ParseFake(%q) = %+v, want an error", spec, fake.Inventory)
+		}
+	}
+}
+
+func TestParseNVML(t *testing.T) {
+	out := `1, GPU-bbbb, NVIDIA A100-SXM4-80GB, 81920
+0, GPU-aaaa, Tesla T4, 15360
+
+`
+	devices, err := parseNVML(out)
+	if err != nil {
+		t.Fatalf("parseNVML: %v", err)
+	}
+	want := []Device{
+		{Index: 0, UUID: "GPU-aaaa", Model: "Tesla T4", MemoryBytes: 15360 << 20},
+		{Index: 1, UUID: "GPU-bbbb", Model: "NVIDIA A100-SXM4-80GB", MemoryBytes: 81920 << 20},
+	}
+	if !slices.Equal(devices, want) {
+		t.Errorf("devices = %+v, want %+v", devices, want)
+	}
+
+	for _, bad := range []string{"0, GPU-aaaa, Tesla T4", "x, GPU-aaaa, Tesla T4, 15360", "0, GPU-aaaa, Tesla T4, [N/A]"} {
+		if _, err := parseNVML(bad); err == nil {
+			t.Errorf("parseNVML(%q) succeeded, want an error", bad)
+		}
+	}
+}
+
+func TestPickKeepsLargestDevicesFree(t *testing.T) {
+	free := []Device{
+		{Index: 0, Model: "A100", MemoryBytes: 80 << 30},
+		{Index: 1, Model: "T4", MemoryBytes: 16 << 30},
+		{Index: 2, Model: "A100", MemoryBytes: 80 << 30},
+		{Index: 3, Model: "L4", MemoryBytes: 24 << 30},
+	}
+	for _, tc := range []struct {
+		count int
+		want  []int
+	}{
+		{1, []int{1}},
+		// Only the A100s come two of a kind.
+		{2, []int{0, 2}},
+		{3, []int{1, 3, 0}},
+	} {
+		got := Indices(pick(slices.Clone(free), tc.count))
+		if !slices.Equal(got, tc.want) {
+			t.Errorf("pick(%d) = %v, want %v", tc.count, got, tc.want)
+		}
+	}
+}
+
+func TestAllocateAndRelease(t *testing.T) {
+	a := newTestAllocator(t, "2xA100:81920,2xT4:15360")
+
+	if got := allocate(t, a, "1", Request{Count: 1}); !slices.Equal(got, []int{2}) {
+		t.Errorf("job 1 got %v, want the smallest device, 2", got)
+	}
+	if got := allocate(t, a, "2", Request{Count: 2}); !slices.Equal(got, []int{0, 1}) {
+		t.Errorf("job 2 got %v, want the two A100s rather than mixed models", got)
+	}
+	if got := allocate(t, a, "3", Request{Count: 1, Model: "t4"}); !slices.Equal(got, []int{3}) {
+		t.Errorf("job 3 got %v, want the other T4", got)
+	}
+
+	_, err := a.Allocate("4", Request{Count: 1})
+	if !errors.Is(err, ErrBusy) {
+		t.Fatalf("Allocate on a full server = %v, want ErrBusy", err)
+	}
+	if a.Fits(Request{Count: 1}) {
+		t.Error("Fits with every device taken")
+	}
+
+	a.Release("2")
+	a.Release("2")
+	if !a.Fits(Request{Count: 2, Model: "A100"}) {
+		t.Error("released devices are not free again")
+	}
+	for _, d := range a.Devices() {
+		want := map[int]string{2: "1", 3: "3"}[d.Index]
+		if d.JobID != want {
+			t.Errorf("device %d is held by %q, want %q", d.Index, d.JobID, want)
+		}
+	}
+}
+
+func TestAllocateReturnsHeldDevices(t *testing.T) {
+	a := newTestAllocator(t, "4xA100:81920")
+	first := allocate(t, a, "1", Request{Count: 2})
+	again := allocate(t, a, "1", Request{Count: 2})
+	if !slices.Equal(first, again) {
+		t.Errorf("second Allocate = %v, want the held devices %v", again, first)
+	}
+	if got := allocate(t, a, "2", Request{Count: 2}); len(got) != 2 {
+		t.Errorf("job 2 got %v, want the two devices job 1 left", got)
+	}
+}
+
+func TestSatisfiable(t *testing.T) {
+	a := newTestAllocator(t, "2xA100:81920,T4:15360")
+	allocate(t, a, "busy", Request{Count: 3})
+
+	// Satisfiable ignores what is in use.
+	for _, req := range []Request{
+		{Count: 3},
+		{Count: 2, Model: "a100"},
+		{Count: 1, MinMemoryBytes: 81920 << 20},
+	} {
+		if err := a.Satisfiable(req); err != nil {
+			t.Errorf("Satisfiable(%s) = %v, want nil", req, err)
+		}
+	}
+	for _, req := range []Request{
+		{Count: 0},
+		{Count: 1, MinMemoryBytes: -1},
+		{Count: 4},
+		{Count: 1, Model: "h100"},
+		{Count: 2, Model: "t4"},
+		{Count: 1, MinMemoryBytes: 81921 << 20},
+	} {
+		if err := a.Satisfiable(req); err == nil {
+			t.Errorf("Satisfiable(%+v) = nil, want an error", req)
+		}
+	}
+}
@@ -0,0 +1,67 @@
+// Package gpu models the GPUs of the machine the server runs on and hands
+// them out to jobs.
+package gpu
+
+import (
+	"fmt"
+	"strings"
+)
+
+// Device is one GPU. Index is the device's position in NVML's order,
+// which CUDA uses too when CUDA_DEVICE_ORDER is PCI_BUS_ID.
+type Device struct {
+	Index       int    `json:"index"`
+	UUID        string `json:"uuid,omitempty"`
+	Model       string `json:"model"`
+	MemoryBytes int64  `json:"memory_bytes"`
+}
+
+// Provider lists the machine's GPUs.
+type Provider interface {
+	Devices() ([]Device, error)
+}
+
+// Request is what a job asks for: Count devices, each of them of Model
+// when set and with at least MinMemoryBytes of memory.
+type Request struct {
+	Count int `json:"count"`
+	// Model matches device models case-insensitively and by substring,
+	// so "a100" matches "NVIDIA A100-SXM4-80GB".
+	Model          string `json:"model,omitempty"`
+	MinMemoryBytes int64  `json:"min_memory,omitempty"`
+}
+
+// Validate checks the request on its own, before any inventory is
+// consulted.
+func (r Request) Validate() error {
+	if r.Count < 1 {
+		return fmt.Errorf("gpus: count must be at least 1")
+	}
+	if r.MinMemoryBytes < 0 {
+		return fmt.Errorf("gpus: min_memory must not be negative")
+	}
+	return nil
+}
+
+// Matches reports whether d is a device the request can use.
+func (r Request) Matches(d Device) bool {
+	if r.Model != "" && !strings.Contains(strings.ToLower(d.Model), strings.ToLower(r.Model)) {
+		return false
+	}
+	return d.MemoryBytes >= r.MinMemoryBytes
+}
+
+// String describes the request for logs and error messages.
+func (r Request) String() string {
+	s := fmt.Sprintf("%d GPU", r.Count)
+	if r.Count != 1 {
+		s += "s"
+	}
+	if r.Model != "" {
+		s += " of model " + r.Model
+	}
+	if r.MinMemoryBytes > 0 {
+		s += fmt.Sprintf(" with at least %d MiB", r.MinMemoryBytes>>20)
+	}
+	return s
+}
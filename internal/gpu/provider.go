@@ -0,0 +1,121 @@
+package gpu
+
+import (
+	"bytes"
+	"fmt"
+	"os/exec"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// NVML is the Provider for NVIDIA GPUs. It reads the inventory NVML
+// reports through nvidia-smi, which ships with the driver, so the server
+// needs neither cgo nor the NVML headers to build.
+type NVML struct {
+	// Binary is nvidia-smi.
+	Binary string
+}
+
+// NewNVML returns an NVML provider using binary, which is looked up on PATH
+// unless it is a path.
+func NewNVML(binary string) (*NVML, error) {
+	resolved, err := exec.LookPath(binary)
+	if err != nil {
+		return nil, fmt.Errorf("nvml: %w", err)
+	}
+	return &NVML{Binary: resolved}, nil
+}
+
+func (n *NVML) Devices() ([]Device, error) {
+	cmd := exec.Command(n.Binary, "--query-gpu=index,uuid,name,memory.total", "--format=csv,noheader,nounits")
+	var stderr bytes.Buffer
+	cmd.Stderr = &stderr
+	out, err := cmd.Output()
+	if err != nil {
+		return nil, fmt.Errorf("nvml: %w: %s", err, strings.TrimSpace(stderr.String()))
+	}
+	return parseNVML(string(out))
+}
+
+// parseNVML parses nvidia-smi's CSV, one device per line with its memory
+// in MiB.
+func parseNVML(out string) ([]Device, error) {
+	var devices []Device
+	for _, line := range strings.Split(strings.TrimSpace(out), "\n") {
+		if strings.TrimSpace(line) == "" {
+			continue
+		}
+		fields := strings.Split(line, ",")
+		if len(fields) != 4 {
+			return nil, fmt.Errorf("nvml: unexpected device line %q", line)
+		}
+		for i := range fields {
+			fields[i] = strings.TrimSpace(fields[i])
+		}
+		index, err := strconv.Atoi(fields[0])
+		if err != nil {
+			return nil, fmt.Errorf("nvml: device index %q: %w", fields[0], err)
+		}
+		mib, err := strconv.ParseInt(fields[3], 10, 64)
+		if err != nil {
+			return nil, fmt.Errorf("nvml: memory of device %d %q: %w", index, fields[3], err)
+		}
+		devices = append(devices, Device{Index: index, UUID: fields[1], Model: fields[2], MemoryBytes: mib << 20})
+	}
+	sort.Slice(devices, func(i, j int) bool { return devices[i].Index < devices[j].Index })
+	return devices, nil
+}
+
+// Fake is a Provider with a fixed inventory, for tests and for running the
+// server on machines without GPUs.
+type Fake struct {
+	Inventory []Device
+}
+
+func (f *Fake) Devices() ([]Device, error) {
+	return append([]Device(nil), f.Inventory...), nil
+}
+
+// ParseFake builds a Fake from a spec like "2xA100:81920,T4:15360": a comma
+// separated list of models, each with an optional count and its memory in
+// MiB. Devices are indexed in the order they are listed.
+func ParseFake(spec string) (*Fake, error) {
+	fake := &Fake{}
+	for _, part := range strings.Split(spec, ",") {
+		part = strings.TrimSpace(part)
+		if part == "" {
+			continue
+		}
+		count := 1
+		if n, rest, ok := strings.Cut(part, "x"); ok {
+			if c, err := strconv.Atoi(n); err == nil {
+				if c < 1 {
+					return nil, fmt.Errorf("fake gpus: count in %q must be at least 1", part)
+				}
+				count, part = c, rest
+			}
+		}
+		model, mem, ok := strings.Cut(part, ":")
+		if !ok || model == "" {
+			return nil, fmt.Errorf("fake gpus: %q is not MODEL:MEMORY_MIB", part)
+		}
+		mib, err := strconv.ParseInt(mem, 10, 64)
+		if err != nil || mib < 0 {
+			return nil, fmt.Errorf("fake gpus: memory in %q must be a number of MiB", part)
+		}
+		for i := 0; i < count; i++ {
+			index := len(fake.Inventory)
+			fake.Inventory = append(fake.Inventory, Device{
+				Index:       index,
+				UUID:        fmt.Sprintf("GPU-fake-%04d", index),
+				Model:       model,
+				MemoryBytes: mib << 20,
+			})
+		}
+	}
+	if len(fake.Inventory) == 0 {
+		return nil, fmt.Errorf("fake gpus: %q lists no devices", spec)
+	}
+	return fake, nil
+}
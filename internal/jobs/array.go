@@ -0,0 +1,180 @@
+package jobs
+
+import (
+    "fmt"
+    "maps"
+    "regexp"
+    "slices"
+    "strconv"
+    "strings"
+)
+
+// MaxArraySize caps how many child jobs one array may expand to.
+var MaxArraySize = 10000
+
+// ArraySpec expands one job request into child jobs: one per index from
+// Start to End inclusive, or, when Params is set, one per combination of
+// parameter values. MaxConcurrency, if set, limits how many children are
+// queued or running at once; the rest wait in StatusBlocked.
+type ArraySpec struct {
+    Start          int                 `json:"start" yaml:"start,omitempty"`
+    End            int                 `json:"end" yaml:"end,omitempty"`
+    Params         map[string][]string `json:"params,omitempty" yaml:"params,omitempty"`
+    MaxConcurrency int                 `json:"max_concurrency,omitempty" yaml:"max_concurrency,omitempty"`
+}
+
+// Environment variables set on every array child, alongside one variable
+// per parameter.
+const (
+    EnvArrayID    = "ARRAY_ID"
+    EnvArrayIndex = "ARRAY_INDEX"
+    EnvArraySize  = "ARRAY_SIZE"
+)
+
+var envName = regexp.MustCompile(`^[A-Za-z_][A-Za-z0-9_]*$`)
+
+// Size returns the number of child jobs the array expands to.
+func (a *ArraySpec) Size() int {
+    if len(a.Params) == 0 {
+        return a.End - a.Start + 1
+    }
+    size := 1
+    for _, values := range a.Params {
+        size *= len(values)
+        if size > MaxArraySize {
+            break
+        }
+    }
+    return size
+}
+
+func (a *ArraySpec) validate() error {
+    if len(a.Params) > 0 {
+        if a.Start != 0 || a.End != 0 {
+            return fmt.Errorf("array takes either an index range or params, not both")
+        }
+        for name, values := range a.Params {
+            if !envName.MatchString(name) || strings.HasPrefix(name, "ARRAY_") {
+                return fmt.Errorf("invalid array parameter name %q", name)
+            }
+            if len(values) == 0 {
+                return fmt.Errorf("array parameter %q has no values", name)
+            }
+        }
+    } else if a.End < a.Start || a.Start < 0 {
+        return fmt.Errorf("array range %d-%d is invalid", a.Start, a.End)
+    }
+    if size := a.Size(); size > MaxArraySize {
+        return fmt.Errorf("array expands to more than %d jobs", MaxArraySize)
+    }
+    if a.MaxConcurrency < 0 {
+        return fmt.Errorf("max_concurrency must not be negative")
+    }
+    return nil
+}
+
+// point returns the index and parameter values of child i. Parameters vary
+// in name order with the last name changing fastest.
+func (a *ArraySpec) point(i int) (int, map[string]string) {
+    if len(a.Params) == 0 {
+        return a.Start + i, nil
+    }
+    names := slices.Sorted(maps.Keys(a.Params))
+    values := make(map[string]string, len(names))
+    rest := i
+    for k := len(names) - 1; k >= 0; k-- {
+        options := a.Params[names[k]]
+        values[names[k]] = options[rest%len(options)]
+        rest /= len(options)
+    }
+    return i, values
+}
+
+// ArrayChildren expands a stored array job into its children. Each child
+// gets the array's variables in Env, and {{NAME}} in the command template
+// or argv is replaced by the value of variable NAME.
+func (j *Job) ArrayChildren() []*Job {
+    size := j.Array.Size()
+    children := make([]*Job, 0, size)
+    for i := 0; i < size; i++ {
+        index, params := j.Array.point(i)
+        env := maps.Clone(j.Env)
+        if env == nil {
+            env = make(map[string]string, 3+len(params))
+        }
+        env[EnvArrayID] = j.ID
+        env[EnvArrayIndex] = strconv.Itoa(index)
+        env[EnvArraySize] = strconv.Itoa(size)
+        maps.Copy(env, params)
+
+        child := *j
+        child.ID = ""
+        child.Command = substitute(j.Command, env)
+        if len(j.Argv) > 0 {
+            child.Argv = make([]string, len(j.Argv))
+            for k, arg := range j.Argv {
+                child.Argv[k] = substitute(arg, env)
+            }
+            child.Command = ShellJoin(child.Argv)
+        }
+        child.Array = nil
+        child.ArrayID = j.ID
+        child.ArrayIndex = &index
+        child.Env = env
+        child.Labels = maps.Clone(j.Labels)
+        if j.Array.MaxConcurrency > 0 && i >= j.Array.MaxConcurrency {
+            child.Status = StatusBlocked
+        }
+        children = append(children, &child)
+    }
+    return children
+}
+
+// substitute replaces each {{NAME}} in s with vars[NAME].
+func substitute(s string, vars map[string]string) string {
+    for name, value := range vars {
+        s = strings.ReplaceAll(s, "{{"+name+"}}", value)
+    }
+    return s
+}
+
+// Environ returns the job's extra environment as KEY=value pairs: Env,
+// then INPUT_<NAME> and OUTPUT_<NAME> for each input and output.
+func (j *Job) Environ() []string {
+    vars := maps.Clone(j.Env)
+    if vars == nil {
+        vars = map[string]string{}
+    }
+    for name, path := range j.Inputs {
+        vars["INPUT_"+strings.ToUpper(name)] = path
+    }
+    for name, path := range j.Outputs {
+        vars["OUTPUT_"+strings.ToUpper(name)] = path
+    }
+    env := make([]string, 0, len(vars))
+    for _, k := range slices.Sorted(maps.Keys(vars)) {
+        env = append(env, k+"="+vars[k])
+    }
+    return env
+}
+
+// validateEnv checks the names of Env, Inputs and Outputs and that every
+// input and output has a path.
+func validateEnv(env, inputs, outputs map[string]string) error {
+    for name := range env {
+        if !envName.MatchString(name) {
+            return fmt.Errorf("invalid environment variable name %q", name)
+        }
+    }
+    for kind, files := range map[string]map[string]string{"input": inputs, "output": outputs} {
+        for name, path := range files {
+            if !envName.MatchString(name) {
+                return fmt.Errorf("invalid %s name %q", kind, name)
+            }
+            if path == "" {
+                return fmt.Errorf("%s %q has no path", kind, name)
+            }
+        }
+    }
+    return nil
+}
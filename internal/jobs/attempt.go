@@ -0,0 +1,44 @@
+package jobs
+
+import "time"
+
+// Attempt is one execution of a job. Retried jobs have one Attempt per
+// run, numbered like Job.JobTrial. LogStartID and LogEndID bound the job
+// log stream entries written while the attempt ran.
+type Attempt struct {
+    JobID      string     `json:"job_id"`
+    Attempt    int        `json:"attempt"`
+    Status     JobStatus  `json:"status"`
+    WorkerID   string     `json:"worker_id,omitempty"`
+    StartedAt  *time.Time `json:"started_at,omitempty"`
+    FinishedAt *time.Time `json:"finished_at,omitempty"`
+    ExitCode   *int       `json:"exit_code,omitempty"`
+    Signal     string     `json:"signal,omitempty"`
+    TerminationSignal string `json:"termination_signal,omitempty"`
+    Error      string     `json:"error,omitempty"`
+    FailureReason FailureReason `json:"failure_reason,omitempty"`
+    PeakMemoryBytes int64   `json:"peak_memory_bytes,omitempty"`
+    CPUSeconds float64      `json:"cpu_seconds,omitempty"`
+    LogStartID string     `json:"log_start_id,omitempty"`
+    LogEndID   string     `json:"log_end_id,omitempty"`
+}
+
+// CurrentAttempt describes the attempt the job is on, as reported by the
+// worker running it.
+func (j *Job) CurrentAttempt() Attempt {
+    return Attempt{
+        JobID:      j.ID,
+        Attempt:    j.JobTrial,
+        Status:     j.Status,
+        WorkerID:   j.WorkerID,
+        StartedAt:  j.StartedAt,
+        FinishedAt: j.FinishedAt,
+        ExitCode:   j.ExitCode,
+        Signal:     j.Signal,
+        TerminationSignal: j.TerminationSignal,
+        Error:      j.Error,
+        FailureReason: j.FailureReason,
+        PeakMemoryBytes: j.PeakMemoryBytes,
+        CPUSeconds: j.CPUSeconds,
+    }
+}
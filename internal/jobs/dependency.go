@@ -0,0 +1,61 @@
+package jobs
+
+import "fmt"
+
+// DependencyCondition is the outcome of a parent job that lets its
+// dependent run.
+type DependencyCondition string
+
+const (
+    // OnSuccess runs the dependent only if the parent succeeded.
+    OnSuccess DependencyCondition = "on_success"
+    // OnCompletion runs the dependent once the parent finished, however it
+    // ended.
+    OnCompletion DependencyCondition = "on_completion"
+    // OnFailure runs the dependent only if the parent failed, timed out or
+    // died, e.g. to clean up or alert.
+    OnFailure DependencyCondition = "on_failure"
+)
+
+// Dependency makes a job wait, in StatusBlocked, for another job to finish.
+type Dependency struct {
+    JobID     string              `json:"job_id"`
+    Condition DependencyCondition `json:"condition,omitempty"`
+}
+
+// SatisfiedBy reports whether a parent that ended in status lets the
+// dependent run. It is only meaningful for terminal statuses.
+func (d Dependency) SatisfiedBy(status JobStatus) bool {
+    switch d.Condition {
+    case OnCompletion:
+        return true
+    case OnFailure:
+        return status == StatusFailed || status == StatusTimedOut || status == StatusDead
+    default:
+        return status == StatusSuccess
+    }
+}
+
+// validateDependencies fills in the default condition and rejects empty,
+// repeated or unknown entries.
+func validateDependencies(deps []Dependency) error {
+    seen := make(map[string]bool, len(deps))
+    for i := range deps {
+        d := &deps[i]
+        if d.JobID == "" {
+            return fmt.Errorf("depends_on entries need a job_id")
+        }
+        if seen[d.JobID] {
+            return fmt.Errorf("depends_on lists job %s more than once", d.JobID)
+        }
+        seen[d.JobID] = true
+        switch d.Condition {
+        case "":
+            d.Condition = OnSuccess
+        case OnSuccess, OnCompletion, OnFailure:
+        default:
+            return fmt.Errorf("unknown dependency condition %q", d.Condition)
+        }
+    }
+    return nil
+}
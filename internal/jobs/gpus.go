@@ -0,0 +1,67 @@
+package jobs
+
+import (
+    "fmt"
+
+    "gpu-runner/internal/gpu"
+)
+
+// Fits reports whether the job can be handed to a worker now: it needs no
+// GPUs, or enough matching ones are free. A job whose request this server
+// could never meet fits too, so a worker takes it and fails it instead of
+// leaving it in the queue forever.
+func (jq *JobQueue) Fits(job *Job) bool {
+    if jq.GPUs == nil || job.GPUs == nil {
+        return true
+    }
+    return jq.GPUs.Fits(*job.GPUs) || jq.GPUs.Satisfiable(*job.GPUs) != nil
+}
+
+// AssignGPUs allocates the devices the job asked for and records them in
+// GPUDevices. It fails with gpu.ErrBusy when they are in use, and the job
+// should go back to the queue.
+func (jq *JobQueue) AssignGPUs(job *Job) error {
+    job.GPUDevices = nil
+    if job.GPUs == nil {
+        return nil
+    }
+    if jq.GPUs == nil {
+        return fmt.Errorf("job asks for %s but this server has no GPUs", job.GPUs)
+    }
+    if err := jq.GPUs.Satisfiable(*job.GPUs); err != nil {
+        return err
+    }
+    devices, err := jq.GPUs.Allocate(job.ID, *job.GPUs)
+    if err != nil {
+        return err
+    }
+    job.GPUDevices = gpu.Indices(devices)
+    return nil
+}
+
+// ReleaseGPUs frees the devices the job holds, if any.
+func (jq *JobQueue) ReleaseGPUs(job *Job) {
+    if jq.GPUs != nil {
+        jq.GPUs.Release(job.ID)
+    }
+}
+
+// visibleGPUs is the executer.Spec GPUs of the job's run: nil when the
+// server doesn't manage GPUs, and otherwise the job's devices, none for a
+// job that asked for none.
+func (jq *JobQueue) visibleGPUs(job *Job) []int {
+    if jq.GPUs == nil {
+        return nil
+    }
+    return append([]int{}, job.GPUDevices...)
+}
+
+// ensureGPUs allocates the job's devices if they weren't allocated before
+// it reached a worker. That happens to a job this server can't run, which
+// the allocation fails with the reason why.
+func (jq *JobQueue) ensureGPUs(job *Job) error {
+    if job.GPUs == nil || len(job.GPUDevices) == job.GPUs.Count {
+        return nil
+    }
+    return jq.AssignGPUs(job)
+}
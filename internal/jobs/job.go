@@ -1,7 +1,12 @@
 package jobs
 
 import (
+"fmt"
+"strings"
 "time"
+"unicode"
+"gpu-runner/internal/executer"
+"gpu-runner/internal/gpu"
 "gpu-runner/internal/logger"
 )
 type JobStatus string
@@ -15,14 +20,160 @@ type JobStorage int
 
 type Job struct {
     ID        string     `json:"id"`
+    // Command is the shell string the job runs. For an argv job it is the
+    // argv quoted for display, and Argv is what runs.
     Command   string     `json:"command"`
+    Argv      []string   `json:"argv,omitempty"`
+    // Shell runs Command; empty means the server's default shell.
+    Shell     string     `json:"shell,omitempty"`
+    // Runtime names the executor backend that runs the job; empty means
+    // the server's default.
+    Runtime   string     `json:"runtime,omitempty"`
+    // Image is the container image the job runs in on the container
+    // runtime.
+    Image     string     `json:"image,omitempty"`
+    // Sandbox isolates the job from the host; NoNetwork also cuts it off
+    // from the network, and implies Sandbox. See executer.Sandbox.
+    Sandbox   bool       `json:"sandbox,omitempty"`
+    NoNetwork bool       `json:"no_network,omitempty"`
+    // Workdir is the working directory, relative to the job's volume
+    // unless absolute.
+    Workdir   string     `json:"workdir,omitempty"`
     Status    JobStatus  `json:"status"`
     Logger    *logger.JobLogger `json:"logger"`
     CreatedAt time.Time  `json:"created_at"`
+    // StorageBytes is the quota on the job's workspace, VolumePath, which
+    // is created when the job first starts.
     StorageBytes   JobStorage `json:"storage"`
     VolumePath string    `json:"volume_path"`
-    StartedAt string     `json:"started_at"`
-    FinishedAt string    `json:"finished_at"`
+    StartedAt  *time.Time `json:"started_at,omitempty"`
+    FinishedAt *time.Time `json:"finished_at,omitempty"`
+    ExitCode   *int      `json:"exit_code,omitempty"`
+    Signal     string    `json:"signal,omitempty"`
+    // TerminationSignal is the signal the runner sent to stop the job's
+    // process group on cancellation or timeout.
+    TerminationSignal string `json:"termination_signal,omitempty"`
+    WorkerID   string    `json:"worker_id,omitempty"`
+    Error      string    `json:"error,omitempty"`
+    FailureReason FailureReason `json:"failure_reason,omitempty"`
+    // MaxRetries is the highest JobTrial that may still be retried: the
+    // policy's retry count at creation, raised when a dead job is requeued.
     MaxRetries int       `json:"max_retries"`
     JobTrial   int       `json:"job_trial"`
-}
\ No newline at end of file
+    RetryPolicy *RetryPolicy `json:"retry_policy,omitempty"`
+    Labels     map[string]string `json:"labels,omitempty"`
+    // Priority orders the pending queue; higher runs sooner. See
+    // ValidatePriority for the accepted range.
+    Priority   int       `json:"priority"`
+    // RunAt holds the job in StatusScheduled until this time.
+    RunAt      *time.Time    `json:"run_at,omitempty"`
+    TimeoutSeconds int64     `json:"timeout_seconds,omitempty"`
+    Deadline   *time.Time    `json:"deadline,omitempty"`
+    // DependsOn holds the job in StatusBlocked until every listed job has
+    // finished in a way its condition accepts.
+    DependsOn  []Dependency  `json:"depends_on,omitempty"`
+    WorkflowID string        `json:"workflow_id,omitempty"`
+    // WorkflowStep is the job's step name within its workflow.
+    WorkflowStep string      `json:"workflow_step,omitempty"`
+    // Array is set on an array's parent job, which never runs itself; its
+    // status summarises its children.
+    Array      *ArraySpec    `json:"array,omitempty"`
+    ArrayID    string        `json:"array_id,omitempty"`
+    ArrayIndex *int          `json:"array_index,omitempty"`
+    // Env is added to the command's environment.
+    Env        map[string]string `json:"env,omitempty"`
+    // Inputs and Outputs map names to the files the job reads and writes,
+    // relative to its working directory or absolute. The command sees them
+    // as INPUT_<NAME> and OUTPUT_<NAME>.
+    Inputs     map[string]string `json:"inputs,omitempty"`
+    Outputs    map[string]string `json:"outputs,omitempty"`
+    // Secrets maps environment variable names to secret names. Values are
+    // looked up when the job starts and masked in its log.
+    Secrets    map[string]string `json:"secrets,omitempty"`
+    // CPU (in cores), MemoryBytes and MaxPids limit the job's processes
+    // together through its cgroup; zero leaves a resource unlimited.
+    CPU        float64       `json:"cpu,omitempty"`
+    MemoryBytes int64        `json:"memory,omitempty"`
+    MaxPids    int           `json:"max_pids,omitempty"`
+    // PeakMemoryBytes and CPUSeconds are what the last run used, when the
+    // host could measure it.
+    PeakMemoryBytes int64    `json:"peak_memory_bytes,omitempty"`
+    CPUSeconds float64       `json:"cpu_seconds,omitempty"`
+    // GPUs is what the job needs; it waits in the queue until that many
+    // matching devices are free. GPUDevices are the indices of the
+    // devices its current or last run was given.
+    GPUs       *gpu.Request  `json:"gpus,omitempty"`
+    GPUDevices []int         `json:"gpu_devices,omitempty"`
+}
+
+// ExecCommand returns the command the executor runs for the job.
+func (j *Job) ExecCommand() executer.Command {
+    if len(j.Argv) > 0 {
+        return executer.Command{Argv: j.Argv, Dir: j.Workdir, Limits: j.Limits()}
+    }
+    return executer.Command{Script: j.Command, Shell: j.Shell, Dir: j.Workdir, Limits: j.Limits()}
+}
+
+// ValidatePriority rejects priorities outside MinPriority..MaxPriority.
+func ValidatePriority(p int) error {
+    if p < MinPriority || p > MaxPriority {
+        return fmt.Errorf("priority must be between %d and %d", MinPriority, MaxPriority)
+    }
+    return nil
+}
+
+// ValidateRuntime checks the form of a runtime name. Whether the server
+// has such a runtime is checked when the job is submitted.
+func ValidateRuntime(name string) error {
+    if len(name) > 64 || strings.Trim(name, "abcdefghijklmnopqrstuvwxyz0123456789-_.") != "" {
+        return fmt.Errorf("runtime %q must be a lowercase name", name)
+    }
+    return nil
+}
+
+// ValidateImage checks that image can be passed to a container runtime as
+// an image reference.
+func ValidateImage(image string) error {
+    if strings.HasPrefix(image, "-") || strings.ContainsFunc(image, unicode.IsSpace) {
+        return fmt.Errorf("image %q is not a valid image reference", image)
+    }
+    return nil
+}
+
+// ExecutionDeadline returns when a run starting at start must be stopped:
+// the earlier of start+TimeoutSeconds and Deadline. Jobs without a timeout
+// get DefaultTimeout.
+func (j *Job) ExecutionDeadline(start time.Time) time.Time {
+    timeout := time.Duration(j.TimeoutSeconds) * time.Second
+    if timeout <= 0 {
+        timeout = DefaultTimeout
+    }
+    end := start.Add(timeout)
+    if j.Deadline != nil && j.Deadline.Before(end) {
+        end = *j.Deadline
+    }
+    return end
+}
+
+// ResolveTimeout applies the server limits to a requested timeout. A zero
+// request gets DefaultTimeout, or MaxTimeout when an explicit deadline is
+// set so the deadline alone decides when the job stops.
+func ResolveTimeout(timeoutSeconds int64, deadline *time.Time, now time.Time) (int64, error) {
+    if timeoutSeconds < 0 {
+        return 0, fmt.Errorf("timeout_seconds must not be negative")
+    }
+    if deadline != nil && !deadline.After(now) {
+        return 0, fmt.Errorf("deadline %s is in the past", deadline.Format(time.RFC3339))
+    }
+    limit := int64(MaxTimeout / time.Second)
+    if timeoutSeconds > limit {
+        return 0, fmt.Errorf("timeout_seconds %d exceeds the server maximum of %d", timeoutSeconds, limit)
+    }
+    if timeoutSeconds == 0 {
+        if deadline != nil {
+            return limit, nil
+        }
+        return int64(DefaultTimeout / time.Second), nil
+    }
+    return timeoutSeconds, nil
+}
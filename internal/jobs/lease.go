@@ -0,0 +1,21 @@
+package jobs
+
+import (
+    "context"
+    "errors"
+    "time"
+)
+
+// ErrLeaseLost is returned by LeaseKeeper.ExtendLease when the lease has
+// already expired and the job was taken back by the lease reaper.
+var ErrLeaseLost = errors.New("job lease lost")
+
+// HeartbeatInterval is how often a worker extends the lease on the job it
+// is running. It must be well below the lease duration.
+var HeartbeatInterval = 10 * time.Second
+
+// LeaseKeeper extends the lease on an in-flight job so it isn't reclaimed
+// and run again elsewhere.
+type LeaseKeeper interface {
+    ExtendLease(ctx context.Context, jobID string) error
+}
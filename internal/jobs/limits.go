@@ -0,0 +1,43 @@
+package jobs
+
+import (
+    "fmt"
+    "math"
+
+    "gpu-runner/internal/executer"
+)
+
+// MinMemoryLimit is the smallest memory limit a job may ask for; below it
+// even the shell that starts the command is killed.
+const MinMemoryLimit = 4 << 20
+
+// MaxCPULimit bounds the cpu field, in cores.
+const MaxCPULimit = 1024
+
+// validateLimits checks a request's cpu, memory and max_pids limits. Zero
+// leaves a resource unlimited.
+func validateLimits(cpu float64, memory int64, maxPids int) error {
+    if cpu < 0 || cpu > MaxCPULimit || math.IsNaN(cpu) {
+        return fmt.Errorf("cpu must be between 0 and %d cores", MaxCPULimit)
+    }
+    if cpu > 0 && cpu < 0.01 {
+        return fmt.Errorf("cpu must be at least 0.01 cores")
+    }
+    if memory < 0 || (memory > 0 && memory < MinMemoryLimit) {
+        return fmt.Errorf("memory must be at least %s", FormatSize(MinMemoryLimit))
+    }
+    if maxPids < 0 {
+        return fmt.Errorf("max_pids must not be negative")
+    }
+    return nil
+}
+
+// Limits returns the resource limits the executor enforces on the job.
+func (j *Job) Limits() executer.Limits {
+    return executer.Limits{CPU: j.CPU, MemoryBytes: j.MemoryBytes, MaxPids: j.MaxPids}
+}
+
+// SandboxOptions is how the job asked to be isolated from the host.
+func (j *Job) SandboxOptions() executer.Sandbox {
+    return executer.Sandbox{Enabled: j.Sandbox || j.NoNetwork, NoNetwork: j.NoNetwork}
+}
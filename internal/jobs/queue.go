@@ -1,10 +1,35 @@
 package jobs
 
-import "gpu-runner/internal/executer"
+import (
+    "gpu-runner/internal/executer"
+    "gpu-runner/internal/gpu"
+    "gpu-runner/internal/workspace"
+)
 
 type JobQueue struct {
     Queue chan *Job
-    Executor *executer.Executor
+    // Executor runs the jobs workers take off the queue, usually a
+    // Registry choosing a backend by each job's Runtime.
+    Executor executer.Executor
+    // Leases is optional; without it workers don't send heartbeats.
+    Leases LeaseKeeper
+    // Statuses lets workers see that a job was cancelled after it left
+    // the queue. Without it such a job still runs.
+    Statuses StatusReader
+    // Secrets resolves secret references when a job starts. Without it,
+    // jobs that use secrets fail.
+    Secrets SecretResolver
+    // Workspaces gives each job its directory and enforces its storage.
+    // Without it each run gets a temporary directory.
+    Workspaces *workspace.Manager
+    // GPUs hands out the server's GPUs. Without it the server has none,
+    // and jobs that ask for them fail.
+    GPUs *gpu.Allocator
+}
+
+// StatusReader reads a job's stored status.
+type StatusReader interface {
+    JobStatus(id string) (JobStatus, error)
 }
 
 func NewJobQueue(size int) *JobQueue {
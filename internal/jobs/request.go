@@ -0,0 +1,196 @@
+package jobs
+
+import (
+    "fmt"
+    "gpu-runner/internal/executer"
+    "gpu-runner/internal/gpu"
+    "path/filepath"
+    "slices"
+    "strings"
+    "time"
+)
+
+// JobRequest describes a job to create. It is the body of POST /jobs and
+// the template a schedule creates its jobs from.
+type JobRequest struct {
+    Command        string            `json:"command,omitempty"`
+    Argv           []string          `json:"argv,omitempty"`
+    Shell          string            `json:"shell,omitempty"`
+    Runtime        string            `json:"runtime,omitempty"`
+    // Image selects the container runtime when Runtime is empty.
+    Image          string            `json:"image,omitempty"`
+    Sandbox        bool              `json:"sandbox,omitempty"`
+    NoNetwork      bool              `json:"no_network,omitempty"`
+    Workdir        string            `json:"workdir,omitempty"`
+    Storage        JobStorage        `json:"storage"`
+    MaxRetries     *int              `json:"max_retries,omitempty"`
+    RetryPolicy    *RetryPolicy      `json:"retry_policy,omitempty"`
+    Priority       int               `json:"priority,omitempty"`
+    RunAt          *time.Time        `json:"run_at,omitempty"`
+    Labels         map[string]string `json:"labels,omitempty"`
+    TimeoutSeconds int64             `json:"timeout_seconds,omitempty"`
+    Deadline       *time.Time        `json:"deadline,omitempty"`
+    DependsOn      []Dependency      `json:"depends_on,omitempty"`
+    Array          *ArraySpec        `json:"array,omitempty"`
+    Env            map[string]string `json:"env,omitempty"`
+    Inputs         map[string]string `json:"inputs,omitempty"`
+    Outputs        map[string]string `json:"outputs,omitempty"`
+    Secrets        map[string]string `json:"secrets,omitempty"`
+    CPU            float64           `json:"cpu,omitempty"`
+    MemoryBytes    int64             `json:"memory,omitempty"`
+    MaxPids        int               `json:"max_pids,omitempty"`
+    GPUs           *gpu.Request      `json:"gpus,omitempty"`
+}
+
+// NewJob validates the request and builds the job it describes, ready to
+// be stored. Storage is rounded up to a whole megabyte, and a run_at
+// that has already passed is dropped so the job is queued at once. Jobs
+// with dependencies start out blocked. For an array request the result is
+// the array's parent; see ArrayChildren.
+func (r JobRequest) NewJob(now time.Time) (*Job, error) {
+    storage := r.Storage
+    switch {
+    case storage < 0:
+        return nil, fmt.Errorf("storage must not be negative")
+    case storage == 0:
+        storage = DefaultStorage
+    case storage > MaxStorage:
+        return nil, fmt.Errorf("storage requirement exceeds maximum capacity of %s", FormatSize(MaxStorage))
+    }
+    storage = (storage + storageUnit - 1) / storageUnit * storageUnit
+
+    retryPolicy, err := ResolveRetryPolicy(r.RetryPolicy, r.MaxRetries)
+    if err != nil {
+        return nil, err
+    }
+    if err := ValidatePriority(r.Priority); err != nil {
+        return nil, err
+    }
+    timeout, err := ResolveTimeout(r.TimeoutSeconds, r.Deadline, now)
+    if err != nil {
+        return nil, err
+    }
+
+    command, err := r.validateCommand()
+    if err != nil {
+        return nil, err
+    }
+    if err := validateEnv(r.Env, r.Inputs, r.Outputs); err != nil {
+        return nil, err
+    }
+    if err := validateSecretRefs(r.Secrets); err != nil {
+        return nil, err
+    }
+    if err := validateLimits(r.CPU, r.MemoryBytes, r.MaxPids); err != nil {
+        return nil, err
+    }
+    var gpus *gpu.Request
+    if r.GPUs != nil {
+        if err := r.GPUs.Validate(); err != nil {
+            return nil, err
+        }
+        req := *r.GPUs
+        gpus = &req
+    }
+    if err := ValidateRuntime(r.Runtime); err != nil {
+        return nil, err
+    }
+    if err := ValidateImage(r.Image); err != nil {
+        return nil, err
+    }
+    runtime := r.Runtime
+    if runtime == "" && r.Image != "" {
+        runtime = executer.ContainerRuntime
+    }
+    if runtime == executer.ContainerRuntime && r.Image == "" {
+        return nil, fmt.Errorf("the %s runtime needs an image", runtime)
+    }
+    if (r.Sandbox || r.NoNetwork) && executer.Sandboxing == executer.SandboxDisabled {
+        return nil, fmt.Errorf("sandboxed jobs are disabled on this server")
+    }
+
+    deps := slices.Clone(r.DependsOn)
+    if err := validateDependencies(deps); err != nil {
+        return nil, err
+    }
+
+    if r.Array != nil {
+        if len(deps) > 0 {
+            return nil, fmt.Errorf("array jobs cannot set depends_on")
+        }
+        if err := r.Array.validate(); err != nil {
+            return nil, err
+        }
+    }
+
+    status, runAt := StatusPending, r.RunAt
+    if runAt != nil && runAt.After(now) {
+        if r.Deadline != nil && !r.Deadline.After(*runAt) {
+            return nil, fmt.Errorf("deadline must be after run_at")
+        }
+        status = StatusScheduled
+    } else {
+        runAt = nil
+    }
+    if len(deps) > 0 {
+        status = StatusBlocked
+    }
+
+    return &Job{
+        Command:        command,
+        Argv:           slices.Clone(r.Argv),
+        Shell:          r.Shell,
+        Runtime:        runtime,
+        Image:          r.Image,
+        Sandbox:        r.Sandbox || r.NoNetwork,
+        NoNetwork:      r.NoNetwork,
+        Workdir:        r.Workdir,
+        StorageBytes:   storage,
+        Status:         status,
+        CreatedAt:      now,
+        MaxRetries:     retryPolicy.Retries(),
+        RetryPolicy:    retryPolicy,
+        JobTrial:       1,
+        Labels:         r.Labels,
+        Priority:       r.Priority,
+        RunAt:          runAt,
+        TimeoutSeconds: timeout,
+        Deadline:       r.Deadline,
+        DependsOn:      deps,
+        Array:          r.Array,
+        Env:            r.Env,
+        Inputs:         r.Inputs,
+        Outputs:        r.Outputs,
+        Secrets:        r.Secrets,
+        CPU:            r.CPU,
+        MemoryBytes:    r.MemoryBytes,
+        MaxPids:        r.MaxPids,
+        GPUs:           gpus,
+    }, nil
+}
+
+// validateCommand checks that the request runs either a shell string or an
+// argv, and returns the job's Command.
+func (r JobRequest) validateCommand() (string, error) {
+    hasScript, hasArgv := strings.TrimSpace(r.Command) != "", len(r.Argv) > 0
+    switch {
+    case hasScript && hasArgv:
+        return "", fmt.Errorf("command and argv are mutually exclusive")
+    case !hasScript && !hasArgv:
+        return "", fmt.Errorf("command or argv is required")
+    case hasArgv && r.Argv[0] == "":
+        return "", fmt.Errorf("argv[0] must name a program")
+    case hasArgv && r.Shell != "":
+        return "", fmt.Errorf("shell only applies to command, not argv")
+    }
+    if r.Shell != "" && strings.ContainsAny(r.Shell, " \t\n") {
+        return "", fmt.Errorf("shell %q must be a program name or path", r.Shell)
+    }
+    if r.Workdir != "" && !filepath.IsAbs(r.Workdir) && !filepath.IsLocal(r.Workdir) {
+        return "", fmt.Errorf("workdir %q must stay inside the job's volume or be absolute", r.Workdir)
+    }
+    if hasArgv {
+        return ShellJoin(r.Argv), nil
+    }
+    return r.Command, nil
+}
@@ -0,0 +1,185 @@
+package jobs
+
+import (
+    "fmt"
+    "math"
+    "math/rand/v2"
+    "slices"
+    "time"
+)
+
+// FailureReason classifies why an attempt did not succeed. Retry policies
+// select on it.
+type FailureReason string
+
+const (
+    ReasonExitCode  FailureReason = "exit_code"
+    ReasonSignal    FailureReason = "signal"
+    ReasonTimeout   FailureReason = "timeout"
+    ReasonOOM       FailureReason = "oom_killed"
+    ReasonCancelled FailureReason = "cancelled"
+    ReasonPreempted FailureReason = "preempted"
+    ReasonError     FailureReason = "error"
+    // ReasonDiskQuota is a job that wrote more than its storage allows.
+    ReasonDiskQuota FailureReason = "disk_quota"
+)
+
+var knownReasons = map[FailureReason]bool{
+    ReasonExitCode:  true,
+    ReasonSignal:    true,
+    ReasonTimeout:   true,
+    ReasonOOM:       true,
+    ReasonCancelled: true,
+    ReasonPreempted: true,
+    ReasonError:     true,
+    ReasonDiskQuota: true,
+}
+
+const (
+    DefaultMaxRetries     = 3
+    MaxRetriesLimit       = 100
+    DefaultInitialBackoff = 5 * time.Second
+    DefaultMaxBackoff     = 10 * time.Minute
+)
+
+// DefaultRetryOn is used when a policy doesn't list reasons. Timeouts, OOM
+// kills and full disks usually repeat, so they are only retried when asked
+// for.
+var DefaultRetryOn = []FailureReason{ReasonExitCode, ReasonSignal, ReasonError, ReasonPreempted}
+
+// RetryPolicy decides whether and when a failed attempt runs again.
+// MaxRetries counts retries after the first attempt; an explicit 0 disables
+// them. Backoff doubles from InitialBackoffSeconds up to MaxBackoffSeconds,
+// randomised by up to Jitter (a fraction) either way.
+type RetryPolicy struct {
+    MaxRetries            *int            `json:"max_retries,omitempty"`
+    InitialBackoffSeconds float64         `json:"initial_backoff_seconds,omitempty"`
+    MaxBackoffSeconds     float64         `json:"max_backoff_seconds,omitempty"`
+    Jitter                float64         `json:"jitter,omitempty"`
+    RetryOn               []FailureReason `json:"retry_on,omitempty"`
+    NoRetryOn             []FailureReason `json:"no_retry_on,omitempty"`
+    RetryOnExitCodes      []int           `json:"retry_on_exit_codes,omitempty"`
+    NoRetryOnExitCodes    []int           `json:"no_retry_on_exit_codes,omitempty"`
+}
+
+// ResolveRetryPolicy validates a submitted policy and fills in defaults.
+// maxRetries is the legacy top-level max_retries field; the policy's own
+// value wins when both are given.
+func ResolveRetryPolicy(p *RetryPolicy, maxRetries *int) (*RetryPolicy, error) {
+    resolved := RetryPolicy{}
+    if p != nil {
+        resolved = *p
+    }
+
+    retries := DefaultMaxRetries
+    switch {
+    case resolved.MaxRetries != nil:
+        retries = *resolved.MaxRetries
+    case maxRetries != nil:
+        retries = *maxRetries
+    }
+    if retries < 0 || retries > MaxRetriesLimit {
+        return nil, fmt.Errorf("max_retries must be between 0 and %d", MaxRetriesLimit)
+    }
+    resolved.MaxRetries = &retries
+
+    if resolved.InitialBackoffSeconds < 0 || resolved.MaxBackoffSeconds < 0 {
+        return nil, fmt.Errorf("backoff must not be negative")
+    }
+    if resolved.InitialBackoffSeconds == 0 {
+        resolved.InitialBackoffSeconds = DefaultInitialBackoff.Seconds()
+    }
+    if resolved.MaxBackoffSeconds == 0 {
+        resolved.MaxBackoffSeconds = math.Max(DefaultMaxBackoff.Seconds(), resolved.InitialBackoffSeconds)
+    }
+    if resolved.MaxBackoffSeconds < resolved.InitialBackoffSeconds {
+        return nil, fmt.Errorf("max_backoff_seconds must be at least initial_backoff_seconds")
+    }
+    if resolved.Jitter < 0 || resolved.Jitter > 1 {
+        return nil, fmt.Errorf("jitter must be between 0 and 1")
+    }
+    for _, r := range append(slices.Clone(resolved.RetryOn), resolved.NoRetryOn...) {
+        if !knownReasons[r] {
+            return nil, fmt.Errorf("unknown failure reason %q", r)
+        }
+    }
+    return &resolved, nil
+}
+
+// Retries returns how many retries the policy allows.
+func (p *RetryPolicy) Retries() int {
+    if p == nil || p.MaxRetries == nil {
+        return DefaultMaxRetries
+    }
+    return *p.MaxRetries
+}
+
+// Retryable reports whether a failure with this reason and exit code may be
+// retried. Cancellation by a user is never retried.
+func (p *RetryPolicy) Retryable(reason FailureReason, exitCode *int) bool {
+    if reason == ReasonCancelled {
+        return false
+    }
+    retryOn := DefaultRetryOn
+    if p != nil && len(p.RetryOn) > 0 {
+        retryOn = p.RetryOn
+    }
+    if p != nil && slices.Contains(p.NoRetryOn, reason) {
+        return false
+    }
+    if !slices.Contains(retryOn, reason) {
+        return false
+    }
+    if reason == ReasonExitCode && exitCode != nil && p != nil {
+        if slices.Contains(p.NoRetryOnExitCodes, *exitCode) {
+            return false
+        }
+        if len(p.RetryOnExitCodes) > 0 && !slices.Contains(p.RetryOnExitCodes, *exitCode) {
+            return false
+        }
+    }
+    return true
+}
+
+// RetryOutcome is what a failed attempt leads to under the job's policy.
+type RetryOutcome int
+
+const (
+    // NoRetry leaves the job failed: the failure isn't retryable, or the
+    // job asked for no retries.
+    NoRetry RetryOutcome = iota
+    // Retry runs the job again for its next trial.
+    Retry
+    // RetriesExhausted ends a job that used up the retries it asked for;
+    // it is dead.
+    RetriesExhausted
+)
+
+// NextAttempt decides what the job's failed attempt, described by its
+// FailureReason and ExitCode, leads to.
+func (j *Job) NextAttempt() RetryOutcome {
+    if j.MaxRetries == 0 || !j.RetryPolicy.Retryable(j.FailureReason, j.ExitCode) {
+        return NoRetry
+    }
+    if j.JobTrial > j.MaxRetries {
+        return RetriesExhausted
+    }
+    return Retry
+}
+
+// Backoff returns the delay before retry number retry (1 for the first
+// retry).
+func (p *RetryPolicy) Backoff(retry int) time.Duration {
+    initial, max, jitter := DefaultInitialBackoff.Seconds(), DefaultMaxBackoff.Seconds(), 0.0
+    if p != nil {
+        initial, max, jitter = p.InitialBackoffSeconds, p.MaxBackoffSeconds, p.Jitter
+    }
+    if retry < 1 {
+        retry = 1
+    }
+    delay := math.Min(initial*math.Pow(2, float64(retry-1)), max)
+    if jitter > 0 {
+        delay *= 1 + jitter*(2*rand.Float64()-1)
+    }
+    return time.Duration(delay * float64(time.Second))
+}
@@ -0,0 +1,134 @@
+package jobs
+
+import (
+    "testing"
+    "time"
+)
+
+func intPtr(n int) *int { return &n }
+
+func TestResolveRetryPolicyDefaults(t *testing.T) {
+    p, err := ResolveRetryPolicy(nil, nil)
+    if err != nil {
+        t.Fatalf("ResolveRetryPolicy: %v", err)
+    }
+    if p.Retries() != DefaultMaxRetries {
+        t.Errorf("retries = %d, want %d", p.Retries(), DefaultMaxRetries)
+    }
+    if p.InitialBackoffSeconds != DefaultInitialBackoff.Seconds() || p.MaxBackoffSeconds != DefaultMaxBackoff.Seconds() {
+        t.Errorf("backoff = %v..%v, want the defaults", p.InitialBackoffSeconds, p.MaxBackoffSeconds)
+    }
+}
+
+func TestResolveRetryPolicyMaxRetries(t *testing.T) {
+    for _, tc := range []struct {
+        name   string
+        policy *RetryPolicy
+        legacy *int
+        want   int
+    }{
+        {"explicit zero", &RetryPolicy{MaxRetries: intPtr(0)}, nil, 0},
+        {"legacy field", nil, intPtr(5), 5},
+        {"legacy zero", nil, intPtr(0), 0},
+        {"policy wins", &RetryPolicy{MaxRetries: intPtr(2)}, intPtr(7), 2},
+    } {
+        p, err := ResolveRetryPolicy(tc.policy, tc.legacy)
+        if err != nil {
+            t.Fatalf("%s: %v", tc.name, err)
+        }
+        if p.Retries() != tc.want {
+            t.Errorf("%s: retries = %d, want %d", tc.name, p.Retries(), tc.want)
+        }
+    }
+}
+
+func TestResolveRetryPolicyRejects(t *testing.T) {
+    for name, p := range map[string]*RetryPolicy{
+        "negative retries":  {MaxRetries: intPtr(-1)},
+        "too many retries":  {MaxRetries: intPtr(MaxRetriesLimit + 1)},
+        "negative backoff":  {InitialBackoffSeconds: -1},
+        "max below initial": {InitialBackoffSeconds: 60, MaxBackoffSeconds: 10},
+        "jitter above one":  {Jitter: 1.5},
+        "unknown reason":    {RetryOn: []FailureReason{"flaky"}},
+        "old oom name":      {NoRetryOn: []FailureReason{"oom"}},
+    } {
+        if _, err := ResolveRetryPolicy(p, nil); err == nil {
+            t.Errorf("%s: ResolveRetryPolicy accepted %+v", name, p)
+        }
+    }
+}
+
+func TestRetryable(t *testing.T) {
+    three, seven := 3, 7
+    for _, tc := range []struct {
+        name     string
+        policy   *RetryPolicy
+        reason   FailureReason
+        exitCode *int
+        want     bool
+    }{
+        {"default exit code", nil, ReasonExitCode, &three, true},
+        {"default preempted", nil, ReasonPreempted, nil, true},
+        {"default timeout", nil, ReasonTimeout, nil, false},
+        {"default oom", nil, ReasonOOM, nil, false},
+        {"cancel never", &RetryPolicy{RetryOn: []FailureReason{ReasonCancelled}}, ReasonCancelled, nil, false},
+        {"retry_on", &RetryPolicy{RetryOn: []FailureReason{ReasonTimeout}}, ReasonTimeout, nil, true},
+        {"retry_on excludes others", &RetryPolicy{RetryOn: []FailureReason{ReasonTimeout}}, ReasonExitCode, &three, false},
+        {"no_retry_on", &RetryPolicy{NoRetryOn: []FailureReason{ReasonSignal}}, ReasonSignal, nil, false},
+        {"retry_on_exit_codes match", &RetryPolicy{RetryOnExitCodes: []int{3}}, ReasonExitCode, &three, true},
+        {"retry_on_exit_codes miss", &RetryPolicy{RetryOnExitCodes: []int{3}}, ReasonExitCode, &seven, false},
+        {"no_retry_on_exit_codes", &RetryPolicy{NoRetryOnExitCodes: []int{7}}, ReasonExitCode, &seven, false},
+    } {
+        if got := tc.policy.Retryable(tc.reason, tc.exitCode); got != tc.want {
+            t.Errorf("%s: Retryable(%s) = %v, want %v", tc.name, tc.reason, got, tc.want)
+        }
+    }
+}
+
+func TestBackoffDoublesUpToMax(t *testing.T) {
+    p := &RetryPolicy{InitialBackoffSeconds: 2, MaxBackoffSeconds: 10}
+    for retry, want := range map[int]time.Duration{
+        0: 2 * time.Second,
+        1: 2 * time.Second,
+        2: 4 * time.Second,
+        3: 8 * time.Second,
+        4: 10 * time.Second,
+        9: 10 * time.Second,
+    } {
+        if got := p.Backoff(retry); got != want {
+            t.Errorf("Backoff(%d) = %v, want %v", retry, got, want)
+        }
+    }
+    var none *RetryPolicy
+    if got := none.Backoff(1); got != DefaultInitialBackoff {
+        t.Errorf("nil policy Backoff(1) = %v, want %v", got, DefaultInitialBackoff)
+    }
+}
+
+func TestBackoffJitterStaysInRange(t *testing.T) {
+    p := &RetryPolicy{InitialBackoffSeconds: 10, MaxBackoffSeconds: 10, Jitter: 0.5}
+    for range 100 {
+        if got := p.Backoff(1); got < 5*time.Second || got > 15*time.Second {
+            t.Fatalf("Backoff(1) = %v, want within 50%% of 10s", got)
+        }
+    }
+}
+
+func TestNextAttempt(t *testing.T) {
+    one := 1
+    for _, tc := range []struct {
+        name string
+        job  Job
+        want RetryOutcome
+    }{
+        {"retry left", Job{MaxRetries: 2, JobTrial: 2, FailureReason: ReasonExitCode, ExitCode: &one}, Retry},
+        {"retries used up", Job{MaxRetries: 2, JobTrial: 3, FailureReason: ReasonExitCode, ExitCode: &one}, RetriesExhausted},
+        {"no retries asked for", Job{MaxRetries: 0, JobTrial: 1, FailureReason: ReasonExitCode, ExitCode: &one}, NoRetry},
+        {"not retryable", Job{MaxRetries: 2, JobTrial: 1, FailureReason: ReasonTimeout}, NoRetry},
+        {"cancelled", Job{MaxRetries: 2, JobTrial: 1, FailureReason: ReasonCancelled}, NoRetry},
+    } {
+        if got := tc.job.NextAttempt(); got != tc.want {
+            t.Errorf("%s: NextAttempt() = %v, want %v", tc.name, got, tc.want)
+        }
+    }
+}
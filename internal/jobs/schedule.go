@@ -0,0 +1,80 @@
+package jobs
+
+import (
+    "fmt"
+    "time"
+
+    "github.com/robfig/cron/v3"
+)
+
+// OverlapPolicy decides what a schedule does when it fires while the job
+// it created last time is still waiting or running.
+type OverlapPolicy string
+
+const (
+    // OverlapSkip lets the previous job finish and skips this run.
+    OverlapSkip OverlapPolicy = "skip"
+    // OverlapQueue creates the job anyway, blocked until the previous one
+    // has finished however it ended, so runs never overlap.
+    OverlapQueue OverlapPolicy = "queue"
+    // OverlapCancelPrevious cancels the previous job and starts a new one.
+    OverlapCancelPrevious OverlapPolicy = "cancel-previous"
+)
+
+// Schedule creates a job from Job each time its cron expression fires.
+type Schedule struct {
+    ID        string        `json:"id"`
+    Name      string        `json:"name,omitempty"`
+    // Cron is a standard five-field expression or a descriptor such as
+    // @hourly, evaluated in Timezone.
+    Cron      string        `json:"cron"`
+    Timezone  string        `json:"timezone"`
+    Overlap   OverlapPolicy `json:"overlap"`
+    Job       JobRequest    `json:"job"`
+    CreatedAt time.Time     `json:"created_at"`
+    NextRunAt time.Time     `json:"next_run_at"`
+    LastRunAt *time.Time    `json:"last_run_at,omitempty"`
+    LastJobID string        `json:"last_job_id,omitempty"`
+}
+
+// Validate fills in the default timezone and overlap policy and checks
+// the rest of the schedule. The job template is checked when it is used.
+func (s *Schedule) Validate() error {
+    if s.Timezone == "" {
+        s.Timezone = "UTC"
+    }
+    if s.Overlap == "" {
+        s.Overlap = OverlapSkip
+    }
+    switch s.Overlap {
+    case OverlapSkip, OverlapQueue, OverlapCancelPrevious:
+    default:
+        return fmt.Errorf("unknown overlap policy %q", s.Overlap)
+    }
+    if s.Job.RunAt != nil || s.Job.Deadline != nil || len(s.Job.DependsOn) > 0 {
+        return fmt.Errorf("schedule job templates cannot set run_at, deadline or depends_on")
+    }
+    if s.Overlap == OverlapQueue && s.Job.Array != nil {
+        // Queued runs wait through depends_on, which arrays can't use.
+        return fmt.Errorf("array job templates cannot use the %s overlap policy", OverlapQueue)
+    }
+    _, err := s.Next(time.Now())
+    return err
+}
+
+// Next returns the first time after after that the schedule fires.
+func (s *Schedule) Next(after time.Time) (time.Time, error) {
+    loc, err := time.LoadLocation(s.Timezone)
+    if err != nil {
+        return time.Time{}, fmt.Errorf("unknown timezone %q", s.Timezone)
+    }
+    spec, err := cron.ParseStandard(s.Cron)
+    if err != nil {
+        return time.Time{}, fmt.Errorf("invalid cron expression %q: %w", s.Cron, err)
+    }
+    next := spec.Next(after.In(loc))
+    if next.IsZero() {
+        return time.Time{}, fmt.Errorf("cron expression %q never fires", s.Cron)
+    }
+    return next, nil
+}
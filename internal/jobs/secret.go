@@ -0,0 +1,67 @@
+package jobs
+
+import (
+    "errors"
+    "fmt"
+    "maps"
+    "regexp"
+    "slices"
+)
+
+// ErrSecretNotFound is returned by a SecretResolver for an unknown name.
+var ErrSecretNotFound = errors.New("secret not found")
+
+// SecretResolver looks up secret values when a job starts. Jobs only
+// carry secret names, so values never reach the job store or the queue.
+type SecretResolver interface {
+    Secret(name string) (string, error)
+}
+
+var secretName = regexp.MustCompile(`^[A-Za-z0-9][A-Za-z0-9._-]{0,127}$`)
+
+// ValidateSecretName rejects names that can't be used in a URL path.
+func ValidateSecretName(name string) error {
+    if !secretName.MatchString(name) {
+        return fmt.Errorf("invalid secret name %q: use letters, digits, '.', '_' and '-'", name)
+    }
+    return nil
+}
+
+func validateSecretRefs(refs map[string]string) error {
+    for env, name := range refs {
+        if !envName.MatchString(env) {
+            return fmt.Errorf("invalid environment variable name %q", env)
+        }
+        if err := ValidateSecretName(name); err != nil {
+            return err
+        }
+    }
+    return nil
+}
+
+// SecretNames returns the distinct secrets the job refers to.
+func (j *Job) SecretNames() []string {
+    return slices.Compact(slices.Sorted(maps.Values(j.Secrets)))
+}
+
+// ResolveSecrets looks up the job's secrets and returns them as KEY=value
+// pairs along with the values, which callers must keep out of logs.
+func (j *Job) ResolveSecrets(r SecretResolver) ([]string, []string, error) {
+    if len(j.Secrets) == 0 {
+        return nil, nil, nil
+    }
+    if r == nil {
+        return nil, nil, fmt.Errorf("job uses secrets but no secret store is configured")
+    }
+    env := make([]string, 0, len(j.Secrets))
+    values := make([]string, 0, len(j.Secrets))
+    for _, k := range slices.Sorted(maps.Keys(j.Secrets)) {
+        v, err := r.Secret(j.Secrets[k])
+        if err != nil {
+            return nil, nil, fmt.Errorf("secret %q for %s: %w", j.Secrets[k], k, err)
+        }
+        env = append(env, k+"="+v)
+        values = append(values, v)
+    }
+    return env, values, nil
+}
@@ -0,0 +1,50 @@
+package jobs
+
+import (
+    "fmt"
+    "math"
+    "strconv"
+    "strings"
+)
+
+var sizeUnits = []struct {
+    suffix string
+    bytes  int64
+}{
+    {"GIB", 1 << 30}, {"MIB", 1 << 20}, {"KIB", 1 << 10},
+    {"GB", 1 << 30}, {"MB", 1 << 20}, {"KB", 1 << 10},
+    {"G", 1 << 30}, {"M", 1 << 20}, {"K", 1 << 10},
+    {"B", 1},
+}
+
+// ParseSize reads a storage size such as "25MB", "1.5GiB" or a plain
+// number of bytes. Units are binary: 1MB is 1024*1024 bytes.
+func ParseSize(s string) (JobStorage, error) {
+    v := strings.ToUpper(strings.TrimSpace(s))
+    mult := int64(1)
+    for _, u := range sizeUnits {
+        if strings.HasSuffix(v, u.suffix) {
+            v, mult = strings.TrimSpace(strings.TrimSuffix(v, u.suffix)), u.bytes
+            break
+        }
+    }
+    n, err := strconv.ParseFloat(v, 64)
+    if err != nil || n < 0 || math.IsNaN(n) || math.IsInf(n, 0) {
+        return 0, fmt.Errorf("invalid size %q: use bytes or a number with KB, MB or GB", s)
+    }
+    // float64(math.MaxInt64) rounds up to 2^63, which no longer fits.
+    if n >= math.MaxInt64/float64(mult) {
+        return 0, fmt.Errorf("invalid size %q: too large", s)
+    }
+    return JobStorage(n * float64(mult)), nil
+}
+
+// FormatSize prints a size in the largest unit that divides it exactly.
+func FormatSize(size JobStorage) string {
+    for _, u := range sizeUnits[3:6] {
+        if size > 0 && int64(size)%u.bytes == 0 {
+            return fmt.Sprintf("%d%s", int64(size)/u.bytes, u.suffix)
+        }
+    }
+    return fmt.Sprintf("%d", int64(size))
+}
@@ -0,0 +1,244 @@
+package jobs
+
+import (
+    "bytes"
+    "errors"
+    "fmt"
+    "io"
+    "strings"
+    "time"
+
+    "gpu-runner/internal/gpu"
+
+    "gopkg.in/yaml.v3"
+)
+
+// SpecVersion is the job spec format this build reads and writes.
+const SpecVersion = "v1"
+
+// JobSpec is a job described in a file, as YAML or JSON. It is what
+// `gpucli submit -f` reads; Request turns it into the JobRequest that
+// CreateJob validates, so a spec that passes Validate is accepted by the
+// server. Durations are strings such as "90m" and sizes strings such as
+// "25MB".
+type JobSpec struct {
+    Version   string            `json:"version" yaml:"version"`
+    Command   string            `json:"command,omitempty" yaml:"command,omitempty"`
+    // Argv is an alternative to Command: the program and its arguments,
+    // passed through without shell interpretation.
+    Argv      []string          `json:"argv,omitempty" yaml:"argv,omitempty"`
+    Shell     string            `json:"shell,omitempty" yaml:"shell,omitempty"`
+    Workdir   string            `json:"workdir,omitempty" yaml:"workdir,omitempty"`
+    Runtime   string            `json:"runtime,omitempty" yaml:"runtime,omitempty"`
+    Image     string            `json:"image,omitempty" yaml:"image,omitempty"`
+    Sandbox   bool              `json:"sandbox,omitempty" yaml:"sandbox,omitempty"`
+    NoNetwork bool              `json:"no_network,omitempty" yaml:"no_network,omitempty"`
+    Env       map[string]string `json:"env,omitempty" yaml:"env,omitempty"`
+    // Secrets maps environment variable names to names in the server's
+    // secret store.
+    Secrets   map[string]string `json:"secrets,omitempty" yaml:"secrets,omitempty"`
+    Resources SpecResources     `json:"resources,omitempty" yaml:"resources,omitempty"`
+    Timeout   string            `json:"timeout,omitempty" yaml:"timeout,omitempty"`
+    Deadline  *time.Time        `json:"deadline,omitempty" yaml:"deadline,omitempty"`
+    RunAt     *time.Time        `json:"run_at,omitempty" yaml:"run_at,omitempty"`
+    Retries   *SpecRetries      `json:"retries,omitempty" yaml:"retries,omitempty"`
+    Labels    map[string]string `json:"labels,omitempty" yaml:"labels,omitempty"`
+    Priority  int               `json:"priority,omitempty" yaml:"priority,omitempty"`
+    // Inputs and Outputs name the files the job reads and writes; see
+    // Job.Inputs.
+    Inputs    map[string]string `json:"inputs,omitempty" yaml:"inputs,omitempty"`
+    Outputs   map[string]string `json:"outputs,omitempty" yaml:"outputs,omitempty"`
+    Array     *ArraySpec        `json:"array,omitempty" yaml:"array,omitempty"`
+}
+
+// SpecResources is what a job needs from the machine it runs on.
+type SpecResources struct {
+    Storage string  `json:"storage,omitempty" yaml:"storage,omitempty"`
+    // CPU is a number of cores, such as 2 or 0.5.
+    CPU     float64 `json:"cpu,omitempty" yaml:"cpu,omitempty"`
+    Memory  string  `json:"memory,omitempty" yaml:"memory,omitempty"`
+    MaxPids int     `json:"max_pids,omitempty" yaml:"max_pids,omitempty"`
+    GPUs    *SpecGPUs `json:"gpus,omitempty" yaml:"gpus,omitempty"`
+}
+
+// SpecGPUs is a gpu.Request with the memory written as a size, such as
+// "40GB".
+type SpecGPUs struct {
+    Count     int    `json:"count" yaml:"count"`
+    Model     string `json:"model,omitempty" yaml:"model,omitempty"`
+    MinMemory string `json:"min_memory,omitempty" yaml:"min_memory,omitempty"`
+}
+
+// SpecRetries is a RetryPolicy with durations written as strings.
+type SpecRetries struct {
+    Max                *int            `json:"max,omitempty" yaml:"max,omitempty"`
+    Backoff            string          `json:"backoff,omitempty" yaml:"backoff,omitempty"`
+    MaxBackoff         string          `json:"max_backoff,omitempty" yaml:"max_backoff,omitempty"`
+    Jitter             float64         `json:"jitter,omitempty" yaml:"jitter,omitempty"`
+    RetryOn            []FailureReason `json:"retry_on,omitempty" yaml:"retry_on,omitempty"`
+    NoRetryOn          []FailureReason `json:"no_retry_on,omitempty" yaml:"no_retry_on,omitempty"`
+    RetryOnExitCodes   []int           `json:"retry_on_exit_codes,omitempty" yaml:"retry_on_exit_codes,omitempty"`
+    NoRetryOnExitCodes []int           `json:"no_retry_on_exit_codes,omitempty" yaml:"no_retry_on_exit_codes,omitempty"`
+}
+
+// ParseSpec reads a YAML or JSON job spec. Unknown fields are errors so
+// that typos don't silently fall back to defaults.
+func ParseSpec(data []byte) (*JobSpec, error) {
+    dec := yaml.NewDecoder(bytes.NewReader(data))
+    dec.KnownFields(true)
+    var spec JobSpec
+    if err := dec.Decode(&spec); err != nil {
+        if errors.Is(err, io.EOF) {
+            return nil, fmt.Errorf("job spec is empty")
+        }
+        return nil, fmt.Errorf("invalid job spec: %w", err)
+    }
+    switch spec.Version {
+    case SpecVersion:
+    case "":
+        return nil, fmt.Errorf("job spec has no version; set version: %s", SpecVersion)
+    default:
+        return nil, fmt.Errorf("unsupported job spec version %q; this build reads %s", spec.Version, SpecVersion)
+    }
+    return &spec, nil
+}
+
+// Request converts the spec into a job request. It checks the spec's own
+// syntax; the request itself is validated by JobRequest.NewJob.
+func (s *JobSpec) Request() (JobRequest, error) {
+    req := JobRequest{
+        Command:   s.Command,
+        Argv:      s.Argv,
+        Shell:     s.Shell,
+        Workdir:   s.Workdir,
+        Runtime:   s.Runtime,
+        Image:     s.Image,
+        Sandbox:   s.Sandbox,
+        NoNetwork: s.NoNetwork,
+        Env:       s.Env,
+        Secrets:   s.Secrets,
+        Labels:    s.Labels,
+        Priority:  s.Priority,
+        RunAt:     s.RunAt,
+        Deadline:  s.Deadline,
+        Inputs:    s.Inputs,
+        Outputs:   s.Outputs,
+        Array:     s.Array,
+    }
+    if s.Resources.Storage != "" {
+        storage, err := ParseSize(s.Resources.Storage)
+        if err != nil {
+            return JobRequest{}, fmt.Errorf("resources.storage: %w", err)
+        }
+        req.Storage = storage
+    }
+    if s.Resources.Memory != "" {
+        memory, err := ParseSize(s.Resources.Memory)
+        if err != nil {
+            return JobRequest{}, fmt.Errorf("resources.memory: %w", err)
+        }
+        req.MemoryBytes = int64(memory)
+    }
+    if g := s.Resources.GPUs; g != nil {
+        req.GPUs = &gpu.Request{Count: g.Count, Model: g.Model}
+        if g.MinMemory != "" {
+            memory, err := ParseSize(g.MinMemory)
+            if err != nil {
+                return JobRequest{}, fmt.Errorf("resources.gpus.min_memory: %w", err)
+            }
+            req.GPUs.MinMemoryBytes = int64(memory)
+        }
+    }
+    req.CPU = s.Resources.CPU
+    req.MaxPids = s.Resources.MaxPids
+    if s.Timeout != "" {
+        d, err := time.ParseDuration(s.Timeout)
+        if err != nil || d < time.Second {
+            return JobRequest{}, fmt.Errorf("timeout %q must be a duration of at least 1s, such as 90m", s.Timeout)
+        }
+        req.TimeoutSeconds = int64(d / time.Second)
+    }
+    if r := s.Retries; r != nil {
+        policy := &RetryPolicy{
+            MaxRetries:         r.Max,
+            Jitter:             r.Jitter,
+            RetryOn:            r.RetryOn,
+            NoRetryOn:          r.NoRetryOn,
+            RetryOnExitCodes:   r.RetryOnExitCodes,
+            NoRetryOnExitCodes: r.NoRetryOnExitCodes,
+        }
+        for field, v := range map[string]string{"retries.backoff": r.Backoff, "retries.max_backoff": r.MaxBackoff} {
+            if v == "" {
+                continue
+            }
+            d, err := time.ParseDuration(v)
+            if err != nil {
+                return JobRequest{}, fmt.Errorf("%s %q is not a duration", field, v)
+            }
+            if field == "retries.backoff" {
+                policy.InitialBackoffSeconds = d.Seconds()
+            } else {
+                policy.MaxBackoffSeconds = d.Seconds()
+            }
+        }
+        req.RetryPolicy = policy
+    }
+    return req, nil
+}
+
+// Validate checks the spec exactly as the server would on submission.
+func (s *JobSpec) Validate(now time.Time) (*Job, error) {
+    req, err := s.Request()
+    if err != nil {
+        return nil, err
+    }
+    return req.NewJob(now)
+}
+
+// Resolve returns the spec the server would run: storage rounded up to a
+// whole megabyte, and the default storage, timeout and retry policy filled
+// in.
+func (s *JobSpec) Resolve(now time.Time) (*JobSpec, error) {
+    job, err := s.Validate(now)
+    if err != nil {
+        return nil, err
+    }
+    resolved := *s
+    resolved.Version = SpecVersion
+    resolved.Sandbox = job.Sandbox
+    resolved.Resources.Storage = FormatSize(job.StorageBytes)
+    resolved.Timeout = (time.Duration(job.TimeoutSeconds) * time.Second).String()
+    p := job.RetryPolicy
+    resolved.Retries = &SpecRetries{
+        Max:                p.MaxRetries,
+        Backoff:            secondsDuration(p.InitialBackoffSeconds),
+        MaxBackoff:         secondsDuration(p.MaxBackoffSeconds),
+        Jitter:             p.Jitter,
+        RetryOn:            p.RetryOn,
+        NoRetryOn:          p.NoRetryOn,
+        RetryOnExitCodes:   p.RetryOnExitCodes,
+        NoRetryOnExitCodes: p.NoRetryOnExitCodes,
+    }
+    if len(resolved.Retries.RetryOn) == 0 {
+        resolved.Retries.RetryOn = DefaultRetryOn
+    }
+    return &resolved, nil
+}
+
+func secondsDuration(s float64) string {
+    return time.Duration(s * float64(time.Second)).String()
+}
+
+// ShellJoin quotes args for bash so that running the result executes the
+// same argv.
+func ShellJoin(args []string) string {
+    quoted := make([]string, len(args))
+    for i, a := range args {
+        if a != "" && strings.Trim(a, "abcdefghijklmnopqrstuvwxyzABCDEFGHIJKLMNOPQRSTUVWXYZ0123456789_-./=:,+@%") == "" {
+            quoted[i] = a
+            continue
+        }
+        quoted[i] = "'" + strings.ReplaceAll(a, "'", `'\''`) + "'"
+    }
+    return strings.Join(quoted, " ")
+}
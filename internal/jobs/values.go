@@ -1,23 +1,73 @@
 package jobs
 
+import "time"
 
-const (
-    Volume10MB JobStorage = 10 * 1024 * 1024
-    Volume25MB JobStorage = 25 * 1024 * 1024
-    Volume50MB JobStorage = 50 * 1024 * 1024
+// Workspace quotas. Jobs that don't ask for storage get DefaultStorage;
+// requests above MaxStorage are rejected.
+var (
+    DefaultStorage JobStorage = 1 << 30
+    MaxStorage     JobStorage = 100 << 30
 )
 
-var VolumePaths = map[JobStorage] string{
-	Volume10MB:"/var/lib/jobrunner/volumes/10mb",
-	Volume25MB:"/var/lib/jobrunner/volumes/25mb",
-	Volume50MB:"/var/lib/jobrunner/volumes/50mb",
-}
+// storageUnit is what storage requests are rounded up to.
+const storageUnit JobStorage = 1 << 20
 
 
 const (
     StatusPending   JobStatus = "pending"
+    // StatusScheduled is a job held until its RunAt time.
+    StatusScheduled JobStatus = "scheduled"
+    // StatusBlocked is a job waiting for the jobs it depends on to finish.
+    StatusBlocked   JobStatus = "blocked"
     StatusRunning   JobStatus = "running"
     StatusSuccess   JobStatus = "success"
     StatusFailed    JobStatus = "failed"
     StatusCancelled JobStatus = "cancelled"
+    StatusTimedOut  JobStatus = "timed_out"
+    // StatusDead marks a job that failed and used up its retries. Its last
+    // payload is kept in the dead-letter queue until retried or purged.
+    StatusDead      JobStatus = "dead"
+    // StatusLost is only recorded on attempts: the worker stopped sending
+    // heartbeats and the job was requeued.
+    StatusLost      JobStatus = "lost"
+)
+
+// Job priority bounds. Jobs default to 0; negative priorities run after
+// the default.
+const (
+    MinPriority = -1000
+    MaxPriority = 1000
+)
+
+// Server-side execution time limits. DefaultTimeout applies to jobs that
+// don't ask for one; requests above MaxTimeout are rejected.
+var (
+    DefaultTimeout = 1 * time.Hour
+    MaxTimeout     = 7 * 24 * time.Hour
 )
+
+var knownStatuses = map[JobStatus]bool{
+    StatusPending:   true,
+    StatusScheduled: true,
+    StatusBlocked:   true,
+    StatusRunning:   true,
+    StatusSuccess:   true,
+    StatusFailed:    true,
+    StatusCancelled: true,
+    StatusTimedOut:  true,
+    StatusDead:      true,
+}
+
+// Valid reports whether s is a status the server can assign.
+func (s JobStatus) Valid() bool {
+    return knownStatuses[s]
+}
+
+// IsTerminal reports whether a job in status s will not run again.
+func (s JobStatus) IsTerminal() bool {
+    switch s {
+    case StatusSuccess, StatusFailed, StatusCancelled, StatusTimedOut, StatusDead:
+        return true
+    }
+    return false
+}
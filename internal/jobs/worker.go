@@ -2,7 +2,11 @@ package jobs
 
 import (
 	"context"
+	"errors"
+	"fmt"
+	"gpu-runner/internal/executer"
 	"gpu-runner/internal/logger"
+	"os"
 	"time"
 )
 
@@ -10,19 +14,34 @@ var workerLogger = logger.Server
 
 type Worker struct {
     ID       int
+    // Name identifies the worker across server instances and is recorded
+    // on the jobs it runs.
+    Name     string
     JobQueue *JobQueue
     Results chan *Job
 }
 
 func NewWorker(id int, jq *JobQueue, results chan *Job) *Worker {
     workerLogger.Info("Creating new worker", "worker_id", id)
+    host, err := os.Hostname()
+    if err != nil {
+        host = "unknown"
+    }
     return &Worker{
         ID:       id,
+        Name:     fmt.Sprintf("%s-%d", host, id),
         JobQueue: jq,
         Results: results,
     }
 }
 
+// report sends a copy of job to the results channel so the receiver can
+// persist this transition while the worker keeps mutating the original.
+func (w *Worker) report(job *Job) {
+    snapshot := *job
+    w.Results <- &snapshot
+}
+
 func (w *Worker) Start(ctx context.Context) {
     workerLogger.Info("Starting worker", "worker_id", w.ID)
     go func() {
@@ -32,23 +51,149 @@ func (w *Worker) Start(ctx context.Context) {
                 workerLogger.Info("Worker shutting down", "worker_id", w.ID)
                 return
             case job := <-w.JobQueue.Queue:
+                if w.cancelled(job) {
+                    workerLogger.Info("Dropping job cancelled before it started", "worker_id", w.ID, "job_id", job.ID)
+                    w.dropCancelled(job)
+                    continue
+                }
+                if !w.leaseHeld(ctx, job) {
+                    // The reaper took the job back and has settled it.
+                    workerLogger.Warn("Dropping job whose lease was lost before it started", "worker_id", w.ID, "job_id", job.ID)
+                    w.JobQueue.ReleaseGPUs(job)
+                    continue
+                }
+                startedAt := time.Now()
                 job.Status = StatusRunning
+                job.StartedAt = &startedAt
+                job.FinishedAt = nil
+                job.ExitCode = nil
+                job.Signal = ""
+                job.TerminationSignal = ""
+                job.Error = ""
+                job.FailureReason = ""
+                job.PeakMemoryBytes = 0
+                job.CPUSeconds = 0
+                job.WorkerID = w.Name
+                ws, wsErr := w.JobQueue.prepareWorkspace(job)
+                if wsErr == nil {
+                    job.VolumePath = ws.Path
+                }
+                w.report(job)
                 workerLogger.Info("Worker received job from queue", "worker_id", w.ID, "job_id", job.ID, "status", job.Status)
                 job.Logger.Info("Job Running", logger.Item("Job Status", job.Status) , logger.Item("worker", w.ID),  logger.Item("command", job.Command))
-                volumePath := VolumePaths[job.StorageBytes]
-                jobCtx, cancel := context.WithTimeout(ctx, 30*time.Second)
-                workerLogger.Info("Setting up job execution context", "worker_id", w.ID, "job_id", job.ID, "volume_path", volumePath)
+                if wsErr != nil {
+                    workerLogger.Error("Failed to prepare job workspace", "worker_id", w.ID, "job_id", job.ID, "error", wsErr)
+                    w.failBeforeRun(job, "Could not prepare the job's workspace", wsErr)
+                    continue
+                }
+                volumePath := ws.Path
+                job.Logger.Info("Prepared job workspace",
+                    logger.Item("path", ws.Path),
+                    logger.Item("mode", ws.Mode),
+                    logger.Item("quota_bytes", ws.QuotaBytes))
+                if err := w.JobQueue.ensureGPUs(job); err != nil {
+                    workerLogger.Error("Failed to allocate job GPUs", "worker_id", w.ID, "job_id", job.ID, "error", err)
+                    w.failBeforeRun(job, "Could not allocate the job's GPUs", err)
+                    continue
+                }
+                if len(job.GPUDevices) > 0 {
+                    job.Logger.Info("Assigned GPUs", logger.Item("devices", job.GPUDevices), logger.Item("request", job.GPUs.String()))
+                }
+                secretEnv, secretValues, err := job.ResolveSecrets(w.JobQueue.Secrets)
+                if err != nil {
+                    workerLogger.Error("Failed to resolve job secrets", "worker_id", w.ID, "job_id", job.ID, "error", err)
+                    w.failBeforeRun(job, "Could not resolve job secrets", err)
+                    continue
+                }
+                jobLogger := job.Logger.Redacting(secretValues...)
+                if len(secretValues) > 0 {
+                    workerLogger.Info("Resolved job secrets", "worker_id", w.ID, "job_id", job.ID, "count", len(secretValues))
+                }
+                deadline := job.ExecutionDeadline(time.Now())
+                jobCtx, cancel := context.WithDeadline(ctx, deadline)
+                workerLogger.Info("Setting up job execution context", "worker_id", w.ID, "job_id", job.ID, "volume_path", volumePath, "deadline", deadline)
 
-                w.JobQueue.Executor.SetCancelFunc(job.ID, cancel)
+                stopHeartbeat := w.heartbeat(ctx, job, cancel)
+                ws.Watch(cancel)
 
                 workerLogger.Info("Executing job command", "worker_id", w.ID, "job_id", job.ID)
-                output, err := w.JobQueue.Executor.RunJob(job.Command, job.ID, volumePath, jobCtx, *job.Logger)
+                spec := executer.Spec{
+                    JobID:      job.ID,
+                    Runtime:    job.Runtime,
+                    Image:      job.Image,
+                    Command:    job.ExecCommand(),
+                    VolumePath: volumePath,
+                    Env:        append(job.Environ(), secretEnv...),
+                    Sandbox:    job.SandboxOptions(),
+                    GPUs:       w.JobQueue.visibleGPUs(job),
+                    Logger:     *jobLogger,
+                }
+                result := executer.Result{ExitCode: -1}
+                // The job may have been cancelled, or taken back by the
+                // lease reaper, while it was being set up; until Start the
+                // executor can't stop it.
+                if w.cancelled(job) {
+                    err = executer.ErrCancelled
+                } else if !w.leaseHeld(ctx, job) {
+                    err = ErrLeaseLost
+                } else {
+                    err = w.JobQueue.Executor.Start(jobCtx, spec)
+                }
+                if err == nil {
+                    result, err = w.JobQueue.Executor.Wait(job.ID)
+                }
+                cancel()
+                leaseLost := stopHeartbeat() || errors.Is(err, ErrLeaseLost)
+                w.JobQueue.ReleaseGPUs(job)
+                quotaErr := ws.Finish(err)
+                jobLogger.Info("Workspace usage", logger.Item("used_bytes", ws.Used()), logger.Item("quota_bytes", ws.QuotaBytes))
+                if leaseLost {
+                    // The attempt was already settled as lost; whatever it
+                    // came to is not the job's outcome.
+                    workerLogger.Warn("Discarding result of job whose lease was lost", "worker_id", w.ID, "job_id", job.ID, "error", err)
+                    jobLogger.Error("Lease on job lost; this attempt was stopped", logger.Item("worker", w.Name))
+                    continue
+                }
 
+                finishedAt := time.Now()
+                job.FinishedAt = &finishedAt
+                if result.ExitCode >= 0 {
+                    exitCode := result.ExitCode
+                    job.ExitCode = &exitCode
+                }
+                job.Signal = result.Signal
+                job.TerminationSignal = result.TermSignal
+                job.PeakMemoryBytes = result.PeakMemoryBytes
+                job.CPUSeconds = result.CPUSeconds
+
+                if quotaErr != nil {
+                    err = quotaErr
+                }
                 if err != nil{
-                    job.Status = StatusFailed
-                    workerLogger.Error("Job execution failed", "worker_id", w.ID, "job_id", job.ID, "error", err)
-                    job.Logger.Error("Job did not complete successfully",
-                        logger.Item("error", err),
+                    switch {
+                    case quotaErr != nil:
+                        job.Status = StatusFailed
+                        job.FailureReason = ReasonDiskQuota
+                    case errors.Is(err, executer.ErrTimedOut):
+                        job.Status = StatusTimedOut
+                        job.FailureReason = ReasonTimeout
+                    case errors.Is(err, executer.ErrCancelled) && ctx.Err() != nil:
+                        // The server is shutting down, not the user cancelling.
+                        job.Status = StatusFailed
+                        job.FailureReason = ReasonPreempted
+                    case errors.Is(err, executer.ErrCancelled):
+                        job.Status = StatusCancelled
+                        job.FailureReason = ReasonCancelled
+                    default:
+                        job.Status = StatusFailed
+                        job.FailureReason = failureReason(result)
+                    }
+                    // The error quotes the command's stderr.
+                    job.Error = jobLogger.Redact(err.Error())
+                    workerLogger.Error("Job execution failed", "worker_id", w.ID, "job_id", job.ID, "error", job.Error)
+                    jobLogger.Error("Job did not complete successfully",
+                        logger.Item("status", job.Status),
+                        logger.Item("error", job.Error),
                         logger.Item("volume_path", volumePath),
                         logger.Item("job_id", job.ID),
                     )
@@ -56,12 +201,116 @@ func (w *Worker) Start(ctx context.Context) {
                     continue
                 }
 
-                workerLogger.Info("Job execution completed successfully", "worker_id", w.ID, "job_id", job.ID, "output_length", len(output))
+                workerLogger.Info("Job execution completed successfully", "worker_id", w.ID, "job_id", job.ID, "output_length", len(result.Output))
                 time.Sleep(1 * time.Second)
                 job.Status = StatusSuccess
-                job.Logger.Info("Completed job", logger.Item("status", job.Status), logger.Item("worker_id", w.ID), logger.Item("command", job.Command))
+                job.Logger.Info("Completed job", logger.Item("status", job.Status), logger.Item("worker_id", w.ID), logger.Item("command", job.Command), logger.Duration("runtime_ms", finishedAt.Sub(startedAt)))
                 w.Results <- job 
             }
         }
     }()
-}
\ No newline at end of file
+}
+
+// failBeforeRun reports a job that failed before its command could start.
+func (w *Worker) failBeforeRun(job *Job, msg string, err error) {
+    finishedAt := time.Now()
+    job.FinishedAt = &finishedAt
+    job.Status = StatusFailed
+    job.FailureReason = ReasonError
+    job.Error = err.Error()
+    job.Logger.Error(msg, logger.Item("error", err))
+    w.JobQueue.ReleaseGPUs(job)
+    w.Results <- job
+}
+
+// cancelled reports whether job was cancelled since it was dequeued.
+// Cancelling only takes jobs out of Redis, so one already handed to the
+// workers has to be caught here.
+func (w *Worker) cancelled(job *Job) bool {
+    if w.JobQueue.Statuses == nil {
+        return false
+    }
+    status, err := w.JobQueue.Statuses.JobStatus(job.ID)
+    if err != nil {
+        workerLogger.Warn("Failed to check job status", "worker_id", w.ID, "job_id", job.ID, "error", err)
+        return false
+    }
+    return status == StatusCancelled
+}
+
+// dropCancelled reports a job that was cancelled before it started, so it
+// is acknowledged without running and any GPUs assigned to it are freed.
+func (w *Worker) dropCancelled(job *Job) {
+    finishedAt := time.Now()
+    job.FinishedAt = &finishedAt
+    job.Status = StatusCancelled
+    job.FailureReason = ReasonCancelled
+    w.JobQueue.ReleaseGPUs(job)
+    job.Logger.Info("Job was cancelled before it started")
+    w.Results <- job
+}
+
+// heartbeat keeps extending the lease on job until the returned function is
+// called. If the lease is lost, the job's run is stopped through cancel and
+// the returned function reports true.
+func (w *Worker) heartbeat(ctx context.Context, job *Job, cancel context.CancelFunc) func() bool {
+    if w.JobQueue.Leases == nil {
+        return func() bool { return false }
+    }
+    hbCtx, stop := context.WithCancel(ctx)
+    done := make(chan struct{})
+    lost := false
+    go func() {
+        defer close(done)
+        ticker := time.NewTicker(HeartbeatInterval)
+        defer ticker.Stop()
+        for {
+            select {
+            case <-hbCtx.Done():
+                return
+            case <-ticker.C:
+                if !w.leaseHeld(hbCtx, job) {
+                    workerLogger.Warn("Lease on running job lost, stopping it", "worker_id", w.ID, "job_id", job.ID)
+                    lost = true
+                    cancel()
+                    return
+                }
+            }
+        }
+    }()
+    return func() bool {
+        stop()
+        <-done
+        return lost
+    }
+}
+
+// leaseHeld extends the lease on job and reports whether it still had one.
+// A failure to reach the lease store is not taken as losing the lease.
+func (w *Worker) leaseHeld(ctx context.Context, job *Job) bool {
+    if w.JobQueue.Leases == nil {
+        return true
+    }
+    err := w.JobQueue.Leases.ExtendLease(ctx, job.ID)
+    if errors.Is(err, ErrLeaseLost) {
+        return false
+    }
+    if err != nil {
+        workerLogger.Warn("Failed to extend job lease", "worker_id", w.ID, "job_id", job.ID, "error", err)
+    }
+    return true
+}
+
+// failureReason classifies a failed run that was not cancelled or timed out.
+func failureReason(result executer.Result) FailureReason {
+    switch {
+    case result.OOMKilled:
+        return ReasonOOM
+    case result.ExitCode >= 0:
+        return ReasonExitCode
+    case result.Signal != "":
+        return ReasonSignal
+    default:
+        return ReasonError
+    }
+}
@@ -0,0 +1,328 @@
+package jobs
+
+import (
+    "context"
+    "slices"
+    "sync"
+    "testing"
+    "time"
+
+    "gpu-runner/internal/executer"
+    "gpu-runner/internal/gpu"
+    "gpu-runner/internal/logger"
+)
+
+// discardSink drops job log entries.
+type discardSink struct{}
+
+func (discardSink) Append(context.Context, string, string) error { return nil }
+
+// statuses is a StatusReader over a fixed set of job statuses.
+type statuses map[string]JobStatus
+
+func (s statuses) JobStatus(id string) (JobStatus, error) {
+    return s[id], nil
+}
+
+// leases is a LeaseKeeper that holds every lease until it is lost.
+type leases struct {
+    mu   sync.Mutex
+    lost map[string]bool
+}
+
+func (l *leases) ExtendLease(_ context.Context, id string) error {
+    l.mu.Lock()
+    defer l.mu.Unlock()
+    if l.lost[id] {
+        return ErrLeaseLost
+    }
+    return nil
+}
+
+func (l *leases) lose(id string) {
+    l.mu.Lock()
+    defer l.mu.Unlock()
+    if l.lost == nil {
+        l.lost = make(map[string]bool)
+    }
+    l.lost[id] = true
+}
+
+// startWorker runs one worker over a queue backed by fake and returns the
+// queue and the channel the worker reports on.
+func startWorker(t *testing.T, fake *executer.Fake) (*JobQueue, chan *Job) {
+    t.Helper()
+    // Without a workspace manager each run gets a directory under TMPDIR.
+    t.Setenv("TMPDIR", t.TempDir())
+    jq := NewJobQueue(1)
+    jq.Executor = fake
+    results := make(chan *Job, 10)
+    ctx, cancel := context.WithCancel(context.Background())
+    t.Cleanup(cancel)
+    NewWorker(1, jq, results).Start(ctx)
+    return jq, results
+}
+
+func newTestJob(id string) *Job {
+    return &Job{
+        ID:      id,
+        Command: "train.py",
+        Status:  StatusPending,
+        Logger:  logger.NewJobLogger(context.Background(), id, discardSink{}),
+    }
+}
+
+// finalResult returns the first report on the job that isn't the running
+// transition.
+func finalResult(t *testing.T, results chan *Job) *Job {
+    t.Helper()
+    timeout := time.After(10 * time.Second)
+    for {
+        select {
+        case job := <-results:
+            if job.Status != StatusRunning {
+                return job
+            }
+        case <-timeout:
+            t.Fatal("worker did not report a result")
+            return nil
+        }
+    }
+}
+
+// waitStarted waits until the fake has a run of jobID in progress.
+func waitStarted(t *testing.T, fake *executer.Fake, jobID string) {
+    t.Helper()
+    deadline := time.Now().Add(5 * time.Second)
+    for {
+        if _, err := fake.Stats(jobID); err == nil {
+            return
+        }
+        if time.Now().After(deadline) {
+            t.Fatalf("job %s never started", jobID)
+        }
+        time.Sleep(10 * time.Millisecond)
+    }
+}
+
+func TestWorkerReportsSuccess(t *testing.T) {
+    fake := executer.NewFake()
+    fake.Script("1", executer.FakeOutcome{Output: "epoch 1\n"})
+    jq, results := startWorker(t, fake)
+
+    jq.Enqueue(newTestJob("1"))
+    running := <-results
+    if running.Status != StatusRunning || running.StartedAt == nil || running.WorkerID == "" {
+        t.Fatalf("first report = %+v, want the job running on a worker", running)
+    }
+
+    job := finalResult(t, results)
+    if job.Status != StatusSuccess {
+        t.Fatalf("status = %s, want success (error %q)", job.Status, job.Error)
+    }
+    if job.ExitCode == nil || *job.ExitCode != 0 || job.FinishedAt == nil {
+        t.Errorf("exit code = %v, finished at = %v; want 0 and a finish time", job.ExitCode, job.FinishedAt)
+    }
+    started := fake.Started()
+    if len(started) != 1 || started[0].Command.Script != "train.py" || started[0].VolumePath != job.VolumePath {
+        t.Errorf("fake started %+v, want one run of train.py in the job's workspace", started)
+    }
+    if job.VolumePath == "" {
+        t.Error("job ran without a workspace")
+    }
+}
+
+func TestWorkerReportsExitCode(t *testing.T) {
+    fake := executer.NewFake()
+    fake.Script("1", executer.FakeOutcome{ExitCode: 3})
+    jq, results := startWorker(t, fake)
+
+    jq.Enqueue(newTestJob("1"))
+    job := finalResult(t, results)
+    if job.Status != StatusFailed || job.FailureReason != ReasonExitCode {
+        t.Fatalf("status = %s (%s), want failed with exit_code", job.Status, job.FailureReason)
+    }
+    if job.ExitCode == nil || *job.ExitCode != 3 {
+        t.Errorf("exit code = %v, want 3", job.ExitCode)
+    }
+    if job.Error == "" {
+        t.Error("failed job has no error")
+    }
+}
+
+func TestWorkerReportsTimeout(t *testing.T) {
+    fake := executer.NewFake()
+    fake.Script("1", executer.FakeOutcome{Duration: time.Minute})
+    jq, results := startWorker(t, fake)
+
+    job := newTestJob("1")
+    deadline := time.Now().Add(200 * time.Millisecond)
+    job.Deadline = &deadline
+    jq.Enqueue(job)
+
+    job = finalResult(t, results)
+    if job.Status != StatusTimedOut || job.FailureReason != ReasonTimeout {
+        t.Fatalf("status = %s (%s), want timed_out with timeout", job.Status, job.FailureReason)
+    }
+    if job.TerminationSignal != "SIGTERM" || job.ExitCode != nil {
+        t.Errorf("termination signal = %q, exit code = %v; want SIGTERM and none", job.TerminationSignal, job.ExitCode)
+    }
+}
+
+func TestWorkerReportsCancel(t *testing.T) {
+    fake := executer.NewFake()
+    fake.Script("1", executer.FakeOutcome{Duration: time.Minute})
+    jq, results := startWorker(t, fake)
+
+    jq.Enqueue(newTestJob("1"))
+    waitStarted(t, fake, "1")
+    if err := fake.Cancel("1"); err != nil {
+        t.Fatalf("Cancel: %v", err)
+    }
+
+    job := finalResult(t, results)
+    if job.Status != StatusCancelled || job.FailureReason != ReasonCancelled {
+        t.Fatalf("status = %s (%s), want cancelled", job.Status, job.FailureReason)
+    }
+}
+
+func TestWorkerDropsJobCancelledInQueue(t *testing.T) {
+    fake := executer.NewFake()
+    jq, results := startWorker(t, fake)
+    jq.Statuses = statuses{"1": StatusCancelled}
+
+    jq.Enqueue(newTestJob("1"))
+    job := finalResult(t, results)
+    if job.Status != StatusCancelled || job.StartedAt != nil {
+        t.Fatalf("report = %+v, want the job cancelled without starting", job)
+    }
+    if len(fake.Started()) != 0 {
+        t.Error("cancelled job was started")
+    }
+}
+
+func TestWorkerDropsJobWhoseLeaseWasLost(t *testing.T) {
+    fake := executer.NewFake()
+    jq, results := startWorker(t, fake)
+    lk := &leases{}
+    lk.lose("1")
+    jq.Leases = lk
+
+    jq.Enqueue(newTestJob("1"))
+    jq.Enqueue(newTestJob("2"))
+    // The worker takes jobs in order, so a report on job 1 would come first.
+    if first := <-results; first.ID != "2" {
+        t.Fatalf("first report = %+v, want none on the job whose lease was lost", first)
+    }
+    finalResult(t, results)
+    for _, spec := range fake.Started() {
+        if spec.JobID == "1" {
+            t.Error("job whose lease was lost was started")
+        }
+    }
+}
+
+func TestWorkerStopsJobWhenLeaseIsLost(t *testing.T) {
+    old := HeartbeatInterval
+    HeartbeatInterval = 20 * time.Millisecond
+    t.Cleanup(func() { HeartbeatInterval = old })
+    fake := executer.NewFake()
+    fake.Script("1", executer.FakeOutcome{Duration: time.Minute})
+    jq, results := startWorker(t, fake)
+    lk := &leases{}
+    jq.Leases = lk
+
+    jq.Enqueue(newTestJob("1"))
+    if running := <-results; running.ID != "1" || running.Status != StatusRunning {
+        t.Fatalf("first report = %+v, want job 1 running", running)
+    }
+    waitStarted(t, fake, "1")
+    lk.lose("1")
+
+    // The stopped run must not be reported as the job's outcome.
+    jq.Enqueue(newTestJob("2"))
+    if next := <-results; next.ID != "2" {
+        t.Fatalf("report = %+v, want none on job 1 after its lease was lost", next)
+    }
+    if _, err := fake.Stats("1"); err == nil {
+        t.Error("job 1 still running after its lease was lost")
+    }
+    finalResult(t, results)
+}
+
+// useFakeGPUs gives the queue an allocator over a fake inventory.
+func useFakeGPUs(t *testing.T, jq *JobQueue, spec string) {
+    t.Helper()
+    fake, err := gpu.ParseFake(spec)
+    if err != nil {
+        t.Fatalf("ParseFake: %v", err)
+    }
+    jq.GPUs, err = gpu.NewAllocator(fake)
+    if err != nil {
+        t.Fatalf("NewAllocator: %v", err)
+    }
+}
+
+func TestWorkerLimitsJobToItsGPUs(t *testing.T) {
+    fake := executer.NewFake()
+    jq, results := startWorker(t, fake)
+    useFakeGPUs(t, jq, "2xT4:15360,A100:81920")
+
+    // Job 1's devices are allocated as the adapter hands it off, job 2's
+    // by the worker, and job 3 asks for none.
+    job := newTestJob("1")
+    job.GPUs = &gpu.Request{Count: 1, Model: "a100"}
+    if err := jq.AssignGPUs(job); err != nil {
+        t.Fatalf("AssignGPUs: %v", err)
+    }
+    jq.Enqueue(job)
+    job = newTestJob("2")
+    job.GPUs = &gpu.Request{Count: 2}
+    jq.Enqueue(job)
+    jq.Enqueue(newTestJob("3"))
+    for range 3 {
+        if job := finalResult(t, results); job.Status != StatusSuccess {
+            t.Fatalf("job %s: status = %s, want success (error %q)", job.ID, job.Status, job.Error)
+        }
+    }
+
+    want := map[string]struct {
+        gpus         []int
+        cuda, nvidia string
+    }{
+        "1": {[]int{2}, "CUDA_VISIBLE_DEVICES=2", "NVIDIA_VISIBLE_DEVICES=2"},
+        "2": {[]int{0, 1}, "CUDA_VISIBLE_DEVICES=0,1", "NVIDIA_VISIBLE_DEVICES=0,1"},
+        "3": {[]int{}, "CUDA_VISIBLE_DEVICES=", "NVIDIA_VISIBLE_DEVICES=none"},
+    }
+    for _, spec := range fake.Started() {
+        w := want[spec.JobID]
+        if !slices.Equal(spec.GPUs, w.gpus) || spec.GPUs == nil {
+            t.Errorf("job %s ran with GPUs %v, want %v", spec.JobID, spec.GPUs, w.gpus)
+        }
+        if !slices.Contains(spec.Env, w.cuda) || !slices.Contains(spec.Env, w.nvidia) {
+            t.Errorf("job %s ran with env %q, want %s and %s", spec.JobID, spec.Env, w.cuda, w.nvidia)
+        }
+    }
+    for _, d := range jq.GPUs.Devices() {
+        if d.JobID != "" {
+            t.Errorf("device %d still held by job %s after it finished", d.Index, d.JobID)
+        }
+    }
+}
+
+func TestWorkerFailsJobAskingForMissingGPUs(t *testing.T) {
+    fake := executer.NewFake()
+    jq, results := startWorker(t, fake)
+    useFakeGPUs(t, jq, "T4:15360")
+
+    job := newTestJob("1")
+    job.GPUs = &gpu.Request{Count: 1, Model: "a100"}
+    jq.Enqueue(job)
+    job = finalResult(t, results)
+    if job.Status != StatusFailed || job.FailureReason != ReasonError {
+        t.Fatalf("status = %s (%s), want failed with error", job.Status, job.FailureReason)
+    }
+    if len(fake.Started()) != 0 {
+        t.Error("job was started without the GPUs it asked for")
+    }
+}
@@ -0,0 +1,185 @@
+package jobs
+
+import (
+    "fmt"
+    "strings"
+    "time"
+)
+
+// Workflow groups jobs submitted together whose dependencies form a DAG.
+type Workflow struct {
+    ID        string    `json:"id"`
+    Name      string    `json:"name,omitempty"`
+    CreatedAt time.Time `json:"created_at"`
+}
+
+// WorkflowRequest is the body of POST /workflows.
+type WorkflowRequest struct {
+    Name  string         `json:"name"`
+    Steps []WorkflowStep `json:"steps"`
+}
+
+// WorkflowStep is one job of a workflow. Its DependsOn entries name other
+// steps; Job.DependsOn may still list jobs outside the workflow.
+type WorkflowStep struct {
+    Name      string           `json:"name"`
+    Job       JobRequest       `json:"job"`
+    DependsOn []StepDependency `json:"depends_on,omitempty"`
+}
+
+// StepDependency is a Dependency on another step of the same workflow.
+type StepDependency struct {
+    Step      string              `json:"step"`
+    Condition DependencyCondition `json:"condition,omitempty"`
+}
+
+// Order checks the step names and dependencies and returns the step
+// indexes in an order where every step follows the steps it depends on.
+// A dependency cycle is reported with the steps that form it.
+func (w WorkflowRequest) Order() ([]int, error) {
+    if len(w.Steps) == 0 {
+        return nil, fmt.Errorf("workflow has no steps")
+    }
+    index := make(map[string]int, len(w.Steps))
+    for i, step := range w.Steps {
+        if step.Name == "" {
+            return nil, fmt.Errorf("step %d has no name", i+1)
+        }
+        if _, dup := index[step.Name]; dup {
+            return nil, fmt.Errorf("step name %q is used more than once", step.Name)
+        }
+        index[step.Name] = i
+    }
+    for _, step := range w.Steps {
+        for _, dep := range step.DependsOn {
+            if _, ok := index[dep.Step]; !ok {
+                return nil, fmt.Errorf("step %q depends on unknown step %q", step.Name, dep.Step)
+            }
+        }
+    }
+
+    const (
+        unvisited = iota
+        visiting
+        done
+    )
+    state := make([]int, len(w.Steps))
+    order := make([]int, 0, len(w.Steps))
+    var path []string
+    var visit func(i int) error
+    visit = func(i int) error {
+        switch state[i] {
+        case done:
+            return nil
+        case visiting:
+            start := 0
+            for start < len(path) && path[start] != w.Steps[i].Name {
+                start++
+            }
+            cycle := append(path[start:], w.Steps[i].Name)
+            return fmt.Errorf("dependency cycle: %s", strings.Join(cycle, " -> "))
+        }
+        state[i] = visiting
+        path = append(path, w.Steps[i].Name)
+        for _, dep := range w.Steps[i].DependsOn {
+            if err := visit(index[dep.Step]); err != nil {
+                return err
+            }
+        }
+        path = path[:len(path)-1]
+        state[i] = done
+        order = append(order, i)
+        return nil
+    }
+    for i := range w.Steps {
+        if err := visit(i); err != nil {
+            return nil, err
+        }
+    }
+    return order, nil
+}
+
+// AggregateStatus summarises a group of jobs, such as a workflow or an
+// array, from the number of jobs in each status: pending until one of them
+// starts, running while any can still run, then failed if any failed,
+// cancelled if all were cancelled, and success otherwise. Jobs skipped
+// because their dependency condition was not met are cancelled, so they
+// don't fail the group.
+func AggregateStatus(counts map[JobStatus]int) JobStatus {
+    active, finished, failed := 0, 0, false
+    for status, n := range counts {
+        if n == 0 {
+            continue
+        }
+        if !status.IsTerminal() {
+            active += n
+            continue
+        }
+        finished += n
+        if status != StatusSuccess && status != StatusCancelled {
+            failed = true
+        }
+    }
+    switch {
+    case active > 0 && finished == 0 && counts[StatusRunning] == 0:
+        return StatusPending
+    case active > 0:
+        return StatusRunning
+    case failed:
+        return StatusFailed
+    case finished > 0 && counts[StatusCancelled] == finished:
+        return StatusCancelled
+    }
+    return StatusSuccess
+}
+
+// NewJobs validates the workflow and builds its jobs, every job after the
+// jobs it depends on. Dependencies between steps are added by Link once
+// the parents have been stored and have IDs.
+func (w WorkflowRequest) NewJobs(now time.Time) ([]*Job, error) {
+    order, err := w.Order()
+    if err != nil {
+        return nil, err
+    }
+    built := make([]*Job, 0, len(order))
+    for _, i := range order {
+        step := w.Steps[i]
+        for _, dep := range step.DependsOn {
+            switch dep.Condition {
+            case "", OnSuccess, OnCompletion, OnFailure:
+            default:
+                return nil, fmt.Errorf("step %q: unknown dependency condition %q", step.Name, dep.Condition)
+            }
+        }
+        if step.Job.Array != nil {
+            return nil, fmt.Errorf("step %q: workflow steps cannot be arrays", step.Name)
+        }
+        job, err := step.Job.NewJob(now)
+        if err != nil {
+            return nil, fmt.Errorf("step %q: %w", step.Name, err)
+        }
+        job.WorkflowStep = step.Name
+        built = append(built, job)
+    }
+    return built, nil
+}
+
+// Link adds job's dependencies on other steps, given the IDs of the steps
+// stored so far, and blocks the job if it now has any.
+func (w WorkflowRequest) Link(job *Job, ids map[string]string) {
+    for _, step := range w.Steps {
+        if step.Name != job.WorkflowStep {
+            continue
+        }
+        for _, dep := range step.DependsOn {
+            cond := dep.Condition
+            if cond == "" {
+                cond = OnSuccess
+            }
+            job.DependsOn = append(job.DependsOn, Dependency{JobID: ids[dep.Step], Condition: cond})
+        }
+    }
+    if len(job.DependsOn) > 0 {
+        job.Status = StatusBlocked
+    }
+}
@@ -0,0 +1,22 @@
+package jobs
+
+import (
+    "fmt"
+    "os"
+
+    "gpu-runner/internal/workspace"
+)
+
+// prepareWorkspace gives the job its workspace from Workspaces. Without a
+// manager the job gets a fresh temporary directory, measured against its
+// quota like a ModeDir workspace; nothing retains or removes it afterwards.
+func (jq *JobQueue) prepareWorkspace(job *Job) (*workspace.Workspace, error) {
+    if jq.Workspaces != nil {
+        return jq.Workspaces.Prepare(job.ID, int64(job.StorageBytes))
+    }
+    dir, err := os.MkdirTemp("", "gpu-runner-job-")
+    if err != nil {
+        return nil, fmt.Errorf("create workspace: %w", err)
+    }
+    return &workspace.Workspace{Path: dir, JobID: job.ID, Mode: workspace.ModeDir, QuotaBytes: int64(job.StorageBytes)}, nil
+}
@@ -1,8 +1,11 @@
 package logger
 
 import (
+	"cmp"
 	"context"
 	"encoding/json"
+	"slices"
+	"strings"
 	"time"
 )
 
@@ -26,6 +29,7 @@ type LogEntry struct {
 	Level     string
 	Message   string
 	Timestamp time.Time
+	Stream    string
 	Fields    []Field
 }
 
@@ -34,9 +38,36 @@ type wireLogEntry struct {
 	Level     string         `json:"level"`
 	Message   string         `json:"message"`
 	Timestamp time.Time      `json:"timestamp"`
+	Stream    string         `json:"stream,omitempty"`
 	Fields    map[string]any `json:"fields,omitempty"`
 }
 
+// Output streams of the job process. Entries carrying one of these hold a
+// line of program output rather than a runner message.
+const (
+	StreamStdout = "stdout"
+	StreamStderr = "stderr"
+)
+
+// LevelOutput is the level recorded for program output entries.
+const LevelOutput = "output"
+
+// Record is a stored entry read back from a sink, tagged with the ID the
+// sink assigned to it.
+type Record struct {
+	ID string `json:"id"`
+	wireLogEntry
+}
+
+// ParseRecord decodes an entry previously written by a JobLogger.
+func ParseRecord(id, data string) (Record, error) {
+	rec := Record{ID: id}
+	if err := json.Unmarshal([]byte(data), &rec.wireLogEntry); err != nil {
+		return rec, err
+	}
+	return rec, nil
+}
+
 /*
  ─────────────────────────────────────────────
  Logger
@@ -48,8 +79,15 @@ type JobLogger struct {
 	jobID string
 	sink  StreamSink
 	base  []Field
+
+	// secrets are masked wherever they appear in an entry; see Redacting.
+	secrets  []string
+	redactor *strings.Replacer
 }
 
+// Redacted replaces secret values in log entries.
+const Redacted = "[REDACTED]"
+
 func NewJobLogger(
 	ctx context.Context,
 	jobID string,
@@ -79,19 +117,40 @@ func (l *JobLogger) Info(msg string, fields ...Field) {
 	l.log("info", msg, fields...)
 }
 
+func (l *JobLogger) Warn(msg string, fields ...Field) {
+	l.log("warn", msg, fields...)
+}
+
 func (l *JobLogger) Error(msg string, fields ...Field) {
 	l.log("error", msg, fields...)
 }
 
+// Output records one line (or chunk) the job process wrote to stream.
+func (l *JobLogger) Output(stream, line string, fields ...Field) {
+	l.write(LevelOutput, stream, line, fields...)
+}
+
 func (l *JobLogger) log(level, msg string, fields ...Field) {
-	all := append(l.base, fields...)
+	l.write(level, "", msg, fields...)
+}
+
+func (l *JobLogger) write(level, stream, msg string, fields ...Field) {
+	all := append(l.base[:len(l.base):len(l.base)], fields...)
 
 	wire := wireLogEntry{
 		Level:     level,
-		Message:   msg,
+		Message:   l.Redact(msg),
 		Timestamp: time.Now().UTC(),
+		Stream:    stream,
 		Fields:    fieldsToMap(all),
 	}
+	if l.redactor != nil {
+		for k, v := range wire.Fields {
+			if s, ok := v.(string); ok {
+				wire.Fields[k] = l.Redact(s)
+			}
+		}
+	}
 
 	data, err := json.Marshal(wire)
 	if err != nil {
@@ -109,10 +168,72 @@ func (l *JobLogger) log(level, msg string, fields ...Field) {
 
 func (l *JobLogger) With(fields ...Field) *JobLogger {
 	child := *l
-	child.base = append(l.base, fields...)
+	child.base = append(l.base[:len(l.base):len(l.base)], fields...)
 	return &child
 }
 
+// minSecretLine is the shortest line of a multi-line secret that is masked
+// on its own. Shorter ones, like the braces around JSON credentials, are
+// too common in other output to mask and give little away.
+const minSecretLine = 8
+
+// Redacting returns a logger that replaces each of values with Redacted
+// in messages and string fields, so secrets handed to a job stay out of
+// its log even if the job prints them. Job output is logged a line at a
+// time, so each line of a multi-line value, such as a PEM key, is masked
+// on its own as well.
+func (l *JobLogger) Redacting(values ...string) *JobLogger {
+	child := *l
+	child.secrets = slices.Clone(l.secrets)
+	for _, v := range values {
+		if v == "" {
+			continue
+		}
+		child.secrets = append(child.secrets, v)
+		if !strings.ContainsAny(v, "\r\n") {
+			continue
+		}
+		for _, line := range strings.FieldsFunc(v, func(r rune) bool { return r == '\n' || r == '\r' }) {
+			if line = strings.TrimSpace(line); len(line) >= minSecretLine {
+				child.secrets = append(child.secrets, line)
+			}
+		}
+	}
+	if len(child.secrets) == 0 {
+		return &child
+	}
+	// Longer values first, so a secret containing another is masked whole.
+	slices.SortFunc(child.secrets, func(a, b string) int { return cmp.Compare(len(b), len(a)) })
+	pairs := make([]string, 0, 2*len(child.secrets))
+	for _, v := range child.secrets {
+		pairs = append(pairs, v, Redacted)
+	}
+	child.redactor = strings.NewReplacer(pairs...)
+	return &child
+}
+
+// LongestSecret returns the length of the longest value the logger masks
+// that fits on one line, or 0 if there is none. Output split at some point
+// within a line must keep that many bytes minus one past it for a secret
+// crossing the split to be masked.
+func (l *JobLogger) LongestSecret() int {
+	longest := 0
+	for _, v := range l.secrets {
+		if len(v) > longest && !strings.ContainsAny(v, "\r\n") {
+			longest = len(v)
+		}
+	}
+	return longest
+}
+
+// Redact applies the logger's redactions to s.
+func (l *JobLogger) Redact(s string) string {
+	if l.redactor == nil {
+		return s
+	}
+	return l.redactor.Replace(s)
+}
+
 /*
  ─────────────────────────────────────────────
  Field Helpers
@@ -144,6 +265,11 @@ func Time(key string, val time.Time) Field {
 func fieldsToMap(fields []Field) map[string]any {
 	m := make(map[string]any, len(fields))
 	for _, f := range fields {
+		// errors have no exported fields and would encode as {}
+		if err, ok := f.Value.(error); ok {
+			m[f.Key] = err.Error()
+			continue
+		}
 		m[f.Key] = f.Value
 	}
 	return m
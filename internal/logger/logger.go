@@ -10,7 +10,16 @@ import (
 
 var Server *slog.Logger
 
+// sandboxInitName is the argv[0] the executer re-executes the server as
+// inside a job sandbox, where the server log is out of reach and nothing
+// is logged.
+const sandboxInitName = "gpu-runner-sandbox-init"
+
 func init() {
+	if os.Args[0] == sandboxInitName {
+		Server = slog.New(slog.NewTextHandler(io.Discard, nil))
+		return
+	}
 	baseDir := filepath.Join("~", "log", "gpu-runner")
 	_ = os.MkdirAll(baseDir, 0o755)
 
@@ -0,0 +1,149 @@
+package redis
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"time"
+
+	"gpu-runner/internal/jobs"
+	"gpu-runner/internal/logger"
+)
+
+// JobDeadKey is the dead-letter list. Newest entries are at the head.
+const JobDeadKey = "gpu-runner:jobs:dead"
+
+// ErrDeadLetterNotFound is returned when a job has no dead-letter entry.
+var ErrDeadLetterNotFound = errors.New("job not in dead-letter queue")
+
+// DeadLetter is a job that used up its retries, with the reason of its last
+// failure.
+type DeadLetter struct {
+	Job    jobs.Job           `json:"job"`
+	Reason jobs.FailureReason `json:"reason,omitempty"`
+	Error  string             `json:"error,omitempty"`
+	DeadAt time.Time          `json:"dead_at"`
+}
+
+// deadEntry pairs a decoded entry with its raw list member so it can be
+// removed with LREM.
+type deadEntry struct {
+	DeadLetter
+	raw string
+}
+
+// DeadLetter moves a job into the dead-letter queue.
+func (c *Client) DeadLetter(ctx context.Context, job jobs.Job) error {
+	entry := DeadLetter{
+		Job:    job,
+		Reason: job.FailureReason,
+		Error:  job.Error,
+		DeadAt: time.Now(),
+	}
+	entry.Job.Logger = nil
+	data, err := json.Marshal(entry)
+	if err != nil {
+		redisLogger.Error("Failed to marshal dead letter", "error", err, "job_id", job.ID)
+		return fmt.Errorf("failed to marshal dead letter: %w", err)
+	}
+
+	if err := c.rdb.LPush(ctx, JobDeadKey, data).Err(); err != nil {
+		if job.Logger != nil {
+			job.Logger.Error("Failed to move job to dead-letter queue", logger.Item("error", err))
+		}
+		redisLogger.Error("Redis LPush failed", "error", err, "job_id", job.ID, "queue", JobDeadKey)
+		return fmt.Errorf("failed to dead-letter job: %w", err)
+	}
+
+	if job.Logger != nil {
+		job.Logger.Error("Job moved to dead-letter queue", logger.Item("reason", job.FailureReason))
+	}
+	redisLogger.Warn("Job moved to dead-letter queue", "job_id", job.ID, "reason", job.FailureReason, "queue", JobDeadKey)
+	return nil
+}
+
+// ListDeadLetters returns up to limit entries, newest first. A limit of 0
+// returns every entry.
+func (c *Client) ListDeadLetters(ctx context.Context, limit int64) ([]DeadLetter, error) {
+	entries, err := c.deadEntries(ctx, limit)
+	if err != nil {
+		return nil, err
+	}
+	letters := make([]DeadLetter, len(entries))
+	for i, e := range entries {
+		letters[i] = e.DeadLetter
+	}
+	return letters, nil
+}
+
+// GetDeadLetter returns the dead-letter entry for a job.
+func (c *Client) GetDeadLetter(ctx context.Context, jobID string) (*DeadLetter, error) {
+	e, err := c.findDeadEntry(ctx, jobID)
+	if err != nil {
+		return nil, err
+	}
+	return &e.DeadLetter, nil
+}
+
+// RemoveDeadLetter deletes a job's dead-letter entry.
+func (c *Client) RemoveDeadLetter(ctx context.Context, jobID string) error {
+	e, err := c.findDeadEntry(ctx, jobID)
+	if err != nil {
+		return err
+	}
+	if err := c.rdb.LRem(ctx, JobDeadKey, 1, e.raw).Err(); err != nil {
+		redisLogger.Error("Redis LRem failed", "error", err, "job_id", jobID, "queue", JobDeadKey)
+		return fmt.Errorf("failed to remove dead letter: %w", err)
+	}
+	redisLogger.Info("Removed job from dead-letter queue", "job_id", jobID)
+	return nil
+}
+
+// PurgeDeadLetters empties the dead-letter queue and returns how many
+// entries were dropped.
+func (c *Client) PurgeDeadLetters(ctx context.Context) (int64, error) {
+	n, err := c.rdb.LLen(ctx, JobDeadKey).Result()
+	if err != nil {
+		redisLogger.Error("Failed to get dead-letter queue length", "error", err, "queue", JobDeadKey)
+		return 0, err
+	}
+	if err := c.rdb.Del(ctx, JobDeadKey).Err(); err != nil {
+		redisLogger.Error("Failed to purge dead-letter queue", "error", err, "queue", JobDeadKey)
+		return 0, err
+	}
+	redisLogger.Warn("Purged dead-letter queue", "count", n)
+	return n, nil
+}
+
+func (c *Client) findDeadEntry(ctx context.Context, jobID string) (*deadEntry, error) {
+	entries, err := c.deadEntries(ctx, 0)
+	if err != nil {
+		return nil, err
+	}
+	for i := range entries {
+		if entries[i].Job.ID == jobID {
+			return &entries[i], nil
+		}
+	}
+	return nil, ErrDeadLetterNotFound
+}
+
+func (c *Client) deadEntries(ctx context.Context, limit int64) ([]deadEntry, error) {
+	raw, err := c.rdb.LRange(ctx, JobDeadKey, 0, limit-1).Result()
+	if err != nil {
+		redisLogger.Error("Failed to read dead-letter queue", "error", err, "queue", JobDeadKey)
+		return nil, err
+	}
+	entries := make([]deadEntry, 0, len(raw))
+	for _, r := range raw {
+		var e deadEntry
+		if err := json.Unmarshal([]byte(r), &e.DeadLetter); err != nil {
+			redisLogger.Warn("Skipping undecodable dead letter", "error", err)
+			continue
+		}
+		e.raw = r
+		entries = append(entries, e)
+	}
+	return entries, nil
+}
@@ -0,0 +1,136 @@
+package redis
+
+import (
+	"context"
+	"fmt"
+	"strconv"
+	"time"
+
+	"gpu-runner/internal/jobs"
+	"gpu-runner/internal/logger"
+
+	"github.com/redis/go-redis/v9"
+)
+
+// JobDelayedKey is a sorted set of job IDs scored by the unix millisecond
+// time they become due. Jobs submitted with a run_at and retries waiting
+// out their backoff are held here.
+const JobDelayedKey = "gpu-runner:jobs:delayed"
+
+const promoteBatch = 100
+
+// EnqueueAt holds a job in the delayed set until at, after which the
+// promoter moves it to the pending queue.
+func (c *Client) EnqueueAt(ctx context.Context, job jobs.Job, at time.Time) error {
+	if !at.After(time.Now()) {
+		return c.Enqueue(ctx, job)
+	}
+
+	data, err := marshalJob(job)
+	if err != nil {
+		return err
+	}
+
+	_, err = c.rdb.TxPipelined(ctx, func(pipe redis.Pipeliner) error {
+		pipe.HSet(ctx, JobPayloadKey, job.ID, data)
+		pipe.ZAdd(ctx, JobDelayedKey, redis.Z{Score: float64(at.UnixMilli()), Member: job.ID})
+		return nil
+	})
+	if err != nil {
+		if job.Logger != nil {
+			job.Logger.Error("Failed to schedule job in Redis", logger.Item("error", err))
+		}
+		redisLogger.Error("Redis ZAdd failed", "error", err, "job_id", job.ID, "queue", JobDelayedKey)
+		return fmt.Errorf("failed to schedule job: %w", err)
+	}
+
+	if job.Logger != nil {
+		job.Logger.Info("Job scheduled in Redis delayed queue", logger.Item("run_at", at))
+	}
+	redisLogger.Info("Job scheduled successfully", "job_id", job.ID, "queue", JobDelayedKey, "run_at", at)
+	return nil
+}
+
+// PromoteDueJobs moves every delayed job whose time has come onto the
+// pending queue and returns the IDs it moved. A promoted job is scored as
+// if it was enqueued when it became due.
+func (c *Client) PromoteDueJobs(ctx context.Context, now time.Time) ([]string, error) {
+	var promoted []string
+	for {
+		due, err := c.rdb.ZRangeByScoreWithScores(ctx, JobDelayedKey, &redis.ZRangeBy{
+			Min:   "-inf",
+			Max:   strconv.FormatInt(now.UnixMilli(), 10),
+			Count: promoteBatch,
+		}).Result()
+		if err != nil {
+			redisLogger.Error("Failed to read delayed jobs", "error", err, "queue", JobDelayedKey)
+			return promoted, err
+		}
+		for _, z := range due {
+			id := z.Member.(string)
+			score := c.pendingScore(ctx, id, time.UnixMilli(int64(z.Score)))
+			n, err := promoteOneScript.Run(ctx, c.rdb, []string{JobDelayedKey, JobQueueKey}, id, scoreArg(score)).Int64()
+			if err != nil {
+				redisLogger.Error("Failed to promote delayed job", "error", err, "job_id", id)
+				return promoted, err
+			}
+			if n == 1 {
+				promoted = append(promoted, id)
+			}
+		}
+		if len(due) < promoteBatch {
+			return promoted, nil
+		}
+	}
+}
+
+// StartDelayedPromoter polls the delayed set every interval until ctx is
+// done, calling onPromoted for each job it moves to the pending queue.
+func (c *Client) StartDelayedPromoter(ctx context.Context, interval time.Duration, onPromoted func(jobID string)) {
+	redisLogger.Info("Starting delayed job promoter", "queue", JobDelayedKey, "interval", interval)
+	go func() {
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-ctx.Done():
+				redisLogger.Info("Delayed job promoter shutting down")
+				return
+			case now := <-ticker.C:
+				promoted, _ := c.PromoteDueJobs(ctx, now)
+				if len(promoted) > 0 {
+					redisLogger.Info("Promoted delayed jobs", "count", len(promoted))
+				}
+				for _, id := range promoted {
+					onPromoted(id)
+				}
+			}
+		}
+	}()
+}
+
+// removeScript drops a job that is still waiting, in the pending queue or
+// the delayed set, along with its payload. Jobs already handed to a worker
+// are left alone.
+var removeScript = redis.NewScript(`
+local removed = redis.call('ZREM', KEYS[1], ARGV[1]) + redis.call('ZREM', KEYS[2], ARGV[1])
+if removed > 0 then
+	redis.call('HDEL', KEYS[3], ARGV[1])
+end
+return removed
+`)
+
+// Remove takes a job that has not started yet out of the queue. It reports
+// whether the job was found waiting; a job already handed to the workers
+// is not, and the worker drops it once it sees the job is cancelled.
+func (c *Client) Remove(ctx context.Context, jobID string) (bool, error) {
+	n, err := removeScript.Run(ctx, c.rdb, []string{JobQueueKey, JobDelayedKey, JobPayloadKey}, jobID).Int()
+	if err != nil {
+		redisLogger.Error("Failed to remove job from queue", "error", err, "job_id", jobID)
+		return false, fmt.Errorf("failed to remove job: %w", err)
+	}
+	if n > 0 {
+		redisLogger.Info("Removed waiting job from queue", "job_id", jobID)
+	}
+	return n > 0, nil
+}
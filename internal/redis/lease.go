@@ -0,0 +1,165 @@
+package redis
+
+import (
+	"context"
+	"encoding/json"
+	"strconv"
+	"strings"
+	"time"
+
+	"gpu-runner/internal/jobs"
+
+	"github.com/redis/go-redis/v9"
+)
+
+// JobLeaseKey is a sorted set of in-flight job IDs scored by the unix
+// millisecond time their lease expires. Workers push the expiry forward
+// while a job runs; the reaper requeues jobs whose lease ran out.
+const JobLeaseKey = "gpu-runner:jobs:leases"
+
+// LeaseDuration is how long a dequeued job may go without a heartbeat
+// before it is considered lost.
+var LeaseDuration = 30 * time.Second
+
+// extendScript only moves an existing lease, so a worker can't revive a
+// job the reaper has already requeued.
+var extendScript = redis.NewScript(`
+if redis.call('ZSCORE', KEYS[1], ARGV[1]) then
+	redis.call('ZADD', KEYS[1], ARGV[2], ARGV[1])
+	return 1
+end
+return 0
+`)
+
+// reapScript takes back one job whose lease is still expired by removing
+// the lease, if the job is still in the processing list. It stays there
+// until the caller settles it, so a crash in between only leaves it to be
+// leased and reaped again.
+var reapScript = redis.NewScript(`
+local expiry = redis.call('ZSCORE', KEYS[1], ARGV[1])
+if not expiry or tonumber(expiry) > tonumber(ARGV[2]) then
+	return 0
+end
+redis.call('ZREM', KEYS[1], ARGV[1])
+if not redis.call('LPOS', KEYS[2], ARGV[1]) then
+	return 0
+end
+return 1
+`)
+
+func leaseExpiry(now time.Time) string {
+	return strconv.FormatInt(now.Add(LeaseDuration).UnixMilli(), 10)
+}
+
+// ExtendLease pushes a running job's lease expiry forward. It returns
+// jobs.ErrLeaseLost when the lease is gone.
+func (c *Client) ExtendLease(ctx context.Context, jobID string) error {
+	n, err := extendScript.Run(ctx, c.rdb, []string{JobLeaseKey}, jobID, leaseExpiry(time.Now())).Int()
+	if err != nil {
+		redisLogger.Error("Failed to extend job lease", "error", err, "job_id", jobID)
+		return err
+	}
+	if n == 0 {
+		return jobs.ErrLeaseLost
+	}
+	return nil
+}
+
+// ReapExpiredLeases takes back every in-flight job whose lease has expired
+// and returns them as they were when the lease was lost. They are left in
+// the processing list for the caller to requeue, fail or dead-letter, as
+// the job's retry policy decides. Processing entries without a lease, left
+// by a crash between dequeue and lease, are given one so they expire too.
+func (c *Client) ReapExpiredLeases(ctx context.Context, at time.Time) ([]jobs.Job, error) {
+	if err := c.leaseOrphans(ctx); err != nil {
+		return nil, err
+	}
+
+	expired, err := c.rdb.ZRangeByScore(ctx, JobLeaseKey, &redis.ZRangeBy{
+		Min: "-inf",
+		Max: strconv.FormatInt(at.UnixMilli(), 10),
+	}).Result()
+	if err != nil {
+		redisLogger.Error("Failed to read expired leases", "error", err, "queue", JobLeaseKey)
+		return nil, err
+	}
+
+	var lost []jobs.Job
+	for _, id := range expired {
+		data, err := c.rdb.HGet(ctx, JobPayloadKey, id).Result()
+		if err == redis.Nil {
+			redisLogger.Warn("Dropping lease for job without payload", "job_id", id)
+			c.rdb.ZRem(ctx, JobLeaseKey, id)
+			c.rdb.LRem(ctx, JobProcessingKey, 0, id)
+			continue
+		}
+		if err != nil {
+			redisLogger.Error("Failed to read job payload", "error", err, "job_id", id)
+			continue
+		}
+
+		var job jobs.Job
+		if err := json.Unmarshal([]byte(data), &job); err != nil {
+			redisLogger.Error("Failed to unmarshal leased job", "error", err, "job_id", id)
+			continue
+		}
+		n, err := reapScript.Run(ctx, c.rdb, []string{JobLeaseKey, JobProcessingKey}, id, strconv.FormatInt(at.UnixMilli(), 10)).Int()
+		if err != nil {
+			redisLogger.Error("Failed to reap expired lease", "error", err, "job_id", id)
+			continue
+		}
+		if n == 1 {
+			redisLogger.Warn("Reaped job with expired lease", "job_id", id, "trial", job.JobTrial)
+			lost = append(lost, job)
+		}
+	}
+	return lost, nil
+}
+
+func (c *Client) leaseOrphans(ctx context.Context) error {
+	ids, err := c.rdb.LRange(ctx, JobProcessingKey, 0, -1).Result()
+	if err != nil {
+		redisLogger.Error("Failed to read processing list", "error", err, "queue", JobProcessingKey)
+		return err
+	}
+	expiry := float64(time.Now().Add(LeaseDuration).UnixMilli())
+	_, err = c.rdb.Pipelined(ctx, func(pipe redis.Pipeliner) error {
+		for _, id := range ids {
+			if strings.HasPrefix(id, "{") {
+				continue
+			}
+			pipe.ZAddNX(ctx, JobLeaseKey, redis.Z{Score: expiry, Member: id})
+		}
+		return nil
+	})
+	if err != nil {
+		redisLogger.Error("Failed to lease orphaned jobs", "error", err, "queue", JobLeaseKey)
+	}
+	return err
+}
+
+// StartLeaseReaper checks for expired leases every interval until ctx is
+// done, calling onLost for each job it takes back. onLost must requeue or
+// acknowledge the job.
+func (c *Client) StartLeaseReaper(ctx context.Context, interval time.Duration, onLost func(jobs.Job)) {
+	redisLogger.Info("Starting lease reaper", "queue", JobLeaseKey, "interval", interval, "lease", LeaseDuration)
+	go func() {
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-ctx.Done():
+				redisLogger.Info("Lease reaper shutting down")
+				return
+			case <-ticker.C:
+				lost, err := c.ReapExpiredLeases(ctx, time.Now())
+				if err != nil {
+					continue
+				}
+				for _, job := range lost {
+					onLost(job)
+				}
+			}
+		}
+	}()
+}
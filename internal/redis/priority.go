@@ -0,0 +1,206 @@
+package redis
+
+import (
+	"context"
+	"encoding/json"
+	"strconv"
+	"strings"
+	"time"
+
+	"gpu-runner/internal/jobs"
+
+	"github.com/redis/go-redis/v9"
+)
+
+// PriorityAgingStep is how much waiting one priority level is worth. A job
+// that has waited this long is ordered like a job one level higher that
+// was just enqueued, so low-priority work is delayed but never starved.
+var PriorityAgingStep = 5 * time.Minute
+
+// dequeuePollInterval is how often Dequeue looks for work while the pending
+// queue is empty. Sorted sets have no blocking pop that also moves the
+// entry, so the move is done by a script and polled.
+const dequeuePollInterval = 250 * time.Millisecond
+
+// queueScore orders the pending queue: lowest score is dequeued first.
+func queueScore(priority int, enqueuedAt time.Time) float64 {
+	return float64(enqueuedAt.UnixMilli() - int64(priority)*PriorityAgingStep.Milliseconds())
+}
+
+// dequeueScript pops the best pending job onto the processing list and
+// starts its lease in one step.
+var dequeueScript = redis.NewScript(`
+local ids = redis.call('ZRANGE', KEYS[1], 0, 0)
+if #ids == 0 then
+	return false
+end
+redis.call('ZREM', KEYS[1], ids[1])
+redis.call('LPUSH', KEYS[2], ids[1])
+redis.call('ZADD', KEYS[3], ARGV[1], ids[1])
+return ids[1]
+`)
+
+// nackScript returns a job from processing to the front of the pending
+// queue.
+var nackScript = redis.NewScript(`
+redis.call('LREM', KEYS[2], 0, ARGV[1])
+redis.call('ZREM', KEYS[3], ARGV[1])
+local first = redis.call('ZRANGE', KEYS[1], 0, 0, 'WITHSCORES')
+local score = tonumber(ARGV[2])
+if #first > 0 and tonumber(first[2]) <= score then
+	score = tonumber(first[2]) - 1
+end
+redis.call('ZADD', KEYS[1], score, ARGV[1])
+return 1
+`)
+
+// promoteOneScript moves a job from the delayed set to the pending queue
+// if it is still delayed, so concurrent promoters push it only once.
+var promoteOneScript = redis.NewScript(`
+if redis.call('ZREM', KEYS[1], ARGV[1]) == 0 then
+	return 0
+end
+redis.call('ZADD', KEYS[2], ARGV[2], ARGV[1])
+return 1
+`)
+
+// claimScript moves a job the caller picked from the pending queue to
+// processing and starts its lease, if it is still pending.
+var claimScript = redis.NewScript(`
+if redis.call('ZREM', KEYS[1], ARGV[1]) == 0 then
+	return false
+end
+redis.call('LPUSH', KEYS[2], ARGV[1])
+redis.call('ZADD', KEYS[3], ARGV[2], ARGV[1])
+return ARGV[1]
+`)
+
+// fitScanLimit is how far down the pending queue popPending looks for a
+// job that fits.
+const fitScanLimit = 100
+
+// popPending moves the best pending job fits accepts to processing,
+// polling until there is one or timeout passes. A nil fits accepts any
+// job.
+func (c *Client) popPending(ctx context.Context, timeout time.Duration, fits func(*jobs.Job) bool) (string, error) {
+	deadline := time.Now().Add(timeout)
+	for {
+		var id string
+		var err error
+		if fits == nil {
+			id, err = dequeueScript.Run(ctx, c.rdb,
+				[]string{JobQueueKey, JobProcessingKey, JobLeaseKey},
+				leaseExpiry(time.Now()),
+			).Text()
+		} else {
+			id, err = c.claimFitting(ctx, fits)
+		}
+		if err != redis.Nil {
+			return id, err
+		}
+		wait := min(dequeuePollInterval, time.Until(deadline))
+		if wait <= 0 {
+			return "", redis.Nil
+		}
+		select {
+		case <-ctx.Done():
+			return "", ctx.Err()
+		case <-time.After(wait):
+		}
+	}
+}
+
+// claimFitting claims the best of the first fitScanLimit pending jobs
+// that fits accepts. Jobs passed over keep their place, so a job waiting
+// for busy GPUs stays at the front while smaller ones run around it.
+// Entries without a readable payload are claimed as they are, for Dequeue
+// to deal with.
+func (c *Client) claimFitting(ctx context.Context, fits func(*jobs.Job) bool) (string, error) {
+	ids, err := c.rdb.ZRange(ctx, JobQueueKey, 0, fitScanLimit-1).Result()
+	if err != nil || len(ids) == 0 {
+		if err == nil {
+			err = redis.Nil
+		}
+		return "", err
+	}
+	payloads, err := c.rdb.HMGet(ctx, JobPayloadKey, ids...).Result()
+	if err != nil {
+		return "", err
+	}
+	for i, id := range ids {
+		data, _ := payloads[i].(string)
+		if strings.HasPrefix(id, "{") {
+			data = id
+		}
+		var job jobs.Job
+		if data != "" && json.Unmarshal([]byte(data), &job) == nil && !fits(&job) {
+			continue
+		}
+		claimed, err := claimScript.Run(ctx, c.rdb,
+			[]string{JobQueueKey, JobProcessingKey, JobLeaseKey},
+			id, leaseExpiry(time.Now()),
+		).Text()
+		if err == redis.Nil {
+			// Cancelled or claimed by another server since the ZRANGE.
+			continue
+		}
+		return claimed, err
+	}
+	return "", redis.Nil
+}
+
+// pendingScore reads a job's priority from its payload so entries that
+// only carry an ID can be scored.
+func (c *Client) pendingScore(ctx context.Context, jobID string, at time.Time) float64 {
+	job, err := c.payload(ctx, jobID)
+	if err != nil {
+		return queueScore(0, at)
+	}
+	return queueScore(job.Priority, at)
+}
+
+// migratePendingList converts a pending list left by an older server into
+// the sorted set, keeping its order.
+func (c *Client) migratePendingList(ctx context.Context) error {
+	kind, err := c.rdb.Type(ctx, JobQueueKey).Result()
+	if err != nil || kind != "list" {
+		return err
+	}
+	legacyKey := JobQueueKey + ":legacy"
+	if err := c.rdb.Rename(ctx, JobQueueKey, legacyKey).Err(); err != nil {
+		redisLogger.Error("Failed to move legacy pending list", "error", err, "queue", JobQueueKey)
+		return err
+	}
+	ids, err := c.rdb.LRange(ctx, legacyKey, 0, -1).Result()
+	if err != nil {
+		return err
+	}
+	now := time.Now()
+	// The list was consumed from the tail, so the last element is oldest.
+	for i := len(ids) - 1; i >= 0; i-- {
+		at := now.Add(time.Duration(len(ids)-i) * time.Millisecond)
+		if err := c.rdb.ZAdd(ctx, JobQueueKey, redis.Z{Score: c.pendingScore(ctx, ids[i], at), Member: ids[i]}).Err(); err != nil {
+			redisLogger.Error("Failed to migrate pending job", "error", err, "job_id", ids[i])
+			return err
+		}
+	}
+	redisLogger.Info("Migrated pending list to priority queue", "count", len(ids), "queue", JobQueueKey)
+	return c.rdb.Del(ctx, legacyKey).Err()
+}
+
+func scoreArg(score float64) string {
+	return strconv.FormatFloat(score, 'f', -1, 64)
+}
+
+// payload reads a queued job's stored payload.
+func (c *Client) payload(ctx context.Context, jobID string) (*jobs.Job, error) {
+	data, err := c.rdb.HGet(ctx, JobPayloadKey, jobID).Result()
+	if err != nil {
+		return nil, err
+	}
+	var job jobs.Job
+	if err := json.Unmarshal([]byte(data), &job); err != nil {
+		return nil, err
+	}
+	return &job, nil
+}
@@ -3,62 +3,97 @@ package redis
 import (
 	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
+	"strings"
 	"time"
+	"gpu-runner/internal/gpu"
 	"gpu-runner/internal/jobs"
 	"gpu-runner/internal/logger"
+
+	"github.com/redis/go-redis/v9"
 )
 
 var redisLogger = logger.Server
 
 const (
+	// JobQueueKey is a sorted set of job IDs waiting to run, scored by
+	// queueScore so higher priority and longer waits come first.
 	JobQueueKey      = "gpu-runner:jobs:pending"
 	JobProcessingKey = "gpu-runner:jobs:processing"
+	// JobPayloadKey is a hash of job ID to the job's JSON. The pending,
+	// processing and delayed structures hold only IDs, so entries can be
+	// acknowledged by ID whatever has changed in the job since.
+	JobPayloadKey = "gpu-runner:jobs:payloads"
 )
 
-// JobMessage represents a job in the Redis queue
-
-// Enqueue adds a job to the pending queue
+// Enqueue stores the job's payload and adds its ID to the pending queue
 func (c *Client) Enqueue(ctx context.Context, job jobs.Job) error {
-	data, err := json.Marshal(job)
+	data, err := marshalJob(job)
 	if err != nil {
-		if job.Logger != nil {
-			job.Logger.Error("Failed to marshal job for Redis queue", logger.Item("error", err))
-		}
-		redisLogger.Error("Failed to marshal job", "error", err, "job_id", job.ID)
-		return fmt.Errorf("failed to marshal job: %w", err)
+		return err
 	}
 
-	if err := c.rdb.LPush(ctx, JobQueueKey, data).Err(); err != nil {
+	_, err = c.rdb.TxPipelined(ctx, func(pipe redis.Pipeliner) error {
+		pipe.HSet(ctx, JobPayloadKey, job.ID, data)
+		pipe.ZAdd(ctx, JobQueueKey, redis.Z{Score: queueScore(job.Priority, time.Now()), Member: job.ID})
+		return nil
+	})
+	if err != nil {
 		if job.Logger != nil {
 			job.Logger.Error("Failed to enqueue job to Redis", logger.Item("error", err))
 		}
-		redisLogger.Error("Redis LPush failed", "error", err, "job_id", job.ID, "queue", JobQueueKey)
+		redisLogger.Error("Redis ZAdd failed", "error", err, "job_id", job.ID, "queue", JobQueueKey)
 		return fmt.Errorf("failed to enqueue job: %w", err)
 	}
 
 	if job.Logger != nil {
 		job.Logger.Info("Job enqueued to Redis queue")
 	}
-	redisLogger.Info("Job enqueued successfully", "job_id", job.ID, "queue", JobQueueKey)
+	redisLogger.Info("Job enqueued successfully", "job_id", job.ID, "queue", JobQueueKey, "priority", job.Priority)
 	return nil
 }
 
 // Dequeue blocks until a job is available, then returns it
-// Uses BRPOPLPUSH for reliable queue processing (atomic move to processing list)
+// The job is moved to the processing list and leased in one step
 func (c *Client) Dequeue(ctx context.Context, timeout time.Duration) (*jobs.Job, error) {
-	result, err := c.rdb.BRPopLPush(ctx, JobQueueKey, JobProcessingKey, timeout).Result()
+	return c.DequeueFitting(ctx, timeout, nil)
+}
+
+// DequeueFitting is Dequeue for a consumer that can't run every job right
+// now: it takes the best pending job fits accepts and leaves the others
+// where they are. A nil fits accepts any job.
+func (c *Client) DequeueFitting(ctx context.Context, timeout time.Duration, fits func(*jobs.Job) bool) (*jobs.Job, error) {
+	id, err := c.popPending(ctx, timeout, fits)
 	if err != nil {
 		// Don't log timeout errors as they're expected during normal operation
-		if err.Error() != "redis: nil" {
+		if err != redis.Nil {
 			redisLogger.Error("Redis dequeue failed", "error", err, "queue", JobQueueKey)
 		}
 		return nil, fmt.Errorf("failed to dequeue job: %w", err)
 	}
 
+	if strings.HasPrefix(id, "{") {
+		return c.adoptLegacyEntry(ctx, id)
+	}
+
+	data, err := c.rdb.HGet(ctx, JobPayloadKey, id).Result()
+	if err == redis.Nil {
+		// The payload was dropped (for example the job was acknowledged
+		// twice); there is nothing left to run.
+		redisLogger.Warn("Dropping queue entry without payload", "job_id", id)
+		c.rdb.LRem(ctx, JobProcessingKey, 1, id)
+		c.rdb.ZRem(ctx, JobLeaseKey, id)
+		return nil, fmt.Errorf("no payload for job %s", id)
+	}
+	if err != nil {
+		redisLogger.Error("Failed to read job payload", "error", err, "job_id", id)
+		return nil, fmt.Errorf("failed to read job payload: %w", err)
+	}
+
 	var job jobs.Job
-	if err := json.Unmarshal([]byte(result), &job); err != nil {
-		redisLogger.Error("Failed to unmarshal dequeued job", "error", err)
+	if err := json.Unmarshal([]byte(data), &job); err != nil {
+		redisLogger.Error("Failed to unmarshal dequeued job", "error", err, "job_id", id)
 		return nil, fmt.Errorf("failed to unmarshal job: %w", err)
 	}
 
@@ -66,68 +101,140 @@ func (c *Client) Dequeue(ctx context.Context, timeout time.Duration) (*jobs.Job,
 	return &job, nil
 }
 
-// Acknowledge removes a completed job from the processing list
-func (c *Client) Acknowledge(ctx context.Context, job jobs.Job) error {
-	data, err := json.Marshal(job)
+// adoptLegacyEntry converts a whole-JSON entry left by an older server into
+// an ID entry so it can be acknowledged like any other.
+func (c *Client) adoptLegacyEntry(ctx context.Context, raw string) (*jobs.Job, error) {
+	var job jobs.Job
+	if err := json.Unmarshal([]byte(raw), &job); err != nil {
+		redisLogger.Error("Dropping undecodable legacy queue entry", "error", err)
+		c.rdb.LRem(ctx, JobProcessingKey, 1, raw)
+		c.rdb.ZRem(ctx, JobLeaseKey, raw)
+		return nil, fmt.Errorf("failed to unmarshal job: %w", err)
+	}
+	_, err := c.rdb.TxPipelined(ctx, func(pipe redis.Pipeliner) error {
+		pipe.HSet(ctx, JobPayloadKey, job.ID, raw)
+		pipe.LRem(ctx, JobProcessingKey, 1, raw)
+		pipe.LPush(ctx, JobProcessingKey, job.ID)
+		pipe.ZRem(ctx, JobLeaseKey, raw)
+		pipe.ZAdd(ctx, JobLeaseKey, redis.Z{Score: float64(time.Now().Add(LeaseDuration).UnixMilli()), Member: job.ID})
+		return nil
+	})
 	if err != nil {
-		if job.Logger != nil {
-			job.Logger.Error("Failed to marshal job for acknowledgment", logger.Item("error", err))
-		}
-		redisLogger.Error("Failed to marshal job for acknowledgment", "error", err, "job_id", job.ID)
-		return fmt.Errorf("failed to marshal job: %w", err)
+		redisLogger.Error("Failed to convert legacy queue entry", "error", err, "job_id", job.ID)
+		return nil, fmt.Errorf("failed to convert legacy entry: %w", err)
 	}
+	redisLogger.Info("Converted legacy queue entry", "job_id", job.ID)
+	return &job, nil
+}
 
-	if err := c.rdb.LRem(ctx, JobProcessingKey, 1, data).Err(); err != nil {
-		if job.Logger != nil {
-			job.Logger.Error("Failed to acknowledge job in Redis", logger.Item("error", err))
-		}
-		redisLogger.Error("Redis LRem failed during acknowledgment", "error", err, "job_id", job.ID, "queue", JobProcessingKey)
+// Ack removes a finished job from the processing list and drops its payload
+func (c *Client) Ack(ctx context.Context, jobID string) error {
+	_, err := c.rdb.TxPipelined(ctx, func(pipe redis.Pipeliner) error {
+		pipe.LRem(ctx, JobProcessingKey, 0, jobID)
+		pipe.ZRem(ctx, JobLeaseKey, jobID)
+		pipe.HDel(ctx, JobPayloadKey, jobID)
+		return nil
+	})
+	if err != nil {
+		redisLogger.Error("Redis LRem failed during acknowledgment", "error", err, "job_id", jobID, "queue", JobProcessingKey)
 		return fmt.Errorf("failed to acknowledge job: %w", err)
 	}
 
-	if job.Logger != nil {
-		job.Logger.Info("Job acknowledged in Redis")
-	}
-	redisLogger.Info("Job acknowledged and removed from processing queue", "job_id", job.ID, "status", job.Status)
+	redisLogger.Info("Job acknowledged and removed from processing queue", "job_id", jobID)
 	return nil
 }
 
-// QueueLength returns the number of pending jobs
-func (c *Client) QueueLength(ctx context.Context) (int64, error) {
-	length, err := c.rdb.LLen(ctx, JobQueueKey).Result()
+// Nack hands a dequeued job back to the pending queue untouched, ahead of
+// the jobs already waiting
+func (c *Client) Nack(ctx context.Context, jobID string) error {
+	score := c.pendingScore(ctx, jobID, time.Now())
+	err := nackScript.Run(ctx, c.rdb,
+		[]string{JobQueueKey, JobProcessingKey, JobLeaseKey},
+		jobID, scoreArg(score),
+	).Err()
 	if err != nil {
-		redisLogger.Error("Failed to get queue length", "error", err, "queue", JobQueueKey)
-		return 0, err
+		redisLogger.Error("Redis nack failed", "error", err, "job_id", jobID, "queue", JobQueueKey)
+		return fmt.Errorf("failed to nack job: %w", err)
 	}
-	return length, nil
+
+	redisLogger.Info("Job returned to pending queue", "job_id", jobID)
+	return nil
 }
 
+// Requeue stores the job's updated payload and moves it from processing
+// back to pending, or to the delayed set when at is in the future
+func (c *Client) Requeue(ctx context.Context, job jobs.Job, at time.Time) error {
+	data, err := marshalJob(job)
+	if err != nil {
+		return err
+	}
 
-// RequeueStaleJobs moves jobs from processing back to pending (for crash recovery)
-func (c *Client) RequeueStaleJobs(ctx context.Context) (int64, error) {
-	redisLogger.Info("Starting stale job requeue process", "processing_queue", JobProcessingKey)
-	var count int64
-	for {
-		result, err := c.rdb.RPopLPush(ctx, JobProcessingKey, JobQueueKey).Result()
-		if err != nil {
-			break // No more items
+	delayed := at.After(time.Now())
+	_, err = c.rdb.TxPipelined(ctx, func(pipe redis.Pipeliner) error {
+		pipe.HSet(ctx, JobPayloadKey, job.ID, data)
+		pipe.LRem(ctx, JobProcessingKey, 0, job.ID)
+		pipe.ZRem(ctx, JobLeaseKey, job.ID)
+		if delayed {
+			pipe.ZAdd(ctx, JobDelayedKey, redis.Z{Score: float64(at.UnixMilli()), Member: job.ID})
+		} else {
+			pipe.ZAdd(ctx, JobQueueKey, redis.Z{Score: queueScore(job.Priority, time.Now()), Member: job.ID})
 		}
-		if result == "" {
-			break
+		return nil
+	})
+	if err != nil {
+		if job.Logger != nil {
+			job.Logger.Error("Failed to requeue job in Redis", logger.Item("error", err))
 		}
-		count++
-		redisLogger.Info("Requeued stale job", "count", count)
+		redisLogger.Error("Redis requeue failed", "error", err, "job_id", job.ID)
+		return fmt.Errorf("failed to requeue job: %w", err)
 	}
-	if count > 0 {
-		redisLogger.Info("Stale job requeue completed", "total_requeued", count)
-	} else {
-		redisLogger.Info("No stale jobs found to requeue")
+
+	redisLogger.Info("Job requeued", "job_id", job.ID, "run_at", at, "delayed", delayed)
+	return nil
+}
+
+// marshalJob encodes the queue payload. The logger is rebuilt on dequeue,
+// so it is left out.
+func marshalJob(job jobs.Job) ([]byte, error) {
+	jobLogger := job.Logger
+	job.Logger = nil
+	data, err := json.Marshal(job)
+	if err != nil {
+		if jobLogger != nil {
+			jobLogger.Error("Failed to marshal job for Redis queue", logger.Item("error", err))
+		}
+		redisLogger.Error("Failed to marshal job", "error", err, "job_id", job.ID)
+		return nil, fmt.Errorf("failed to marshal job: %w", err)
+	}
+	return data, nil
+}
+
+// QueueLength returns the number of pending jobs
+func (c *Client) QueueLength(ctx context.Context) (int64, error) {
+	length, err := c.rdb.ZCard(ctx, JobQueueKey).Result()
+	if err != nil {
+		redisLogger.Error("Failed to get queue length", "error", err, "queue", JobQueueKey)
+		return 0, err
 	}
-	return count, nil
+	return length, nil
 }
 
+
+// StartRedisAdapter feeds pending jobs to the workers. When the server has
+// GPUs, a job is only taken from the queue once the devices it needs are
+// free, so jobs waiting for GPUs don't hold worker slots. The devices are
+// allocated as the job is handed off; since jobQueue.Queue is unbuffered,
+// only the one job waiting for a free worker holds GPUs before it runs,
+// and no other job could be dequeued meanwhile anyway.
 func (c *Client) StartRedisAdapter(ctx context.Context, jobQueue *jobs.JobQueue, sink *StreamSink) error {
 	redisLogger.Info("Starting Redis adapter", "queue", JobQueueKey)
+	if err := c.migratePendingList(ctx); err != nil {
+		return fmt.Errorf("failed to migrate pending queue: %w", err)
+	}
+	var fits func(*jobs.Job) bool
+	if jobQueue.GPUs != nil {
+		fits = jobQueue.Fits
+	}
 	go func() {
 		defer func() {
 			redisLogger.Info("Redis adapter shutting down, closing job queue")
@@ -138,27 +245,65 @@ func (c *Client) StartRedisAdapter(ctx context.Context, jobQueue *jobs.JobQueue,
 			case <-ctx.Done():
 				return
 			default:
-				job, err := c.Dequeue(ctx, 5*time.Second)
+				job, err := c.DequeueFitting(ctx, 5*time.Second, fits)
 				if err != nil {
 					continue
 				}
 				// Recreate the logger after deserialization (Logger can't be serialized to JSON)
 				job.Logger = logger.NewJobLogger(ctx, job.ID, sink)
-				redisLogger.Info("Passing job to worker queue", "job_id", job.ID)
+				if err := jobQueue.AssignGPUs(job); errors.Is(err, gpu.ErrBusy) {
+					redisLogger.Warn("GPUs taken before the job could claim them", "job_id", job.ID, "error", err)
+					if err := c.Nack(context.Background(), job.ID); err != nil {
+						redisLogger.Error("Failed to return job to pending queue", "error", err, "job_id", job.ID)
+					}
+					continue
+				} else if err != nil {
+					// The worker fails the job with this error.
+					redisLogger.Warn("Job's GPUs cannot be allocated", "job_id", job.ID, "error", err)
+				}
+				redisLogger.Info("Passing job to worker queue", "job_id", job.ID, "gpus", job.GPUDevices)
 
-				select {
-				case <-ctx.Done():
+				if err := c.handOff(ctx, jobQueue.Queue, job); errors.Is(err, jobs.ErrLeaseLost) {
+					// The reaper took the job back and settles it.
+					redisLogger.Warn("Lease lost while job waited for a worker", "job_id", job.ID)
+					jobQueue.ReleaseGPUs(job)
+					continue
+				} else if err != nil {
 					redisLogger.Warn("Context cancelled while sending job to queue", "job_id", job.ID)
+					jobQueue.ReleaseGPUs(job)
+					if err := c.Nack(context.Background(), job.ID); err != nil {
+						redisLogger.Error("Failed to return job to pending queue", "error", err, "job_id", job.ID)
+					}
 					return
-				case jobQueue.Queue <- job:
-					redisLogger.Info("Job sent to worker queue successfully", "job_id", job.ID)
-				}
 				}
+				redisLogger.Info("Job sent to worker queue successfully", "job_id", job.ID)
+			}
 		}
 	}()
 	return nil
 }
 
+// handOff waits for a worker to take job, extending its lease meanwhile:
+// the lease started at dequeue and the worker's heartbeat only takes over
+// once it has the job. It returns ctx's error when ctx is done first, and
+// jobs.ErrLeaseLost if the lease ran out anyway.
+func (c *Client) handOff(ctx context.Context, queue chan<- *jobs.Job, job *jobs.Job) error {
+	ticker := time.NewTicker(jobs.HeartbeatInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case queue <- job:
+			return nil
+		case <-ticker.C:
+			if err := c.ExtendLease(ctx, job.ID); errors.Is(err, jobs.ErrLeaseLost) {
+				return err
+			}
+		}
+	}
+}
+
 
 
 		
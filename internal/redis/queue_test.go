@@ -0,0 +1,441 @@
+package redis
+
+import (
+	"context"
+	"encoding/json"
+	"testing"
+	"time"
+
+	"gpu-runner/internal/jobs"
+
+	"github.com/alicebob/miniredis/v2"
+	"github.com/redis/go-redis/v9"
+)
+
+// newTestClient returns a Client on a fresh miniredis, which runs the
+// queue's Lua scripts too.
+func newTestClient(t *testing.T) (*Client, *miniredis.Miniredis) {
+	t.Helper()
+	mr := miniredis.RunT(t)
+	rdb := redis.NewClient(&redis.Options{Addr: mr.Addr()})
+	t.Cleanup(func() { rdb.Close() })
+	return &Client{rdb: rdb}, mr
+}
+
+func enqueue(t *testing.T, c *Client, job jobs.Job) {
+	t.Helper()
+	if err := c.Enqueue(context.Background(), job); err != nil {
+		t.Fatalf("Enqueue(%s): %v", job.ID, err)
+	}
+}
+
+func dequeue(t *testing.T, c *Client) *jobs.Job {
+	t.Helper()
+	job, err := c.Dequeue(context.Background(), time.Second)
+	if err != nil {
+		t.Fatalf("Dequeue: %v", err)
+	}
+	return job
+}
+
+func pendingIDs(t *testing.T, c *Client) []string {
+	t.Helper()
+	ids, err := c.rdb.ZRange(context.Background(), JobQueueKey, 0, -1).Result()
+	if err != nil {
+		t.Fatalf("read pending queue: %v", err)
+	}
+	return ids
+}
+
+func processingIDs(t *testing.T, c *Client) []string {
+	t.Helper()
+	ids, err := c.rdb.LRange(context.Background(), JobProcessingKey, 0, -1).Result()
+	if err != nil {
+		t.Fatalf("read processing list: %v", err)
+	}
+	return ids
+}
+
+func hasLease(t *testing.T, c *Client, id string) bool {
+	t.Helper()
+	err := c.rdb.ZScore(context.Background(), JobLeaseKey, id).Err()
+	if err != nil && err != redis.Nil {
+		t.Fatalf("read lease of %s: %v", id, err)
+	}
+	return err == nil
+}
+
+func hasPayload(t *testing.T, c *Client, id string) bool {
+	t.Helper()
+	n, err := c.rdb.HExists(context.Background(), JobPayloadKey, id).Result()
+	if err != nil {
+		t.Fatalf("read payload of %s: %v", id, err)
+	}
+	return n
+}
+
+func equalIDs(got, want []string) bool {
+	if len(got) != len(want) {
+		return false
+	}
+	for i := range got {
+		if got[i] != want[i] {
+			return false
+		}
+	}
+	return true
+}
+
+func TestDequeueTakesHighestPriorityAndLeasesIt(t *testing.T) {
+	c, _ := newTestClient(t)
+	enqueue(t, c, jobs.Job{ID: "1", Command: "low"})
+	enqueue(t, c, jobs.Job{ID: "2", Command: "high", Priority: 10})
+
+	job := dequeue(t, c)
+	if job.ID != "2" {
+		t.Fatalf("dequeued job %s, want the high-priority job 2", job.ID)
+	}
+	if got := processingIDs(t, c); !equalIDs(got, []string{"2"}) {
+		t.Errorf("processing = %v, want [2]", got)
+	}
+	if !hasLease(t, c, "2") {
+		t.Error("dequeued job has no lease")
+	}
+	if got := pendingIDs(t, c); !equalIDs(got, []string{"1"}) {
+		t.Errorf("pending = %v, want [1]", got)
+	}
+}
+
+func TestDequeueTimesOutOnEmptyQueue(t *testing.T) {
+	c, _ := newTestClient(t)
+	if _, err := c.Dequeue(context.Background(), 300*time.Millisecond); err == nil {
+		t.Fatal("Dequeue on an empty queue succeeded")
+	}
+}
+
+func TestAckRemovesJobByID(t *testing.T) {
+	c, _ := newTestClient(t)
+	enqueue(t, c, jobs.Job{ID: "1", Command: "true"})
+	job := dequeue(t, c)
+
+	// The worker changes the job before reporting it; Ack only needs the ID.
+	job.Status = jobs.StatusSuccess
+	job.WorkerID = "host-1"
+	if err := c.Ack(context.Background(), job.ID); err != nil {
+		t.Fatalf("Ack: %v", err)
+	}
+	if got := processingIDs(t, c); len(got) != 0 {
+		t.Errorf("processing = %v after Ack, want empty", got)
+	}
+	if hasLease(t, c, "1") || hasPayload(t, c, "1") {
+		t.Error("Ack left the job's lease or payload behind")
+	}
+}
+
+func TestNackPutsJobAheadOfWaitingJobs(t *testing.T) {
+	c, _ := newTestClient(t)
+	enqueue(t, c, jobs.Job{ID: "1", Command: "first"})
+	enqueue(t, c, jobs.Job{ID: "2", Command: "second", Priority: 5})
+	job := dequeue(t, c)
+	if job.ID != "2" {
+		t.Fatalf("dequeued job %s, want 2", job.ID)
+	}
+
+	if err := c.Nack(context.Background(), job.ID); err != nil {
+		t.Fatalf("Nack: %v", err)
+	}
+	if got := pendingIDs(t, c); !equalIDs(got, []string{"2", "1"}) {
+		t.Errorf("pending = %v after Nack, want [2 1]", got)
+	}
+	if len(processingIDs(t, c)) != 0 || hasLease(t, c, "2") {
+		t.Error("Nack left the job in processing or leased")
+	}
+	if !hasPayload(t, c, "2") {
+		t.Error("Nack dropped the job's payload")
+	}
+}
+
+func TestRemoveOnlyTakesWaitingJobs(t *testing.T) {
+	c, _ := newTestClient(t)
+	ctx := context.Background()
+	enqueue(t, c, jobs.Job{ID: "1", Command: "pending"})
+	if err := c.EnqueueAt(ctx, jobs.Job{ID: "2", Command: "delayed"}, time.Now().Add(time.Hour)); err != nil {
+		t.Fatalf("EnqueueAt: %v", err)
+	}
+	enqueue(t, c, jobs.Job{ID: "3", Command: "running", Priority: 100})
+	dequeue(t, c)
+
+	for _, tc := range []struct {
+		id      string
+		removed bool
+	}{{"1", true}, {"2", true}, {"3", false}, {"4", false}} {
+		removed, err := c.Remove(ctx, tc.id)
+		if err != nil {
+			t.Fatalf("Remove(%s): %v", tc.id, err)
+		}
+		if removed != tc.removed {
+			t.Errorf("Remove(%s) = %v, want %v", tc.id, removed, tc.removed)
+		}
+	}
+	if hasPayload(t, c, "1") || hasPayload(t, c, "2") {
+		t.Error("Remove left a removed job's payload behind")
+	}
+	if !hasPayload(t, c, "3") || !equalIDs(processingIDs(t, c), []string{"3"}) {
+		t.Error("Remove touched a job that was already dequeued")
+	}
+}
+
+func TestDequeueAdoptsLegacyEntry(t *testing.T) {
+	c, _ := newTestClient(t)
+	ctx := context.Background()
+	raw, err := json.Marshal(jobs.Job{ID: "7", Command: "legacy"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := c.rdb.ZAdd(ctx, JobQueueKey, redis.Z{Score: 1, Member: string(raw)}).Err(); err != nil {
+		t.Fatal(err)
+	}
+
+	job := dequeue(t, c)
+	if job.ID != "7" || job.Command != "legacy" {
+		t.Fatalf("dequeued %+v, want the legacy job 7", job)
+	}
+	if got := processingIDs(t, c); !equalIDs(got, []string{"7"}) {
+		t.Errorf("processing = %v, want the legacy entry replaced by its ID", got)
+	}
+	if !hasPayload(t, c, "7") || !hasLease(t, c, "7") {
+		t.Error("adopted entry has no payload or lease")
+	}
+	if hasLease(t, c, string(raw)) {
+		t.Error("lease on the raw legacy entry was left behind")
+	}
+	if err := c.Ack(ctx, "7"); err != nil {
+		t.Fatalf("Ack: %v", err)
+	}
+	if len(processingIDs(t, c)) != 0 {
+		t.Error("adopted entry could not be acknowledged by ID")
+	}
+}
+
+func TestMigratePendingListKeepsOrder(t *testing.T) {
+	c, _ := newTestClient(t)
+	ctx := context.Background()
+	for _, id := range []string{"1", "2", "3"} {
+		enqueue(t, c, jobs.Job{ID: id, Command: "x"})
+	}
+	if err := c.rdb.Del(ctx, JobQueueKey).Err(); err != nil {
+		t.Fatal(err)
+	}
+	// Older servers LPUSHed and consumed from the tail, so 1 is oldest.
+	if err := c.rdb.LPush(ctx, JobQueueKey, "1", "2", "3").Err(); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := c.migratePendingList(ctx); err != nil {
+		t.Fatalf("migratePendingList: %v", err)
+	}
+	if kind := c.rdb.Type(ctx, JobQueueKey).Val(); kind != "zset" {
+		t.Fatalf("pending queue is a %s after migration, want zset", kind)
+	}
+	if got := pendingIDs(t, c); !equalIDs(got, []string{"1", "2", "3"}) {
+		t.Errorf("pending = %v after migration, want [1 2 3]", got)
+	}
+	if c.rdb.Exists(ctx, JobQueueKey+":legacy").Val() != 0 {
+		t.Error("legacy list was not deleted")
+	}
+
+	// A sorted set is left as it is.
+	if err := c.migratePendingList(ctx); err != nil {
+		t.Fatalf("second migratePendingList: %v", err)
+	}
+	if got := pendingIDs(t, c); !equalIDs(got, []string{"1", "2", "3"}) {
+		t.Errorf("pending = %v after a second migration, want [1 2 3]", got)
+	}
+}
+
+func TestDequeueFittingSkipsJobsThatDontFit(t *testing.T) {
+	c, _ := newTestClient(t)
+	enqueue(t, c, jobs.Job{ID: "1", Command: "big", Priority: 10})
+	enqueue(t, c, jobs.Job{ID: "2", Command: "small"})
+	fits := func(job *jobs.Job) bool { return job.Command != "big" }
+
+	job, err := c.DequeueFitting(context.Background(), time.Second, fits)
+	if err != nil {
+		t.Fatalf("DequeueFitting: %v", err)
+	}
+	if job.ID != "2" {
+		t.Fatalf("dequeued job %s, want 2", job.ID)
+	}
+	if got := pendingIDs(t, c); !equalIDs(got, []string{"1"}) {
+		t.Errorf("pending = %v, want the job that didn't fit to keep its place", got)
+	}
+	if !hasLease(t, c, "2") {
+		t.Error("claimed job has no lease")
+	}
+
+	if _, err := c.DequeueFitting(context.Background(), 300*time.Millisecond, fits); err == nil {
+		t.Error("DequeueFitting returned a job although none fits")
+	}
+}
+
+func TestDequeueFittingClaimsEntriesWithoutPayload(t *testing.T) {
+	c, _ := newTestClient(t)
+	ctx := context.Background()
+	if err := c.rdb.ZAdd(ctx, JobQueueKey, redis.Z{Score: 1, Member: "9"}).Err(); err != nil {
+		t.Fatal(err)
+	}
+	enqueue(t, c, jobs.Job{ID: "2", Command: "x"})
+	fits := func(*jobs.Job) bool { return true }
+
+	// The orphaned entry is claimed and dropped rather than blocking the
+	// queue.
+	if _, err := c.DequeueFitting(ctx, time.Second, fits); err == nil {
+		t.Fatal("DequeueFitting returned a job for an entry without payload")
+	}
+	if len(processingIDs(t, c)) != 0 || hasLease(t, c, "9") {
+		t.Error("entry without payload was left in processing")
+	}
+	job, err := c.DequeueFitting(ctx, time.Second, fits)
+	if err != nil || job.ID != "2" {
+		t.Fatalf("DequeueFitting = %v, %v; want job 2", job, err)
+	}
+}
+
+func TestClaimScriptOnlyClaimsPendingJobs(t *testing.T) {
+	c, _ := newTestClient(t)
+	ctx := context.Background()
+	enqueue(t, c, jobs.Job{ID: "1", Command: "x"})
+	keys := []string{JobQueueKey, JobProcessingKey, JobLeaseKey}
+
+	id, err := claimScript.Run(ctx, c.rdb, keys, "1", leaseExpiry(time.Now())).Text()
+	if err != nil || id != "1" {
+		t.Fatalf("claim = %q, %v; want 1", id, err)
+	}
+	if _, err := claimScript.Run(ctx, c.rdb, keys, "1", leaseExpiry(time.Now())).Text(); err != redis.Nil {
+		t.Fatalf("second claim error = %v, want redis.Nil", err)
+	}
+	if got := processingIDs(t, c); !equalIDs(got, []string{"1"}) {
+		t.Errorf("processing = %v, want the job claimed once", got)
+	}
+}
+
+func TestReapExpiredLeasesTakesBackLostJob(t *testing.T) {
+	c, _ := newTestClient(t)
+	ctx := context.Background()
+	enqueue(t, c, jobs.Job{ID: "1", Command: "x", JobTrial: 1})
+	dequeue(t, c)
+	if err := c.ExtendLease(ctx, "1"); err != nil {
+		t.Fatalf("ExtendLease: %v", err)
+	}
+
+	if lost, err := c.ReapExpiredLeases(ctx, time.Now()); err != nil || len(lost) != 0 {
+		t.Fatalf("ReapExpiredLeases before expiry = %+v, %v; want nothing", lost, err)
+	}
+	lost, err := c.ReapExpiredLeases(ctx, time.Now().Add(2*LeaseDuration))
+	if err != nil {
+		t.Fatalf("ReapExpiredLeases: %v", err)
+	}
+	if len(lost) != 1 || lost[0].ID != "1" || lost[0].JobTrial != 1 {
+		t.Fatalf("lost = %+v, want job 1 as it was on trial 1", lost)
+	}
+	// Whether the job runs again is up to its retry policy, so the reaper
+	// leaves it in processing for the caller to settle.
+	if got := pendingIDs(t, c); len(got) != 0 {
+		t.Errorf("pending = %v, want the lost job not requeued by the reaper", got)
+	}
+	if got := processingIDs(t, c); !equalIDs(got, []string{"1"}) {
+		t.Errorf("processing = %v, want the lost job kept until it is settled", got)
+	}
+	if err := c.ExtendLease(ctx, "1"); err != jobs.ErrLeaseLost {
+		t.Errorf("ExtendLease after reaping = %v, want ErrLeaseLost", err)
+	}
+
+	// Left unsettled, as after a crash, it is leased again and expires in
+	// its turn.
+	if again, err := c.ReapExpiredLeases(ctx, time.Now()); err != nil || len(again) != 0 {
+		t.Errorf("second ReapExpiredLeases = %+v, %v; want nothing before the new lease expires", again, err)
+	}
+	if !hasLease(t, c, "1") {
+		t.Error("unsettled lost job was not leased again")
+	}
+}
+
+func TestReapExpiredLeasesSkipsJobsNotInProcessing(t *testing.T) {
+	c, _ := newTestClient(t)
+	ctx := context.Background()
+	enqueue(t, c, jobs.Job{ID: "1", Command: "x", JobTrial: 1})
+	// A lease left behind on a job that is waiting in the queue again.
+	if err := c.rdb.ZAdd(ctx, JobLeaseKey, redis.Z{Score: 0, Member: "1"}).Err(); err != nil {
+		t.Fatal(err)
+	}
+
+	lost, err := c.ReapExpiredLeases(ctx, time.Now())
+	if err != nil || len(lost) != 0 {
+		t.Fatalf("ReapExpiredLeases = %+v, %v; want nothing reaped", lost, err)
+	}
+	if hasLease(t, c, "1") {
+		t.Error("stray lease was not removed")
+	}
+	if got := pendingIDs(t, c); !equalIDs(got, []string{"1"}) {
+		t.Errorf("pending = %v, want the job left waiting", got)
+	}
+}
+
+func TestHandOffKeepsLeaseUntilWorkerTakesJob(t *testing.T) {
+	c, _ := newTestClient(t)
+	ctx := context.Background()
+	oldLease, oldHeartbeat := LeaseDuration, jobs.HeartbeatInterval
+	LeaseDuration, jobs.HeartbeatInterval = 200*time.Millisecond, 20*time.Millisecond
+	t.Cleanup(func() { LeaseDuration, jobs.HeartbeatInterval = oldLease, oldHeartbeat })
+	enqueue(t, c, jobs.Job{ID: "1", Command: "x", JobTrial: 1})
+	job := dequeue(t, c)
+
+	queue := make(chan *jobs.Job)
+	done := make(chan error, 1)
+	go func() { done <- c.handOff(ctx, queue, job) }()
+	time.Sleep(3 * LeaseDuration)
+	if lost, err := c.ReapExpiredLeases(ctx, time.Now()); err != nil || len(lost) != 0 {
+		t.Fatalf("ReapExpiredLeases = %+v, %v; want the waiting job's lease kept alive", lost, err)
+	}
+	if got := <-queue; got.ID != "1" {
+		t.Errorf("worker got %+v, want job 1", got)
+	}
+	if err := <-done; err != nil {
+		t.Errorf("handOff = %v, want nil once a worker took the job", err)
+	}
+	if err := c.Ack(ctx, "1"); err != nil {
+		t.Fatalf("Ack: %v", err)
+	}
+
+	enqueue(t, c, jobs.Job{ID: "2", Command: "x", JobTrial: 1})
+	job = dequeue(t, c)
+	if lost, err := c.ReapExpiredLeases(ctx, time.Now().Add(LeaseDuration)); err != nil || len(lost) != 1 {
+		t.Fatalf("ReapExpiredLeases = %+v, %v; want job 2 reaped", lost, err)
+	}
+	if err := c.handOff(ctx, queue, job); err != jobs.ErrLeaseLost {
+		t.Errorf("handOff of a reaped job = %v, want ErrLeaseLost", err)
+	}
+}
+
+func TestPromoteDueJobs(t *testing.T) {
+	c, _ := newTestClient(t)
+	ctx := context.Background()
+	now := time.Now()
+	if err := c.EnqueueAt(ctx, jobs.Job{ID: "1", Command: "due"}, now.Add(time.Minute)); err != nil {
+		t.Fatal(err)
+	}
+	if err := c.EnqueueAt(ctx, jobs.Job{ID: "2", Command: "later"}, now.Add(time.Hour)); err != nil {
+		t.Fatal(err)
+	}
+
+	promoted, err := c.PromoteDueJobs(ctx, now.Add(2*time.Minute))
+	if err != nil {
+		t.Fatalf("PromoteDueJobs: %v", err)
+	}
+	if !equalIDs(promoted, []string{"1"}) {
+		t.Errorf("promoted = %v, want [1]", promoted)
+	}
+	if got := pendingIDs(t, c); !equalIDs(got, []string{"1"}) {
+		t.Errorf("pending = %v, want [1]", got)
+	}
+}
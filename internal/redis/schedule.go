@@ -0,0 +1,27 @@
+package redis
+
+import (
+	"context"
+	"fmt"
+	"time"
+)
+
+// ScheduleClaimPrefix namespaces the keys servers use to agree on which
+// of them fires a schedule at a given time.
+const ScheduleClaimPrefix = "gpu-runner:schedules:fired:"
+
+// scheduleClaimTTL keeps a claim long enough that a server running
+// behind can't fire the same run again.
+const scheduleClaimTTL = 24 * time.Hour
+
+// ClaimScheduleRun reports whether this server is the first to claim the
+// run of scheduleID due at at. Only the server that gets true may fire it.
+func (c *Client) ClaimScheduleRun(ctx context.Context, scheduleID string, at time.Time) (bool, error) {
+	key := fmt.Sprintf("%s%s:%d", ScheduleClaimPrefix, scheduleID, at.Unix())
+	ok, err := c.rdb.SetNX(ctx, key, time.Now().UnixMilli(), scheduleClaimTTL).Result()
+	if err != nil {
+		redisLogger.Error("Failed to claim schedule run", "error", err, "schedule_id", scheduleID, "run_at", at)
+		return false, fmt.Errorf("failed to claim schedule run: %w", err)
+	}
+	return ok, nil
+}
@@ -3,6 +3,7 @@ package redis
 import (
 	"context"
 	"fmt"
+	"math"
 	"time"
 
 	"github.com/redis/go-redis/v9"
@@ -50,10 +51,49 @@ func (s *StreamSink) Append(ctx context.Context, jobID string, message string) e
 	return nil
 }
 
+// StreamEntry is a raw log message together with its Redis stream ID.
+type StreamEntry struct {
+	ID      string
+	Message string
+}
+
+// StreamIDAt returns the stream ID bounding entries written at t: the first
+// possible ID in that millisecond, or with end set, the last.
+func StreamIDAt(t time.Time, end bool) string {
+	if end {
+		return fmt.Sprintf("%d-%d", t.UnixMilli(), uint64(math.MaxUint64))
+	}
+	return fmt.Sprintf("%d-0", t.UnixMilli())
+}
+
 // Stream returns a channel that receives log messages in real-time
 func (s *StreamSink) Stream(ctx context.Context, jobID string, from string) (<-chan string, error) {
-	key := streamKey(jobID)
+	entries, err := s.Follow(ctx, jobID, from)
+	if err != nil {
+		return nil, err
+	}
+
 	ch := make(chan string, 100)
+	go func() {
+		defer close(ch)
+		for entry := range entries {
+			select {
+			case ch <- entry.Message:
+			case <-ctx.Done():
+				return
+			}
+		}
+	}()
+
+	return ch, nil
+}
+
+// Follow returns a channel that receives log entries appended after the
+// stream ID from ("0" or empty replays the whole stream). The channel is
+// closed when ctx is done or Redis returns a non-timeout error.
+func (s *StreamSink) Follow(ctx context.Context, jobID string, from string) (<-chan StreamEntry, error) {
+	key := streamKey(jobID)
+	ch := make(chan StreamEntry, 100)
 
 	if from == "" {
 		from = "0" // Start from beginning
@@ -86,7 +126,7 @@ func (s *StreamSink) Stream(ctx context.Context, jobID string, from string) (<-c
 						lastID = msg.ID
 						if message, ok := msg.Values["message"].(string); ok {
 							select {
-							case ch <- message:
+							case ch <- StreamEntry{ID: msg.ID, Message: message}:
 							case <-ctx.Done():
 								return
 							}
@@ -102,6 +142,23 @@ func (s *StreamSink) Stream(ctx context.Context, jobID string, from string) (<-c
 
 // GetLogs retrieves all logs for a job (non-streaming)
 func (s *StreamSink) GetLogs(ctx context.Context, jobID string, start, end string) ([]string, error) {
+	entries, err := s.Entries(ctx, jobID, start, end, 0)
+	if err != nil {
+		return nil, err
+	}
+
+	logs := make([]string, 0, len(entries))
+	for _, entry := range entries {
+		logs = append(logs, entry.Message)
+	}
+
+	return logs, nil
+}
+
+// Entries returns the log entries between the stream IDs start and end
+// (inclusive; prefix an ID with "(" to exclude it). A count of zero returns
+// every entry in the range.
+func (s *StreamSink) Entries(ctx context.Context, jobID string, start, end string, count int64) ([]StreamEntry, error) {
 	key := streamKey(jobID)
 
 	if start == "" {
@@ -111,19 +168,42 @@ func (s *StreamSink) GetLogs(ctx context.Context, jobID string, start, end strin
 		end = "+"
 	}
 
-	messages, err := s.client.rdb.XRange(ctx, key, start, end).Result()
+	var messages []redis.XMessage
+	var err error
+	if count > 0 {
+		messages, err = s.client.rdb.XRangeN(ctx, key, start, end, count).Result()
+	} else {
+		messages, err = s.client.rdb.XRange(ctx, key, start, end).Result()
+	}
 	if err != nil {
 		return nil, fmt.Errorf("failed to get logs: %w", err)
 	}
 
-	logs := make([]string, 0, len(messages))
+	return toEntries(messages), nil
+}
+
+// Tail returns the last n log entries for a job, oldest first.
+func (s *StreamSink) Tail(ctx context.Context, jobID string, n int64) ([]StreamEntry, error) {
+	messages, err := s.client.rdb.XRevRangeN(ctx, streamKey(jobID), "+", "-", n).Result()
+	if err != nil {
+		return nil, fmt.Errorf("failed to get logs: %w", err)
+	}
+
+	entries := toEntries(messages)
+	for i, j := 0, len(entries)-1; i < j; i, j = i+1, j-1 {
+		entries[i], entries[j] = entries[j], entries[i]
+	}
+	return entries, nil
+}
+
+func toEntries(messages []redis.XMessage) []StreamEntry {
+	entries := make([]StreamEntry, 0, len(messages))
 	for _, msg := range messages {
 		if message, ok := msg.Values["message"].(string); ok {
-			logs = append(logs, message)
+			entries = append(entries, StreamEntry{ID: msg.ID, Message: message})
 		}
 	}
-
-	return logs, nil
+	return entries
 }
 
 // DeleteLogs removes all logs for a job
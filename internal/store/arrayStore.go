@@ -0,0 +1,84 @@
+package store
+
+import (
+	"strings"
+	"time"
+
+	"gpu-runner/internal/jobs"
+)
+
+// ArrayCounts returns how many of an array's children are in each status.
+func (s *JobStore) ArrayCounts(arrayID string) (map[jobs.JobStatus]int, error) {
+	rows, err := s.DB.Query(`SELECT status, COUNT(*) FROM jobs WHERE array_id = ? GROUP BY status`, arrayID)
+	if err != nil {
+		serverLogger.Error("Failed to count array jobs", "error", err, "array_id", arrayID)
+		return nil, err
+	}
+	defer rows.Close()
+
+	counts := make(map[jobs.JobStatus]int)
+	for rows.Next() {
+		var status string
+		var n int
+		if err := rows.Scan(&status, &n); err != nil {
+			return nil, err
+		}
+		counts[jobs.JobStatus(status)] = n
+	}
+	return counts, rows.Err()
+}
+
+// ArrayJobs returns an array's children in index order. With statuses
+// given, only children in one of them are returned, at most limit of them
+// when limit is positive.
+func (s *JobStore) ArrayJobs(arrayID string, limit int, statuses ...jobs.JobStatus) ([]*jobs.Job, error) {
+	query := `SELECT ` + jobSelectColumns + ` FROM jobs WHERE array_id = ?`
+	args := []any{arrayID}
+	if len(statuses) > 0 {
+		placeholders := make([]string, len(statuses))
+		for i, st := range statuses {
+			placeholders[i] = "?"
+			args = append(args, string(st))
+		}
+		query += ` AND status IN (` + strings.Join(placeholders, ", ") + `)`
+	}
+	query += ` ORDER BY array_index`
+	if limit > 0 {
+		query += ` LIMIT ?`
+		args = append(args, limit)
+	}
+
+	rows, err := s.DB.Query(query, args...)
+	if err != nil {
+		serverLogger.Error("Failed to query array jobs", "error", err, "array_id", arrayID)
+		return nil, err
+	}
+	defer rows.Close()
+
+	var children []*jobs.Job
+	for rows.Next() {
+		j, err := scanJob(rows)
+		if err != nil {
+			serverLogger.Error("Failed to scan array job", "error", err, "array_id", arrayID)
+			return nil, err
+		}
+		children = append(children, j)
+	}
+	return children, rows.Err()
+}
+
+// SetArrayStatus records an array parent's summarised status. A cancelled
+// array stays cancelled.
+func (s *JobStore) SetArrayStatus(id string, status jobs.JobStatus) error {
+	var finishedAt *time.Time
+	if status.IsTerminal() {
+		now := time.Now()
+		finishedAt = &now
+	}
+	_, err := s.DB.Exec(`UPDATE jobs SET status = ?, finished_at = ? WHERE id = ? AND status != ?`,
+		string(status), finishedAt, id, string(jobs.StatusCancelled))
+	if err != nil {
+		serverLogger.Error("Failed to update array status", "error", err, "job_id", id)
+	}
+	return err
+}
@@ -0,0 +1,118 @@
+package store
+
+import (
+	"gpu-runner/internal/jobs"
+)
+
+const attemptSchema = `
+CREATE TABLE IF NOT EXISTS job_attempts (
+    id INTEGER PRIMARY KEY AUTOINCREMENT,
+    job_id INTEGER NOT NULL,
+    attempt INTEGER NOT NULL,
+    status TEXT NOT NULL,
+    worker_id TEXT,
+    started_at DATETIME,
+    finished_at DATETIME,
+    exit_code INTEGER,
+    signal TEXT,
+    error TEXT,
+    log_start_id TEXT,
+    log_end_id TEXT,
+    UNIQUE (job_id, attempt)
+);`
+
+var attemptMigrations = []columnMigration{
+	{"failure_reason", "TEXT"},
+	{"termination_signal", "TEXT"},
+	{"peak_memory_bytes", "INTEGER"},
+	{"cpu_seconds", "REAL"},
+}
+
+// RecordAttempt inserts or updates the row for a.JobID's attempt number
+// a.Attempt, so workers can report the start and the end of a run
+// separately.
+func (s *JobStore) RecordAttempt(a jobs.Attempt) error {
+	_, err := s.DB.Exec(
+		`INSERT INTO job_attempts
+			(job_id, attempt, status, worker_id, started_at, finished_at, exit_code, signal, error, failure_reason,
+			 log_start_id, log_end_id, termination_signal, peak_memory_bytes, cpu_seconds)
+			VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)
+		ON CONFLICT (job_id, attempt) DO UPDATE SET
+			status = excluded.status,
+			worker_id = excluded.worker_id,
+			started_at = COALESCE(excluded.started_at, started_at),
+			finished_at = excluded.finished_at,
+			exit_code = excluded.exit_code,
+			signal = excluded.signal,
+			error = excluded.error,
+			failure_reason = excluded.failure_reason,
+			log_start_id = COALESCE(excluded.log_start_id, log_start_id),
+			log_end_id = excluded.log_end_id,
+			termination_signal = excluded.termination_signal,
+			peak_memory_bytes = excluded.peak_memory_bytes,
+			cpu_seconds = excluded.cpu_seconds`,
+		a.JobID,
+		a.Attempt,
+		string(a.Status),
+		nullString(a.WorkerID),
+		a.StartedAt,
+		a.FinishedAt,
+		a.ExitCode,
+		nullString(a.Signal),
+		nullString(a.Error),
+		nullString(string(a.FailureReason)),
+		nullString(a.LogStartID),
+		nullString(a.LogEndID),
+		nullString(a.TerminationSignal),
+		a.PeakMemoryBytes,
+		a.CPUSeconds,
+	)
+	if err != nil {
+		serverLogger.Error("Failed to record job attempt", "error", err, "job_id", a.JobID, "attempt", a.Attempt)
+		return err
+	}
+	return nil
+}
+
+// ListAttempts returns every recorded attempt of a job, oldest first.
+func (s *JobStore) ListAttempts(jobID string) ([]jobs.Attempt, error) {
+	rows, err := s.DB.Query(
+		`SELECT job_id, attempt, status, COALESCE(worker_id, ''), started_at, finished_at, exit_code,
+			COALESCE(signal, ''), COALESCE(error, ''), COALESCE(failure_reason, ''), COALESCE(log_start_id, ''), COALESCE(log_end_id, ''),
+			COALESCE(termination_signal, ''), COALESCE(peak_memory_bytes, 0), COALESCE(cpu_seconds, 0)
+		FROM job_attempts WHERE job_id = ? ORDER BY attempt`, jobID)
+	if err != nil {
+		serverLogger.Error("Failed to query job attempts", "error", err, "job_id", jobID)
+		return nil, err
+	}
+	defer rows.Close()
+
+	attempts := []jobs.Attempt{}
+	for rows.Next() {
+		var a jobs.Attempt
+		var status string
+		if err := rows.Scan(
+			&a.JobID,
+			&a.Attempt,
+			&status,
+			&a.WorkerID,
+			&a.StartedAt,
+			&a.FinishedAt,
+			&a.ExitCode,
+			&a.Signal,
+			&a.Error,
+			&a.FailureReason,
+			&a.LogStartID,
+			&a.LogEndID,
+			&a.TerminationSignal,
+			&a.PeakMemoryBytes,
+			&a.CPUSeconds,
+		); err != nil {
+			serverLogger.Error("Failed to scan job attempt", "error", err, "job_id", jobID)
+			return nil, err
+		}
+		a.Status = jobs.JobStatus(status)
+		attempts = append(attempts, a)
+	}
+	return attempts, rows.Err()
+}
@@ -0,0 +1,208 @@
+package store
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"gpu-runner/internal/jobs"
+)
+
+const (
+	DefaultListLimit = 50
+	MaxListLimit     = 500
+)
+
+// ErrInvalidCursor is returned when a list cursor cannot be decoded or was
+// issued for a different sort order.
+var ErrInvalidCursor = errors.New("invalid cursor")
+
+// sortColumns maps the accepted sort keys to the expressions they order by.
+// Timestamps are compared through julianday so rows written with different
+// UTC offsets still order correctly.
+var sortColumns = map[string]string{
+	"created_at":  "COALESCE(julianday(created_at), 0)",
+	"started_at":  "COALESCE(julianday(started_at), 0)",
+	"finished_at": "COALESCE(julianday(finished_at), 0)",
+	"deadline":    "COALESCE(julianday(deadline), 0)",
+	"priority":    "COALESCE(priority, 0)",
+}
+
+// JobFilter selects and orders jobs for ListJobs. Zero values mean "no
+// constraint".
+type JobFilter struct {
+	Statuses      []jobs.JobStatus
+	Command       string
+	CreatedAfter  time.Time
+	CreatedBefore time.Time
+	Labels        map[string]string
+	ArrayID       string
+	SortBy        string
+	Descending    bool
+	Limit         int
+	Cursor        string
+}
+
+// JobPage is one page of ListJobs results. NextCursor is empty on the last
+// page.
+type JobPage struct {
+	Jobs       []*jobs.Job `json:"jobs"`
+	NextCursor string      `json:"next_cursor,omitempty"`
+}
+
+// listCursor is the decoded form of the opaque cursor handed to clients. It
+// pins the sort so a cursor cannot be replayed against a different ordering.
+type listCursor struct {
+	SortBy     string  `json:"s"`
+	Descending bool    `json:"d"`
+	Key        float64 `json:"k"`
+	ID         int64   `json:"i"`
+}
+
+func encodeCursor(c listCursor) string {
+	data, _ := json.Marshal(c)
+	return base64.RawURLEncoding.EncodeToString(data)
+}
+
+func decodeCursor(raw string) (listCursor, error) {
+	var c listCursor
+	data, err := base64.RawURLEncoding.DecodeString(raw)
+	if err != nil {
+		return c, ErrInvalidCursor
+	}
+	if err := json.Unmarshal(data, &c); err != nil {
+		return c, ErrInvalidCursor
+	}
+	return c, nil
+}
+
+// ValidSortKey reports whether key can be used as JobFilter.SortBy.
+func ValidSortKey(key string) bool {
+	_, ok := sortColumns[key]
+	return ok
+}
+
+// ListJobs returns the jobs matching f, ordered by f.SortBy and then by id,
+// starting after f.Cursor.
+func (s *JobStore) ListJobs(f JobFilter) (*JobPage, error) {
+	if f.SortBy == "" {
+		f.SortBy = "created_at"
+	}
+	sortExpr, ok := sortColumns[f.SortBy]
+	if !ok {
+		return nil, fmt.Errorf("unsupported sort key %q", f.SortBy)
+	}
+	if f.Limit <= 0 {
+		f.Limit = DefaultListLimit
+	}
+	if f.Limit > MaxListLimit {
+		f.Limit = MaxListLimit
+	}
+
+	var where []string
+	var args []any
+
+	if len(f.Statuses) > 0 {
+		placeholders := make([]string, len(f.Statuses))
+		for i, st := range f.Statuses {
+			placeholders[i] = "?"
+			args = append(args, string(st))
+		}
+		where = append(where, "status IN ("+strings.Join(placeholders, ", ")+")")
+	}
+	if f.Command != "" {
+		where = append(where, `command LIKE ? ESCAPE '\'`)
+		args = append(args, "%"+escapeLike(f.Command)+"%")
+	}
+	if !f.CreatedAfter.IsZero() {
+		where = append(where, "julianday(created_at) >= julianday(?)")
+		args = append(args, f.CreatedAfter)
+	}
+	if !f.CreatedBefore.IsZero() {
+		where = append(where, "julianday(created_at) < julianday(?)")
+		args = append(args, f.CreatedBefore)
+	}
+	if f.ArrayID != "" {
+		where = append(where, "array_id = ?")
+		args = append(args, f.ArrayID)
+	}
+	for key, value := range f.Labels {
+		where = append(where, "json_extract(labels, ?) = ?")
+		args = append(args, labelPath(key), value)
+	}
+
+	cmp, order := ">", "ASC"
+	if f.Descending {
+		cmp, order = "<", "DESC"
+	}
+	if f.Cursor != "" {
+		c, err := decodeCursor(f.Cursor)
+		if err != nil {
+			return nil, err
+		}
+		if c.SortBy != f.SortBy || c.Descending != f.Descending {
+			return nil, ErrInvalidCursor
+		}
+		where = append(where, fmt.Sprintf("(%[1]s %[2]s ? OR (%[1]s = ? AND id %[2]s ?))", sortExpr, cmp))
+		args = append(args, c.Key, c.Key, c.ID)
+	}
+
+	query := `SELECT ` + jobSelectColumns + `, ` + sortExpr + ` FROM jobs`
+	if len(where) > 0 {
+		query += " WHERE " + strings.Join(where, " AND ")
+	}
+	// Fetch one extra row to learn whether another page exists.
+	query += fmt.Sprintf(" ORDER BY %s %s, id %s LIMIT ?", sortExpr, order, order)
+	args = append(args, f.Limit+1)
+
+	rows, err := s.DB.Query(query, args...)
+	if err != nil {
+		serverLogger.Error("Database list query failed", "error", err)
+		return nil, err
+	}
+	defer rows.Close()
+
+	page := &JobPage{Jobs: []*jobs.Job{}}
+	var lastKey float64
+	for rows.Next() {
+		var key float64
+		j, err := scanJob(rows, &key)
+		if err != nil {
+			serverLogger.Error("Failed to scan job row", "error", err)
+			return nil, err
+		}
+		if len(page.Jobs) == f.Limit {
+			last := page.Jobs[len(page.Jobs)-1]
+			id, _ := strconv.ParseInt(last.ID, 10, 64)
+			page.NextCursor = encodeCursor(listCursor{
+				SortBy:     f.SortBy,
+				Descending: f.Descending,
+				Key:        lastKey,
+				ID:         id,
+			})
+			break
+		}
+		page.Jobs = append(page.Jobs, j)
+		lastKey = key
+	}
+	if err := rows.Err(); err != nil {
+		serverLogger.Error("Database list iteration failed", "error", err)
+		return nil, err
+	}
+	return page, nil
+}
+
+func escapeLike(s string) string {
+	r := strings.NewReplacer(`\`, `\\`, `%`, `\%`, `_`, `\_`)
+	return r.Replace(s)
+}
+
+// labelPath builds a JSON path for a label key, quoting it so keys with dots
+// or spaces address a single member.
+func labelPath(key string) string {
+	return `$."` + strings.ReplaceAll(key, `"`, `\"`) + `"`
+}
@@ -2,6 +2,7 @@ package store
 
 import (
 	"database/sql"
+	"encoding/json"
 	"fmt"
 	"log/slog"
 	"time"
@@ -54,9 +55,145 @@ CREATE TABLE IF NOT EXISTS jobs (
     finished_at DATETIME,
     exit_code INTEGER
 );`
-	_, err := s.DB.Exec(schema)
-	return err
+	if _, err := s.DB.Exec(schema); err != nil {
+		return err
+	}
+	if err := s.migrate(); err != nil {
+		return err
+	}
+	if _, err := s.DB.Exec(attemptSchema); err != nil {
+		return err
+	}
+	if _, err := s.DB.Exec(scheduleSchema); err != nil {
+		return err
+	}
+	if _, err := s.DB.Exec(workflowSchema); err != nil {
+		return err
+	}
+	return s.addColumns("job_attempts", attemptMigrations)
+
+}
+
+// jobMigrations lists columns added to the jobs table after its first
+// release. Existing databases get them through ALTER TABLE on startup.
+var jobMigrations = []columnMigration{
+	{"labels", "TEXT"},
+	{"timeout_seconds", "INTEGER"},
+	{"deadline", "DATETIME"},
+	{"signal", "TEXT"},
+	{"worker_id", "TEXT"},
+	{"max_retries", "INTEGER"},
+	{"job_trial", "INTEGER"},
+	{"error", "TEXT"},
+	{"failure_reason", "TEXT"},
+	{"retry_policy", "TEXT"},
+	{"priority", "INTEGER NOT NULL DEFAULT 0"},
+	{"run_at", "DATETIME"},
+	{"depends_on", "TEXT"},
+	{"workflow_id", "TEXT"},
+	{"workflow_step", "TEXT"},
+	{"env", "TEXT"},
+	{"array_spec", "TEXT"},
+	{"array_id", "TEXT"},
+	{"array_index", "INTEGER"},
+	{"inputs", "TEXT"},
+	{"outputs", "TEXT"},
+	{"secrets", "TEXT"},
+	{"argv", "TEXT"},
+	{"shell", "TEXT"},
+	{"workdir", "TEXT"},
+	{"termination_signal", "TEXT"},
+	{"cpu", "REAL"},
+	{"memory_bytes", "INTEGER"},
+	{"max_pids", "INTEGER"},
+	{"peak_memory_bytes", "INTEGER"},
+	{"cpu_seconds", "REAL"},
+	{"runtime", "TEXT"},
+	{"image", "TEXT"},
+	{"sandbox", "INTEGER"},
+	{"no_network", "INTEGER"},
+	{"gpus", "TEXT"},
+	{"gpu_devices", "TEXT"},
+}
+
+// jobDataFixes normalise rows written by earlier versions. They must be
+// safe to run on every startup.
+var jobDataFixes = []string{
+	// Timestamps used to be stored as empty strings until set.
+	`UPDATE jobs SET started_at = NULL WHERE started_at = ''`,
+	`UPDATE jobs SET finished_at = NULL WHERE finished_at = ''`,
+}
+
+var jobIndexes = []string{
+	`CREATE INDEX IF NOT EXISTS idx_jobs_status ON jobs(status)`,
+	`CREATE INDEX IF NOT EXISTS idx_jobs_created_at ON jobs(created_at)`,
+	`CREATE INDEX IF NOT EXISTS idx_jobs_workflow_id ON jobs(workflow_id)`,
+	`CREATE INDEX IF NOT EXISTS idx_jobs_array_id ON jobs(array_id, array_index)`,
+}
+
+func (s *JobStore) migrate() error {
+	if err := s.addColumns("jobs", jobMigrations); err != nil {
+		return err
+	}
+	for _, fix := range jobDataFixes {
+		if _, err := s.DB.Exec(fix); err != nil {
+			return fmt.Errorf("normalise jobs: %w", err)
+		}
+	}
+	for _, idx := range jobIndexes {
+		if _, err := s.DB.Exec(idx); err != nil {
+			return fmt.Errorf("create index: %w", err)
+		}
+	}
+	return nil
+}
+
+type columnMigration struct {
+	column string
+	decl   string
+}
+
+// addColumns adds the listed columns to table when they are missing.
+func (s *JobStore) addColumns(table string, migrations []columnMigration) error {
+	existing, err := s.tableColumns(table)
+	if err != nil {
+		return err
+	}
+	for _, m := range migrations {
+		if existing[m.column] {
+			continue
+		}
+		serverLogger.Info("Adding column to table", "table", table, "column", m.column)
+		if _, err := s.DB.Exec(fmt.Sprintf("ALTER TABLE %s ADD COLUMN %s %s", table, m.column, m.decl)); err != nil {
+			return fmt.Errorf("add column %s.%s: %w", table, m.column, err)
+		}
+	}
+	return nil
+}
+
+func (s *JobStore) tableColumns(table string) (map[string]bool, error) {
+	rows, err := s.DB.Query(fmt.Sprintf("PRAGMA table_info(%s)", table))
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
 
+	columns := make(map[string]bool)
+	for rows.Next() {
+		var (
+			cid      int
+			name     string
+			declType string
+			notNull  int
+			dflt     sql.NullString
+			pk       int
+		)
+		if err := rows.Scan(&cid, &name, &declType, &notNull, &dflt, &pk); err != nil {
+			return nil, err
+		}
+		columns[name] = true
+	}
+	return columns, rows.Err()
 }
 
 func (s *JobStore) CreateJob(j *jobs.Job) error {
@@ -64,10 +201,65 @@ func (s *JobStore) CreateJob(j *jobs.Job) error {
 		j.CreatedAt = time.Now()
 	}
 
+	labels, err := encodeJSON(j.Labels)
+	if err != nil {
+		serverLogger.Error("Failed to encode job labels", "error", err)
+		return err
+	}
+	retryPolicy, err := encodeJSON(j.RetryPolicy)
+	if err != nil {
+		serverLogger.Error("Failed to encode job retry policy", "error", err)
+		return err
+	}
+	dependsOn, err := encodeJSON(j.DependsOn)
+	if err != nil {
+		serverLogger.Error("Failed to encode job dependencies", "error", err)
+		return err
+	}
+	env, err := encodeJSON(j.Env)
+	if err != nil {
+		serverLogger.Error("Failed to encode job environment", "error", err)
+		return err
+	}
+	arraySpec, err := encodeJSON(j.Array)
+	if err != nil {
+		serverLogger.Error("Failed to encode job array", "error", err)
+		return err
+	}
+	inputs, err := encodeJSON(j.Inputs)
+	if err != nil {
+		serverLogger.Error("Failed to encode job inputs", "error", err)
+		return err
+	}
+	outputs, err := encodeJSON(j.Outputs)
+	if err != nil {
+		serverLogger.Error("Failed to encode job outputs", "error", err)
+		return err
+	}
+	secrets, err := encodeJSON(j.Secrets)
+	if err != nil {
+		serverLogger.Error("Failed to encode job secret references", "error", err)
+		return err
+	}
+	argv, err := encodeJSON(j.Argv)
+	if err != nil {
+		serverLogger.Error("Failed to encode job argv", "error", err)
+		return err
+	}
+	gpus, err := encodeJSON(j.GPUs)
+	if err != nil {
+		serverLogger.Error("Failed to encode job GPU request", "error", err)
+		return err
+	}
+
 	result, err := s.DB.Exec(
 		`INSERT INTO jobs
-			(command, status, storage_bytes, volume_path, created_at, started_at, finished_at)
-				VALUES (?, ?, ?, ?, ?, ?, ?)`,
+			(command, status, storage_bytes, volume_path, created_at, started_at, finished_at, labels,
+			 timeout_seconds, deadline, max_retries, job_trial, retry_policy, priority, run_at,
+			 depends_on, workflow_id, workflow_step, env, array_spec, array_id, array_index, inputs, outputs,
+			 secrets, argv, shell, workdir, cpu, memory_bytes, max_pids, runtime, image,
+			 sandbox, no_network, gpus)
+				VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)`,
 		j.Command,
 		string(j.Status),
 		j.StorageBytes,
@@ -75,6 +267,35 @@ func (s *JobStore) CreateJob(j *jobs.Job) error {
 		j.CreatedAt,
 		j.StartedAt,
 		j.FinishedAt,
+		labels,
+		j.TimeoutSeconds,
+		j.Deadline,
+		j.MaxRetries,
+		j.JobTrial,
+		retryPolicy,
+		j.Priority,
+		j.RunAt,
+		dependsOn,
+		nullString(j.WorkflowID),
+		nullString(j.WorkflowStep),
+		env,
+		arraySpec,
+		nullString(j.ArrayID),
+		j.ArrayIndex,
+		inputs,
+		outputs,
+		secrets,
+		argv,
+		nullString(j.Shell),
+		nullString(j.Workdir),
+		j.CPU,
+		j.MemoryBytes,
+		j.MaxPids,
+		nullString(j.Runtime),
+		nullString(j.Image),
+		j.Sandbox,
+		j.NoNetwork,
+		gpus,
 	)
 
 	if err != nil {
@@ -92,14 +313,41 @@ func (s *JobStore) CreateJob(j *jobs.Job) error {
 	return nil
 }
 
+// UpdateJob records a lifecycle transition: status, timestamps, exit
+// details, worker and attempt. A job cancelled through CancelJob keeps its
+// cancelled status; only the worker's own cancelled report may update it.
 func (s *JobStore) UpdateJob(j *jobs.Job) error {
-	_, err := s.DB.Exec(
+	gpuDevices, err := encodeJSON(j.GPUDevices)
+	if err != nil {
+		serverLogger.Error("Failed to encode job GPU devices", "error", err, "job_id", j.ID)
+		return err
+	}
+	_, err = s.DB.Exec(
 		`UPDATE jobs
-		SET finished_at = ?, status = ?
-			WHERE id = ?`,
-		j.FinishedAt,
+		SET status = ?, started_at = ?, finished_at = ?, exit_code = ?, signal = ?,
+			worker_id = ?, job_trial = ?, max_retries = ?, error = ?, failure_reason = ?,
+			termination_signal = ?, peak_memory_bytes = ?, cpu_seconds = ?,
+			volume_path = COALESCE(?, volume_path), gpu_devices = COALESCE(?, gpu_devices)
+			WHERE id = ? AND (status != ? OR ? = ?)`,
 		j.Status,
+		j.StartedAt,
+		j.FinishedAt,
+		j.ExitCode,
+		nullString(j.Signal),
+		nullString(j.WorkerID),
+		j.JobTrial,
+		j.MaxRetries,
+		nullString(j.Error),
+		nullString(string(j.FailureReason)),
+		nullString(j.TerminationSignal),
+		j.PeakMemoryBytes,
+		j.CPUSeconds,
+		nullString(j.VolumePath),
+		gpuDevices,
 		j.ID,
+		jobs.StatusCancelled,
+		j.Status,
+		jobs.StatusCancelled,
 	)
 
 	if err != nil {
@@ -113,14 +361,28 @@ func (s *JobStore) UpdateJob(j *jobs.Job) error {
 
 
 
-func (s *JobStore) GetJob(id string) (*jobs.Job, error) {
-	row := s.DB.QueryRow(
-		`SELECT id, command, status, storage_bytes, volume_path, created_at, started_at, finished_at
-         FROM jobs WHERE id = ?`, id)
+// jobSelectColumns is the column list understood by scanJob.
+const jobSelectColumns = `id, command, status, storage_bytes, volume_path, created_at, started_at, finished_at, labels,
+	COALESCE(timeout_seconds, 0), deadline, exit_code, COALESCE(signal, ''), COALESCE(worker_id, ''),
+	COALESCE(max_retries, 0), COALESCE(job_trial, 0), COALESCE(error, ''), COALESCE(failure_reason, ''),
+	retry_policy, COALESCE(priority, 0), run_at, depends_on, COALESCE(workflow_id, ''), COALESCE(workflow_step, ''),
+	env, array_spec, COALESCE(array_id, ''), array_index, inputs, outputs,
+	secrets, argv, COALESCE(shell, ''), COALESCE(workdir, ''), COALESCE(termination_signal, ''),
+	COALESCE(cpu, 0), COALESCE(memory_bytes, 0), COALESCE(max_pids, 0), COALESCE(peak_memory_bytes, 0), COALESCE(cpu_seconds, 0),
+	COALESCE(runtime, ''), COALESCE(image, ''), COALESCE(sandbox, 0), COALESCE(no_network, 0),
+	gpus, gpu_devices`
+
+type rowScanner interface {
+	Scan(dest ...any) error
+}
 
+// scanJob reads a row selected with jobSelectColumns. Extra destinations are
+// scanned after the job columns.
+func scanJob(row rowScanner, extra ...any) (*jobs.Job, error) {
 	var j jobs.Job
 	var status string
-	err := row.Scan(
+	var labels, retryPolicy, dependsOn, env, arraySpec, inputs, outputs, secrets, argv, gpus, gpuDevices sql.NullString
+	dest := append([]any{
 		&j.ID,
 		&j.Command,
 		&status,
@@ -129,28 +391,245 @@ func (s *JobStore) GetJob(id string) (*jobs.Job, error) {
 		&j.CreatedAt,
 		&j.StartedAt,
 		&j.FinishedAt,
-	)
-	if err != nil {
-		serverLogger.Error("Database query failed", "error", err, "job_id", id)
+		&labels,
+		&j.TimeoutSeconds,
+		&j.Deadline,
+		&j.ExitCode,
+		&j.Signal,
+		&j.WorkerID,
+		&j.MaxRetries,
+		&j.JobTrial,
+		&j.Error,
+		&j.FailureReason,
+		&retryPolicy,
+		&j.Priority,
+		&j.RunAt,
+		&dependsOn,
+		&j.WorkflowID,
+		&j.WorkflowStep,
+		&env,
+		&arraySpec,
+		&j.ArrayID,
+		&j.ArrayIndex,
+		&inputs,
+		&outputs,
+		&secrets,
+		&argv,
+		&j.Shell,
+		&j.Workdir,
+		&j.TerminationSignal,
+		&j.CPU,
+		&j.MemoryBytes,
+		&j.MaxPids,
+		&j.PeakMemoryBytes,
+		&j.CPUSeconds,
+		&j.Runtime,
+		&j.Image,
+		&j.Sandbox,
+		&j.NoNetwork,
+		&gpus,
+		&gpuDevices,
+	}, extra...)
+	if err := row.Scan(dest...); err != nil {
 		return nil, err
 	}
 	j.Status = jobs.JobStatus(status)
+	if err := decodeJSON(labels, &j.Labels); err != nil {
+		return nil, fmt.Errorf("decode labels for job %s: %w", j.ID, err)
+	}
+	if err := decodeJSON(retryPolicy, &j.RetryPolicy); err != nil {
+		return nil, fmt.Errorf("decode retry policy for job %s: %w", j.ID, err)
+	}
+	if err := decodeJSON(dependsOn, &j.DependsOn); err != nil {
+		return nil, fmt.Errorf("decode dependencies for job %s: %w", j.ID, err)
+	}
+	if err := decodeJSON(env, &j.Env); err != nil {
+		return nil, fmt.Errorf("decode environment for job %s: %w", j.ID, err)
+	}
+	if err := decodeJSON(arraySpec, &j.Array); err != nil {
+		return nil, fmt.Errorf("decode array for job %s: %w", j.ID, err)
+	}
+	if err := decodeJSON(inputs, &j.Inputs); err != nil {
+		return nil, fmt.Errorf("decode inputs for job %s: %w", j.ID, err)
+	}
+	if err := decodeJSON(outputs, &j.Outputs); err != nil {
+		return nil, fmt.Errorf("decode outputs for job %s: %w", j.ID, err)
+	}
+	if err := decodeJSON(secrets, &j.Secrets); err != nil {
+		return nil, fmt.Errorf("decode secret references for job %s: %w", j.ID, err)
+	}
+	if err := decodeJSON(argv, &j.Argv); err != nil {
+		return nil, fmt.Errorf("decode argv for job %s: %w", j.ID, err)
+	}
+	if err := decodeJSON(gpus, &j.GPUs); err != nil {
+		return nil, fmt.Errorf("decode GPU request for job %s: %w", j.ID, err)
+	}
+	if err := decodeJSON(gpuDevices, &j.GPUDevices); err != nil {
+		return nil, fmt.Errorf("decode GPU devices for job %s: %w", j.ID, err)
+	}
 	return &j, nil
 }
 
+func (s *JobStore) GetJob(id string) (*jobs.Job, error) {
+	row := s.DB.QueryRow(`SELECT `+jobSelectColumns+` FROM jobs WHERE id = ?`, id)
+
+	j, err := scanJob(row)
+	if err != nil {
+		serverLogger.Error("Database query failed", "error", err, "job_id", id)
+		return nil, err
+	}
+	return j, nil
+}
+
+// JobStatus reads only a job's status.
+func (s *JobStore) JobStatus(id string) (jobs.JobStatus, error) {
+	var status string
+	if err := s.DB.QueryRow(`SELECT status FROM jobs WHERE id = ?`, id).Scan(&status); err != nil {
+		serverLogger.Error("Database query failed", "error", err, "job_id", id)
+		return "", err
+	}
+	return jobs.JobStatus(status), nil
+}
+
 func (s *JobStore) CancelJob(id string) (*jobs.Job, error) {
 	job, err := s.GetJob(id)
 	if err != nil {
 		return nil, err
 	}
-	if job.Status != jobs.StatusPending && job.Status != jobs.StatusRunning {
+	switch job.Status {
+	case jobs.StatusPending, jobs.StatusScheduled, jobs.StatusBlocked, jobs.StatusRunning:
+	default:
 		return nil, fmt.Errorf("job cannot be cancelled: status is %s", job.Status)
 	}
+	now := time.Now()
 	job.Status = jobs.StatusCancelled
-	_, err = s.DB.Exec(`UPDATE jobs SET status = ?, finished_at = ? WHERE id = ?`, string(job.Status), time.Now(), id)
+	job.FinishedAt = &now
+	_, err = s.DB.Exec(`UPDATE jobs SET status = ?, finished_at = ? WHERE id = ?`, string(job.Status), now, id)
 	if err != nil {
 		serverLogger.Error("Failed to cancel job in database", "error", err, "job_id", id)
 		return nil, err
 	}
 	return job, nil
 }
+
+// PromoteScheduled moves a scheduled job to pending once its run_at has
+// passed. Jobs in any other status are left alone.
+func (s *JobStore) PromoteScheduled(id string) error {
+	_, err := s.DB.Exec(`UPDATE jobs SET status = ? WHERE id = ? AND status = ?`,
+		string(jobs.StatusPending), id, string(jobs.StatusScheduled))
+	if err != nil {
+		serverLogger.Error("Failed to promote scheduled job in database", "error", err, "job_id", id)
+	}
+	return err
+}
+
+// BlockedDependents returns the blocked jobs that list parentID in their
+// depends_on.
+func (s *JobStore) BlockedDependents(parentID string) ([]*jobs.Job, error) {
+	rows, err := s.DB.Query(`SELECT `+jobSelectColumns+` FROM jobs
+		WHERE status = ? AND EXISTS (
+			SELECT 1 FROM json_each(jobs.depends_on) WHERE json_extract(value, '$.job_id') = ?)
+		ORDER BY id`,
+		string(jobs.StatusBlocked), parentID)
+	if err != nil {
+		serverLogger.Error("Failed to query dependent jobs", "error", err, "job_id", parentID)
+		return nil, err
+	}
+	defer rows.Close()
+
+	var dependents []*jobs.Job
+	for rows.Next() {
+		j, err := scanJob(rows)
+		if err != nil {
+			serverLogger.Error("Failed to scan dependent job", "error", err, "job_id", parentID)
+			return nil, err
+		}
+		dependents = append(dependents, j)
+	}
+	return dependents, rows.Err()
+}
+
+// ReleaseBlocked moves a blocked job to status once its dependencies are
+// met. It reports false if the job was no longer blocked, so only one
+// caller goes on to queue it.
+func (s *JobStore) ReleaseBlocked(id string, status jobs.JobStatus) (bool, error) {
+	res, err := s.DB.Exec(`UPDATE jobs SET status = ? WHERE id = ? AND status = ?`,
+		string(status), id, string(jobs.StatusBlocked))
+	if err != nil {
+		serverLogger.Error("Failed to release blocked job in database", "error", err, "job_id", id)
+		return false, err
+	}
+	n, _ := res.RowsAffected()
+	return n > 0, nil
+}
+
+// RequeueDeadJob resets a dead job to pending with j's command, limits and
+// retry settings, keeping its attempt count so attempt history continues.
+func (s *JobStore) RequeueDeadJob(j *jobs.Job) error {
+	labels, err := encodeJSON(j.Labels)
+	if err != nil {
+		serverLogger.Error("Failed to encode job labels", "error", err, "job_id", j.ID)
+		return err
+	}
+	retryPolicy, err := encodeJSON(j.RetryPolicy)
+	if err != nil {
+		serverLogger.Error("Failed to encode job retry policy", "error", err, "job_id", j.ID)
+		return err
+	}
+	res, err := s.DB.Exec(`
+		UPDATE jobs SET
+			status = ?, command = ?, labels = ?, timeout_seconds = ?, deadline = ?,
+			max_retries = ?, job_trial = ?, retry_policy = ?,
+			started_at = NULL, finished_at = NULL, exit_code = NULL, signal = NULL,
+			worker_id = NULL, error = NULL, failure_reason = NULL
+			WHERE id = ? AND status = ?`,
+		string(jobs.StatusPending),
+		j.Command,
+		labels,
+		j.TimeoutSeconds,
+		j.Deadline,
+		j.MaxRetries,
+		j.JobTrial,
+		retryPolicy,
+		j.ID,
+		string(jobs.StatusDead),
+	)
+	if err != nil {
+		serverLogger.Error("Failed to requeue dead job in database", "error", err, "job_id", j.ID)
+		return err
+	}
+	if n, _ := res.RowsAffected(); n == 0 {
+		return fmt.Errorf("job %s is not dead", j.ID)
+	}
+	j.Status = jobs.StatusPending
+	j.StartedAt, j.FinishedAt, j.ExitCode = nil, nil, nil
+	j.Signal, j.WorkerID, j.Error, j.FailureReason = "", "", "", ""
+	return nil
+}
+
+func nullString(s string) any {
+	if s == "" {
+		return nil
+	}
+	return s
+}
+
+// encodeJSON stores empty values as NULL so optional columns stay sparse.
+func encodeJSON(v any) (any, error) {
+	data, err := json.Marshal(v)
+	if err != nil {
+		return nil, err
+	}
+	switch string(data) {
+	case "null", "{}", "[]":
+		return nil, nil
+	}
+	return string(data), nil
+}
+
+func decodeJSON(col sql.NullString, v any) error {
+	if !col.Valid || col.String == "" {
+		return nil
+	}
+	return json.Unmarshal([]byte(col.String), v)
+}
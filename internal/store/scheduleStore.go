@@ -0,0 +1,160 @@
+package store
+
+import (
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"time"
+
+	"gpu-runner/internal/jobs"
+)
+
+const scheduleSchema = `
+CREATE TABLE IF NOT EXISTS schedules (
+    id INTEGER PRIMARY KEY AUTOINCREMENT,
+    name TEXT,
+    cron TEXT NOT NULL,
+    timezone TEXT NOT NULL,
+    overlap TEXT NOT NULL,
+    job TEXT NOT NULL,
+    created_at DATETIME,
+    next_run_at DATETIME NOT NULL,
+    last_run_at DATETIME,
+    last_job_id TEXT
+);`
+
+const scheduleSelectColumns = `id, COALESCE(name, ''), cron, timezone, overlap, job, created_at, next_run_at,
+	last_run_at, COALESCE(last_job_id, '')`
+
+// CreateSchedule stores a new schedule and sets its ID.
+func (s *JobStore) CreateSchedule(sc *jobs.Schedule) error {
+	if sc.CreatedAt.IsZero() {
+		sc.CreatedAt = time.Now()
+	}
+	template, err := json.Marshal(sc.Job)
+	if err != nil {
+		serverLogger.Error("Failed to encode schedule job template", "error", err)
+		return err
+	}
+
+	result, err := s.DB.Exec(
+		`INSERT INTO schedules (name, cron, timezone, overlap, job, created_at, next_run_at)
+			VALUES (?, ?, ?, ?, ?, ?, ?)`,
+		nullString(sc.Name),
+		sc.Cron,
+		sc.Timezone,
+		string(sc.Overlap),
+		string(template),
+		sc.CreatedAt,
+		sc.NextRunAt.UTC(),
+	)
+	if err != nil {
+		serverLogger.Error("Database insert failed", "error", err, "table", "schedules")
+		return err
+	}
+	lastId, err := result.LastInsertId()
+	if err != nil {
+		serverLogger.Error("Failed to retrieve last insert ID", "error", err)
+		return err
+	}
+	sc.ID = fmt.Sprintf("%d", lastId)
+	return nil
+}
+
+func scanSchedule(row rowScanner) (*jobs.Schedule, error) {
+	var sc jobs.Schedule
+	var overlap, template string
+	if err := row.Scan(
+		&sc.ID,
+		&sc.Name,
+		&sc.Cron,
+		&sc.Timezone,
+		&overlap,
+		&template,
+		&sc.CreatedAt,
+		&sc.NextRunAt,
+		&sc.LastRunAt,
+		&sc.LastJobID,
+	); err != nil {
+		return nil, err
+	}
+	sc.Overlap = jobs.OverlapPolicy(overlap)
+	if err := json.Unmarshal([]byte(template), &sc.Job); err != nil {
+		return nil, fmt.Errorf("decode job template for schedule %s: %w", sc.ID, err)
+	}
+	return &sc, nil
+}
+
+func (s *JobStore) GetSchedule(id string) (*jobs.Schedule, error) {
+	row := s.DB.QueryRow(`SELECT `+scheduleSelectColumns+` FROM schedules WHERE id = ?`, id)
+	sc, err := scanSchedule(row)
+	if err != nil {
+		serverLogger.Error("Database query failed", "error", err, "schedule_id", id)
+		return nil, err
+	}
+	return sc, nil
+}
+
+// ListSchedules returns every schedule, oldest first.
+func (s *JobStore) ListSchedules() ([]*jobs.Schedule, error) {
+	return s.querySchedules(`SELECT ` + scheduleSelectColumns + ` FROM schedules ORDER BY id`)
+}
+
+// DueSchedules returns the schedules whose next run is at or before now.
+func (s *JobStore) DueSchedules(now time.Time) ([]*jobs.Schedule, error) {
+	return s.querySchedules(`SELECT `+scheduleSelectColumns+` FROM schedules
+		WHERE julianday(next_run_at) <= julianday(?) ORDER BY next_run_at`, now)
+}
+
+func (s *JobStore) querySchedules(query string, args ...any) ([]*jobs.Schedule, error) {
+	rows, err := s.DB.Query(query, args...)
+	if err != nil {
+		serverLogger.Error("Database query failed", "error", err, "table", "schedules")
+		return nil, err
+	}
+	defer rows.Close()
+
+	var schedules []*jobs.Schedule
+	for rows.Next() {
+		sc, err := scanSchedule(rows)
+		if err != nil {
+			serverLogger.Error("Failed to scan schedule row", "error", err)
+			return nil, err
+		}
+		schedules = append(schedules, sc)
+	}
+	return schedules, rows.Err()
+}
+
+// AdvanceSchedule sets when a schedule fires next. When it fired at
+// firedAt, the run time and the job it created are recorded too.
+func (s *JobStore) AdvanceSchedule(id string, next time.Time, firedAt *time.Time, jobID string) error {
+	_, err := s.DB.Exec(`
+		UPDATE schedules SET
+			next_run_at = ?,
+			last_run_at = COALESCE(?, last_run_at),
+			last_job_id = COALESCE(?, last_job_id)
+			WHERE id = ?`,
+		next.UTC(),
+		firedAt,
+		nullString(jobID),
+		id,
+	)
+	if err != nil {
+		serverLogger.Error("Failed to advance schedule", "error", err, "schedule_id", id)
+	}
+	return err
+}
+
+// DeleteSchedule removes a schedule. Jobs it already created are kept.
+func (s *JobStore) DeleteSchedule(id string) error {
+	res, err := s.DB.Exec(`DELETE FROM schedules WHERE id = ?`, id)
+	if err != nil {
+		serverLogger.Error("Failed to delete schedule", "error", err, "schedule_id", id)
+		return err
+	}
+	if n, _ := res.RowsAffected(); n == 0 {
+		return sql.ErrNoRows
+	}
+	return nil
+}
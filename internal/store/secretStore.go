@@ -0,0 +1,177 @@
+package store
+
+import (
+	"bytes"
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"database/sql"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"time"
+
+	"gpu-runner/internal/jobs"
+)
+
+const secretSchema = `
+CREATE TABLE IF NOT EXISTS secrets (
+    name TEXT PRIMARY KEY,
+    value BLOB NOT NULL,
+    created_at DATETIME NOT NULL,
+    updated_at DATETIME NOT NULL
+);`
+
+// SecretStore keeps named secrets in the job database, encrypted with
+// AES-256-GCM under a key read from a file. Each value is bound to its
+// name, so rows can't be swapped between secrets.
+type SecretStore struct {
+	DB   *sql.DB
+	aead cipher.AEAD
+}
+
+// SecretInfo describes a stored secret without its value.
+type SecretInfo struct {
+	Name      string    `json:"name"`
+	CreatedAt time.Time `json:"created_at"`
+	UpdatedAt time.Time `json:"updated_at"`
+}
+
+// NewSecretStore opens the secrets table in db using the key in keyFile,
+// creating a new random key there if the file does not exist yet.
+func NewSecretStore(db *sql.DB, keyFile string) (*SecretStore, error) {
+	key, err := loadSecretKey(keyFile)
+	if err != nil {
+		serverLogger.Error("Failed to load secret key", "error", err, "path", keyFile)
+		return nil, err
+	}
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, err
+	}
+	aead, err := cipher.NewGCM(block)
+	if err != nil {
+		return nil, err
+	}
+	if _, err := db.Exec(secretSchema); err != nil {
+		serverLogger.Error("Failed to create secrets table", "error", err)
+		return nil, err
+	}
+	return &SecretStore{DB: db, aead: aead}, nil
+}
+
+// loadSecretKey reads a 32-byte key stored as 64 hex characters.
+func loadSecretKey(path string) ([]byte, error) {
+	data, err := os.ReadFile(path)
+	if errors.Is(err, os.ErrNotExist) {
+		key := make([]byte, 32)
+		if _, err := rand.Read(key); err != nil {
+			return nil, err
+		}
+		if err := os.MkdirAll(filepath.Dir(path), 0o700); err != nil {
+			return nil, err
+		}
+		if err := os.WriteFile(path, []byte(hex.EncodeToString(key)+"\n"), 0o600); err != nil {
+			return nil, fmt.Errorf("create secret key file: %w", err)
+		}
+		serverLogger.Warn("Created new secret key file; back it up, secrets can't be read without it", "path", path)
+		return key, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	key, err := hex.DecodeString(string(bytes.TrimSpace(data)))
+	if err != nil || len(key) != 32 {
+		return nil, fmt.Errorf("secret key file %s must hold 64 hex characters", path)
+	}
+	return key, nil
+}
+
+// PutSecret creates or replaces a secret.
+func (s *SecretStore) PutSecret(name, value string) error {
+	nonce := make([]byte, s.aead.NonceSize())
+	if _, err := rand.Read(nonce); err != nil {
+		return err
+	}
+	sealed := s.aead.Seal(nonce, nonce, []byte(value), []byte(name))
+	now := time.Now().UTC()
+	_, err := s.DB.Exec(
+		`INSERT INTO secrets (name, value, created_at, updated_at) VALUES (?, ?, ?, ?)
+			ON CONFLICT(name) DO UPDATE SET value = excluded.value, updated_at = excluded.updated_at`,
+		name, sealed, now, now,
+	)
+	if err != nil {
+		serverLogger.Error("Database upsert failed", "error", err, "table", "secrets", "name", name)
+	}
+	return err
+}
+
+// Secret decrypts a stored secret. It implements jobs.SecretResolver.
+func (s *SecretStore) Secret(name string) (string, error) {
+	var sealed []byte
+	err := s.DB.QueryRow(`SELECT value FROM secrets WHERE name = ?`, name).Scan(&sealed)
+	if errors.Is(err, sql.ErrNoRows) {
+		return "", jobs.ErrSecretNotFound
+	}
+	if err != nil {
+		return "", err
+	}
+	n := s.aead.NonceSize()
+	if len(sealed) < n {
+		return "", fmt.Errorf("secret %q is corrupt", name)
+	}
+	plain, err := s.aead.Open(nil, sealed[:n], sealed[n:], []byte(name))
+	if err != nil {
+		return "", fmt.Errorf("secret %q can't be decrypted with the configured key", name)
+	}
+	return string(plain), nil
+}
+
+// MissingSecrets returns the names from names that are not stored.
+func (s *SecretStore) MissingSecrets(names []string) ([]string, error) {
+	var missing []string
+	for _, name := range names {
+		var found int
+		err := s.DB.QueryRow(`SELECT 1 FROM secrets WHERE name = ?`, name).Scan(&found)
+		if errors.Is(err, sql.ErrNoRows) {
+			missing = append(missing, name)
+			continue
+		}
+		if err != nil {
+			return nil, err
+		}
+	}
+	return missing, nil
+}
+
+// ListSecrets returns every secret's name and timestamps, ordered by name.
+func (s *SecretStore) ListSecrets() ([]SecretInfo, error) {
+	rows, err := s.DB.Query(`SELECT name, created_at, updated_at FROM secrets ORDER BY name`)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	list := []SecretInfo{}
+	for rows.Next() {
+		var info SecretInfo
+		if err := rows.Scan(&info.Name, &info.CreatedAt, &info.UpdatedAt); err != nil {
+			return nil, err
+		}
+		list = append(list, info)
+	}
+	return list, rows.Err()
+}
+
+// DeleteSecret removes a secret, returning sql.ErrNoRows if there was none.
+func (s *SecretStore) DeleteSecret(name string) error {
+	result, err := s.DB.Exec(`DELETE FROM secrets WHERE name = ?`, name)
+	if err != nil {
+		return err
+	}
+	if n, _ := result.RowsAffected(); n == 0 {
+		return sql.ErrNoRows
+	}
+	return nil
+}
@@ -0,0 +1,69 @@
+package store
+
+import (
+	"fmt"
+	"time"
+
+	"gpu-runner/internal/jobs"
+)
+
+const workflowSchema = `
+CREATE TABLE IF NOT EXISTS workflows (
+    id INTEGER PRIMARY KEY AUTOINCREMENT,
+    name TEXT,
+    created_at DATETIME
+);`
+
+// CreateWorkflow stores a new workflow and sets its ID. Its jobs are
+// stored separately with CreateJob.
+func (s *JobStore) CreateWorkflow(wf *jobs.Workflow) error {
+	if wf.CreatedAt.IsZero() {
+		wf.CreatedAt = time.Now()
+	}
+	result, err := s.DB.Exec(`INSERT INTO workflows (name, created_at) VALUES (?, ?)`,
+		nullString(wf.Name), wf.CreatedAt)
+	if err != nil {
+		serverLogger.Error("Database insert failed", "error", err, "table", "workflows")
+		return err
+	}
+	lastId, err := result.LastInsertId()
+	if err != nil {
+		serverLogger.Error("Failed to retrieve last insert ID", "error", err)
+		return err
+	}
+	wf.ID = fmt.Sprintf("%d", lastId)
+	return nil
+}
+
+func (s *JobStore) GetWorkflow(id string) (*jobs.Workflow, error) {
+	var wf jobs.Workflow
+	err := s.DB.QueryRow(`SELECT id, COALESCE(name, ''), created_at FROM workflows WHERE id = ?`, id).
+		Scan(&wf.ID, &wf.Name, &wf.CreatedAt)
+	if err != nil {
+		serverLogger.Error("Database query failed", "error", err, "workflow_id", id)
+		return nil, err
+	}
+	return &wf, nil
+}
+
+// WorkflowJobs returns a workflow's jobs in the order they were created,
+// which puts every job after the jobs it depends on.
+func (s *JobStore) WorkflowJobs(id string) ([]*jobs.Job, error) {
+	rows, err := s.DB.Query(`SELECT `+jobSelectColumns+` FROM jobs WHERE workflow_id = ? ORDER BY id`, id)
+	if err != nil {
+		serverLogger.Error("Failed to query workflow jobs", "error", err, "workflow_id", id)
+		return nil, err
+	}
+	defer rows.Close()
+
+	var list []*jobs.Job
+	for rows.Next() {
+		j, err := scanJob(rows)
+		if err != nil {
+			serverLogger.Error("Failed to scan workflow job", "error", err, "workflow_id", id)
+			return nil, err
+		}
+		list = append(list, j)
+	}
+	return list, rows.Err()
+}
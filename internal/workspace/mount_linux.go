@@ -0,0 +1,78 @@
+//go:build linux
+
+package workspace
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"os/exec"
+	"strings"
+	"syscall"
+)
+
+// canMount reports why workspaces can't be mounted in mode, if they can't.
+func canMount(mode Mode) error {
+	if os.Geteuid() != 0 {
+		return errors.New("mounting workspaces needs root")
+	}
+	if mode == ModeLoop {
+		for _, tool := range []string{"mkfs.ext4", "mount"} {
+			if _, err := exec.LookPath(tool); err != nil {
+				return fmt.Errorf("%s not found", tool)
+			}
+		}
+	}
+	return nil
+}
+
+func mountTmpfs(path string, sizeBytes int64) error {
+	opts := fmt.Sprintf("size=%d,mode=0755", sizeBytes)
+	if err := syscall.Mount("tmpfs", path, "tmpfs", syscall.MS_NOSUID|syscall.MS_NODEV, opts); err != nil {
+		return fmt.Errorf("mount tmpfs: %w", err)
+	}
+	return nil
+}
+
+// mountLoop creates a sparse ext4 image of sizeBytes at image and mounts
+// it on path.
+func mountLoop(image, path string, sizeBytes int64) error {
+	f, err := os.OpenFile(image, os.O_CREATE|os.O_EXCL|os.O_WRONLY, 0o600)
+	if err != nil {
+		return err
+	}
+	err = f.Truncate(sizeBytes)
+	f.Close()
+	if err != nil {
+		return err
+	}
+	// -m 0 leaves no blocks reserved for root, so the job can use all of it.
+	if out, err := exec.Command("mkfs.ext4", "-q", "-F", "-m", "0", image).CombinedOutput(); err != nil {
+		return fmt.Errorf("mkfs.ext4: %w: %s", err, strings.TrimSpace(string(out)))
+	}
+	if out, err := exec.Command("mount", "-o", "loop,nosuid,nodev", image, path).CombinedOutput(); err != nil {
+		return fmt.Errorf("mount: %w: %s", err, strings.TrimSpace(string(out)))
+	}
+	return nil
+}
+
+// unmount detaches the filesystem mounted on path. A path with nothing
+// mounted on it is not an error.
+func unmount(path string) error {
+	err := syscall.Unmount(path, 0)
+	if err == nil || errors.Is(err, syscall.EINVAL) || errors.Is(err, syscall.ENOENT) {
+		return nil
+	}
+	return err
+}
+
+// fsUsage returns the bytes used on the filesystem mounted at path and
+// whether it has no space left.
+func fsUsage(path string) (int64, bool, error) {
+	var st syscall.Statfs_t
+	if err := syscall.Statfs(path, &st); err != nil {
+		return 0, false, err
+	}
+	used := int64(st.Blocks-st.Bfree) * st.Bsize
+	return used, st.Bavail == 0, nil
+}
@@ -0,0 +1,28 @@
+//go:build !linux
+
+package workspace
+
+import "errors"
+
+var errNoMounts = errors.New("mounted workspaces are only available on Linux")
+
+func canMount(mode Mode) error {
+	return errNoMounts
+}
+
+func mountTmpfs(path string, sizeBytes int64) error {
+	return errNoMounts
+}
+
+func mountLoop(image, path string, sizeBytes int64) error {
+	return errNoMounts
+}
+
+func unmount(path string) error {
+	return nil
+}
+
+func fsUsage(path string) (int64, bool, error) {
+	used, err := dirUsage(path)
+	return used, false, err
+}
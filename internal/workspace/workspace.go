@@ -0,0 +1,392 @@
+// Package workspace gives every job its own directory with a disk quota,
+// and removes it once the job has finished and its retention has passed.
+package workspace
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+
+	"gpu-runner/internal/logger"
+)
+
+var workspaceLogger = logger.Server
+
+// Mode is how a workspace's quota is enforced.
+type Mode string
+
+const (
+	// ModeDir is a plain directory whose usage is measured while the job
+	// runs; a job that goes over its quota is killed. It needs no
+	// privileges.
+	ModeDir Mode = "dir"
+	// ModeTmpfs mounts a tmpfs of the quota's size. Its contents live in
+	// memory.
+	ModeTmpfs Mode = "tmpfs"
+	// ModeLoop mounts an ext4 image file of the quota's size.
+	ModeLoop Mode = "loop"
+)
+
+// ParseMode reads a Mode name.
+func ParseMode(s string) (Mode, error) {
+	switch m := Mode(strings.ToLower(strings.TrimSpace(s))); m {
+	case ModeDir, ModeTmpfs, ModeLoop:
+		return m, nil
+	}
+	return "", fmt.Errorf("unknown workspace mode %q: must be dir, tmpfs or loop", s)
+}
+
+// ScanInterval is how often a workspace's usage is measured while its job
+// runs.
+var ScanInterval = 5 * time.Second
+
+// ErrQuotaExceeded is wrapped by the error Finish returns for a job that
+// used more than its workspace quota.
+var ErrQuotaExceeded = errors.New("workspace quota exceeded")
+
+// Retention is how long a workspace is kept after its job finished, by
+// outcome, so outputs can be collected and failures inspected. Zero
+// removes it at once; a negative duration keeps it until removed by hand.
+type Retention struct {
+	Success time.Duration
+	Failure time.Duration
+}
+
+// Manager creates workspaces under Root, one directory per job. What it
+// knows about each workspace is kept in Root/.meta so retention survives
+// server restarts.
+type Manager struct {
+	Root      string
+	Mode      Mode
+	Retention Retention
+
+	mu sync.Mutex
+}
+
+// record is the metadata file of one workspace.
+type record struct {
+	JobID      string     `json:"job_id"`
+	Mode       Mode       `json:"mode"`
+	QuotaBytes int64      `json:"quota_bytes"`
+	CreatedAt  time.Time  `json:"created_at"`
+	ExpiresAt  *time.Time `json:"expires_at,omitempty"`
+}
+
+// NewManager prepares root for workspaces. A mode that needs privileges
+// the server lacks falls back to ModeDir with a warning.
+func NewManager(root string, mode Mode, retention Retention) (*Manager, error) {
+	for _, dir := range []string{root, filepath.Join(root, ".meta"), filepath.Join(root, ".images")} {
+		if err := os.MkdirAll(dir, 0o755); err != nil {
+			return nil, fmt.Errorf("create workspace root: %w", err)
+		}
+	}
+	if mode != ModeDir {
+		if err := canMount(mode); err != nil {
+			workspaceLogger.Warn("Workspace mode unavailable; falling back to plain directories", "mode", mode, "reason", err)
+			mode = ModeDir
+		}
+	}
+	workspaceLogger.Info("Workspace manager ready", "root", root, "mode", mode,
+		"retain_success", retention.Success, "retain_failure", retention.Failure)
+	return &Manager{Root: root, Mode: mode, Retention: retention}, nil
+}
+
+func (m *Manager) path(jobID string) string {
+	return filepath.Join(m.Root, jobID)
+}
+
+func (m *Manager) metaPath(jobID string) string {
+	return filepath.Join(m.Root, ".meta", jobID+".json")
+}
+
+func (m *Manager) imagePath(jobID string) string {
+	return filepath.Join(m.Root, ".images", jobID+".img")
+}
+
+func (m *Manager) readRecord(jobID string) (*record, error) {
+	data, err := os.ReadFile(m.metaPath(jobID))
+	if err != nil {
+		return nil, err
+	}
+	var rec record
+	if err := json.Unmarshal(data, &rec); err != nil {
+		return nil, fmt.Errorf("workspace metadata for job %s: %w", jobID, err)
+	}
+	return &rec, nil
+}
+
+func (m *Manager) writeRecord(rec *record) error {
+	data, err := json.Marshal(rec)
+	if err != nil {
+		return err
+	}
+	tmp := m.metaPath(rec.JobID) + ".tmp"
+	if err := os.WriteFile(tmp, data, 0o644); err != nil {
+		return err
+	}
+	return os.Rename(tmp, m.metaPath(rec.JobID))
+}
+
+// Prepare returns the workspace for jobID with a quota of quotaBytes. A
+// retry gets the workspace of its earlier attempts back, so it can resume
+// from whatever they wrote; a job run again after its workspace was
+// removed gets a fresh, empty one.
+func (m *Manager) Prepare(jobID string, quotaBytes int64) (*Workspace, error) {
+	if jobID == "" || !filepath.IsLocal(jobID) || strings.ContainsRune(jobID, filepath.Separator) {
+		return nil, fmt.Errorf("invalid job ID %q for a workspace", jobID)
+	}
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	rec, err := m.readRecord(jobID)
+	if err == nil {
+		if _, statErr := os.Stat(m.path(jobID)); statErr != nil {
+			// Removed by hand; start over.
+			workspaceLogger.Warn("Job workspace missing; creating a new one", "job_id", jobID, "error", statErr)
+			if err := m.remove(rec); err != nil {
+				return nil, err
+			}
+			err = os.ErrNotExist
+		}
+	}
+	if err == nil {
+		if rec.ExpiresAt != nil {
+			rec.ExpiresAt = nil
+			if err := m.writeRecord(rec); err != nil {
+				return nil, err
+			}
+		}
+		workspaceLogger.Info("Reusing job workspace", "job_id", jobID, "path", m.path(jobID), "mode", rec.Mode)
+		return &Workspace{Path: m.path(jobID), JobID: jobID, Mode: rec.Mode, QuotaBytes: rec.QuotaBytes}, nil
+	}
+	if !errors.Is(err, os.ErrNotExist) {
+		return nil, err
+	}
+
+	path := m.path(jobID)
+	// A directory without metadata was left by a crash mid-creation.
+	if m.Mode != ModeDir {
+		if err := unmount(path); err != nil {
+			return nil, err
+		}
+	}
+	if err := os.RemoveAll(path); err != nil {
+		return nil, err
+	}
+	if err := os.Mkdir(path, 0o755); err != nil {
+		return nil, fmt.Errorf("create workspace: %w", err)
+	}
+	mode := m.Mode
+	switch mode {
+	case ModeTmpfs:
+		err = mountTmpfs(path, quotaBytes)
+	case ModeLoop:
+		err = mountLoop(m.imagePath(jobID), path, quotaBytes)
+	}
+	if err != nil {
+		workspaceLogger.Warn("Failed to mount job workspace; using a plain directory", "job_id", jobID, "mode", mode, "error", err)
+		os.Remove(m.imagePath(jobID))
+		mode = ModeDir
+	}
+
+	rec = &record{JobID: jobID, Mode: mode, QuotaBytes: quotaBytes, CreatedAt: time.Now().UTC()}
+	if err := m.writeRecord(rec); err != nil {
+		m.remove(rec)
+		return nil, err
+	}
+	workspaceLogger.Info("Created job workspace", "job_id", jobID, "path", path, "mode", mode, "quota_bytes", quotaBytes)
+	return &Workspace{Path: path, JobID: jobID, Mode: mode, QuotaBytes: quotaBytes}, nil
+}
+
+// Release starts the retention period of a finished job's workspace.
+func (m *Manager) Release(jobID string, success bool) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	rec, err := m.readRecord(jobID)
+	if errors.Is(err, os.ErrNotExist) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	keep := m.Retention.Failure
+	if success {
+		keep = m.Retention.Success
+	}
+	switch {
+	case keep == 0:
+		workspaceLogger.Info("Removing job workspace", "job_id", jobID)
+		return m.remove(rec)
+	case keep < 0:
+		return nil
+	}
+	expires := time.Now().Add(keep).UTC()
+	rec.ExpiresAt = &expires
+	workspaceLogger.Info("Retaining job workspace", "job_id", jobID, "until", expires)
+	return m.writeRecord(rec)
+}
+
+func (m *Manager) remove(rec *record) error {
+	path := m.path(rec.JobID)
+	if rec.Mode != ModeDir {
+		if err := unmount(path); err != nil {
+			return fmt.Errorf("unmount workspace of job %s: %w", rec.JobID, err)
+		}
+	}
+	if err := os.RemoveAll(path); err != nil {
+		return err
+	}
+	os.Remove(m.imagePath(rec.JobID))
+	err := os.Remove(m.metaPath(rec.JobID))
+	if errors.Is(err, os.ErrNotExist) {
+		return nil
+	}
+	return err
+}
+
+// Sweep removes the workspaces whose retention ended before now and
+// returns how many it removed.
+func (m *Manager) Sweep(now time.Time) (int, error) {
+	entries, err := os.ReadDir(filepath.Join(m.Root, ".meta"))
+	if err != nil {
+		return 0, err
+	}
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	removed := 0
+	for _, entry := range entries {
+		jobID, ok := strings.CutSuffix(entry.Name(), ".json")
+		if !ok {
+			continue
+		}
+		rec, err := m.readRecord(jobID)
+		if err != nil || rec.ExpiresAt == nil || rec.ExpiresAt.After(now) {
+			continue
+		}
+		if err := m.remove(rec); err != nil {
+			workspaceLogger.Error("Failed to remove expired workspace", "job_id", jobID, "error", err)
+			continue
+		}
+		removed++
+	}
+	return removed, nil
+}
+
+// StartJanitor sweeps expired workspaces every interval until ctx ends.
+func (m *Manager) StartJanitor(ctx context.Context, interval time.Duration) {
+	go func() {
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case now := <-ticker.C:
+				n, err := m.Sweep(now)
+				if err != nil {
+					workspaceLogger.Error("Workspace sweep failed", "error", err)
+				} else if n > 0 {
+					workspaceLogger.Info("Removed expired workspaces", "count", n)
+				}
+			}
+		}
+	}()
+}
+
+// Workspace is one job's directory.
+type Workspace struct {
+	Path       string
+	JobID      string
+	Mode       Mode
+	QuotaBytes int64
+
+	mu       sync.Mutex
+	used     int64
+	exceeded bool
+	full     bool
+	stop     chan struct{}
+	done     chan struct{}
+}
+
+// Watch measures the workspace every ScanInterval until Finish is called.
+// A ModeDir workspace over its quota has kill called once. Mounted
+// workspaces can't grow past their quota, so their jobs get ENOSPC
+// instead and are only noted as full.
+func (w *Workspace) Watch(kill func()) {
+	w.stop, w.done = make(chan struct{}), make(chan struct{})
+	go func() {
+		defer close(w.done)
+		ticker := time.NewTicker(ScanInterval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-w.stop:
+				return
+			case <-ticker.C:
+				if w.measure() && w.Mode == ModeDir {
+					workspaceLogger.Warn("Job exceeded its workspace quota; killing it", "job_id", w.JobID, "used_bytes", w.Used(), "quota_bytes", w.QuotaBytes)
+					kill()
+					return
+				}
+			}
+		}
+	}()
+}
+
+// measure records the current usage and reports whether the workspace is
+// over its quota, or full for a mounted one.
+func (w *Workspace) measure() bool {
+	var used int64
+	var full bool
+	var err error
+	if w.Mode == ModeDir {
+		used, err = dirUsage(w.Path)
+	} else {
+		used, full, err = fsUsage(w.Path)
+	}
+	if err != nil {
+		workspaceLogger.Warn("Failed to measure workspace usage", "job_id", w.JobID, "error", err)
+		return false
+	}
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	w.used = used
+	if w.Mode == ModeDir && used > w.QuotaBytes {
+		w.exceeded = true
+	}
+	if full {
+		w.full = true
+	}
+	return w.exceeded || w.full
+}
+
+// Used returns the usage last measured, in bytes.
+func (w *Workspace) Used() int64 {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	return w.used
+}
+
+// Finish stops watching, measures the workspace once more and returns an
+// error wrapping ErrQuotaExceeded if the job has to fail for its disk use:
+// a directory that went over its quota, or a mounted workspace that filled
+// up under a job that then failed.
+func (w *Workspace) Finish(runErr error) error {
+	if w.stop != nil {
+		close(w.stop)
+		<-w.done
+	}
+	w.measure()
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	if w.exceeded || (w.full && runErr != nil) {
+		return fmt.Errorf("%w: the job's workspace is limited to %d bytes and used %d", ErrQuotaExceeded, w.QuotaBytes, w.used)
+	}
+	return nil
+}